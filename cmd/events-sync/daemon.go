@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+	"github.com/dallasurbanists/events-sync/internal/syncer"
+)
+
+func runDaemon(args []string) error {
+	fs := newFlagSet("daemon")
+	var orgs orgList
+	fs.Var(&orgs, "org", "Organization to schedule; repeat or comma separate for several (defaults to all)")
+	concurrency := fs.Int("concurrency", 0, "Number of organizations to sync at once (defaults to the config's concurrency)")
+	shutdownTimeout := fs.Duration("shutdown-timeout", syncer.DefaultShutdownTimeout, "How long to wait for running syncs on SIGTERM before canceling them")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	selected, err := selectOrganizations(cfg, orgs)
+	if err != nil {
+		return err
+	}
+
+	if *concurrency <= 0 {
+		*concurrency = cfg.Concurrency
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	scheduler := &syncer.Scheduler{
+		Syncer:          newSyncer(db),
+		Organizations:   selected,
+		Concurrency:     *concurrency,
+		ShutdownTimeout: *shutdownTimeout,
+		OnResult:        reportScheduledResult,
+	}
+
+	fmt.Printf("Scheduling %d organizations\n", len(selected))
+	if err := scheduler.Run(ctx); err != nil {
+		return err
+	}
+	fmt.Println("Scheduler stopped")
+
+	return nil
+}
+
+func reportScheduledResult(r syncer.Result, next time.Time) {
+	duration := r.Finished.Sub(r.Started).Round(time.Millisecond)
+	nextSync := next.Format(time.RFC3339)
+
+	if r.Err != nil {
+		fmt.Printf("%s: failed after %s: %v; retrying at %s\n", r.Organization, duration, r.Err, nextSync)
+		return
+	}
+
+	fmt.Printf("%s: %s in %s, found %d, inserted %d, updated %d, pruned %d, restored %d; next sync at %s\n",
+		r.Organization, r.Status(), duration, r.Found, r.Inserted, r.Updated, r.Pruned, r.Restored, nextSync)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dallasurbanists/events-sync/internal/syncer"
+)
+
+func reportDiffs(diffs []syncer.OrganizationDiff) {
+	for _, d := range diffs {
+		fmt.Printf("\n=== Dry Run: %s ===\n", d.Organization)
+
+		if d.Error != "" {
+			fmt.Printf("error: %s\n", d.Error)
+			continue
+		}
+
+		counts := map[string]int{}
+		for _, e := range d.Events {
+			counts[e.Action]++
+
+			fmt.Printf("%s %-8s %s (UID: %s)\n", diffMarker(e.Action), e.Action, e.Summary, diffID(e))
+			if len(e.Significant) > 0 {
+				fmt.Printf("    significant: %s\n", strings.Join(e.Significant, ", "))
+			}
+			for _, field := range sortedFields(e) {
+				c := e.Changes[field]
+				fmt.Printf("    %s: %s -> %s\n", field, c.From, c.To)
+			}
+		}
+
+		if d.PruneBlocked != "" {
+			fmt.Printf("! %s\n", d.PruneBlocked)
+		}
+
+		fmt.Printf("found %d: %d new, %d changed, %d restored, %d pruned, %d unchanged\n",
+			d.Found, counts[syncer.DiffNew], counts[syncer.DiffChanged], counts[syncer.DiffRestored],
+			counts[syncer.DiffPruned], d.Unchanged)
+	}
+}
+
+func writeDiffsJSON(path string, diffs []syncer.OrganizationDiff) error {
+	b, err := json.MarshalIndent(diffs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+func diffMarker(action string) string {
+	switch action {
+	case syncer.DiffNew, syncer.DiffRestored:
+		return "+"
+	case syncer.DiffPruned:
+		return "-"
+	}
+
+	return "~"
+}
+
+func diffID(e syncer.EventDiff) string {
+	if e.RecurrenceID != "" {
+		return e.UID + " " + e.RecurrenceID
+	}
+
+	return e.UID
+}
+
+// sortedFields lists the event's changed fields alphabetically
+func sortedFields(e syncer.EventDiff) []string {
+	fields := make([]string, 0, len(e.Changes))
+	for field := range e.Changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	return fields
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+	"github.com/dallasurbanists/events-sync/pkg/event"
+)
+
+func runListEvents(args []string) error {
+	fs := newFlagSet("list-events")
+	org := fs.String("org", "", "Only list events of this organization")
+	uid := fs.String("uid", "", "Only list instances of this UID")
+	reviewStatus := fs.String("review-status", "", "Only list events with this review status: pending, needs_review, approved or rejected")
+	eventType := fs.String("type", "", "Only list events of this type")
+	upcoming := fs.Bool("upcoming", false, "Only list events that haven't started, expanding recurring series into their occurrences")
+	days := fs.Int("days", 180, "How many days ahead -upcoming lists events")
+	includeDeleted := fs.Bool("include-deleted", false, "Also list events pruned from their source")
+	asJSON := fs.Bool("json", false, "Print the events as JSON")
+	fs.Parse(args)
+
+	gi := &event.GetEventsInput{
+		UpcomingOnly:   *upcoming,
+		IncludeDeleted: *includeDeleted,
+	}
+	if *org != "" {
+		gi.Organization = org
+	}
+	if *uid != "" {
+		gi.UID = uid
+	}
+	if *reviewStatus != "" {
+		gi.ReviewStatus = reviewStatus
+	}
+	if *eventType != "" {
+		gi.Type = eventType
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close()
+
+	events, err := db.Events.GetEvents(gi)
+	if err != nil {
+		return fmt.Errorf("failed to get events: %v", err)
+	}
+
+	occurrences := []*event.Occurrence{}
+	if *upcoming {
+		// listing works without a config.json, in the default zone
+		cfg, _ := config.LoadConfig()
+		loc, err := cfg.Location()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		occurrences = event.ExpandOccurrences(events, now, now.AddDate(0, 0, *days), loc)
+	} else {
+		for _, e := range events {
+			occurrences = append(occurrences, &event.Occurrence{Event: e})
+		}
+	}
+
+	if *asJSON {
+		return printJSON(occurrences)
+	}
+
+	fmt.Printf("%-16s %-12s %-24s %-16s %s\n", "start", "status", "organization", "recurrence", "summary (uid)")
+	for _, e := range occurrences {
+		recurrence := ""
+		switch {
+		case e.Generated:
+			recurrence = "+" + *e.RecurrenceID
+		case e.RecurrenceID != nil:
+			recurrence = *e.RecurrenceID
+		case e.RRule != nil:
+			recurrence = "series"
+		}
+
+		status := e.ReviewStatus
+		if e.DeletedAt != nil {
+			status = "deleted"
+		}
+
+		fmt.Printf("%-16s %-12s %-24s %-16s %s (%s)\n",
+			e.StartTime.Local().Format("2006-01-02 15:04"), status, e.Organization, recurrence, e.Summary, e.UID)
+	}
+	fmt.Printf("\n%d events\n", len(occurrences))
+
+	return nil
+}
+
+func runShow(args []string) error {
+	fs := newFlagSet("show")
+	history := fs.Bool("history", false, "Also print the event's revisions, newest first")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a UID and an optional recurrence ID")
+	}
+
+	gi := &event.GetEventInput{UID: fs.Arg(0), IncludeDeleted: true}
+	if fs.NArg() == 2 {
+		recurrenceID := fs.Arg(1)
+		gi.RecurrenceID = &recurrenceID
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close()
+
+	e, err := db.Events.GetEvent(gi)
+	if err != nil {
+		return fmt.Errorf("failed to get event %s: %v", gi.UID, err)
+	}
+
+	if !*history {
+		return printJSON(e)
+	}
+
+	ri := &event.GetRevisionsInput{UID: gi.UID}
+	if gi.RecurrenceID != nil {
+		ri.RecurrenceID = gi.RecurrenceID
+	} else {
+		// the series itself, not every instance sharing its UID
+		series := ""
+		ri.RecurrenceID = &series
+	}
+
+	revisions, err := db.Events.GetRevisions(ri)
+	if err != nil {
+		return fmt.Errorf("failed to get history of %s: %v", gi.UID, err)
+	}
+
+	return printJSON(map[string]interface{}{
+		"event":     e,
+		"revisions": revisions,
+	})
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
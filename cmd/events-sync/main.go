@@ -1,181 +1,159 @@
 package main
 
 import (
-	"errors"
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"time"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/dallasurbanists/events-sync/internal/config"
 	"github.com/dallasurbanists/events-sync/internal/database"
 	"github.com/dallasurbanists/events-sync/internal/importer"
-	"github.com/dallasurbanists/events-sync/pkg/event"
+	"github.com/dallasurbanists/events-sync/internal/syncer"
 )
 
-func main() {
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatalf("No DATABASE_URL given")
-	}
+// command is a subcommand of events-sync. run gets the arguments after the
+// command's name.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) error
+}
 
-	db, err := database.Connect(dbURL)
-	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
-	}
-	defer db.DB.Close()
+// commands is filled in by init, as the commands' flag sets refer back to it
+// for their usage
+var commands []command
 
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatalf("Error loading config: %v", err)
+func init() {
+	commands = []command{
+		{"sync", "sync [-org NAME]... [-concurrency N] [-dry-run [-json FILE]]", "Import and sync organizations' events (the default)", runSync},
+		{"daemon", "daemon [-org NAME]... [-concurrency N] [-shutdown-timeout DURATION]", "Keep running, syncing each organization on its configured interval or schedule", runDaemon},
+		{"validate-config", "validate-config", "Check config.json and the importers' settings without touching the database", runValidateConfig},
+		{"list-events", "list-events [-org NAME] [-uid UID] [-review-status STATUS] [-type TYPE] [-upcoming] [-include-deleted] [-json]", "List stored events", runListEvents},
+		{"show", "show [-history] <uid> [recurrence-id]", "Show a stored event as JSON", runShow},
+		{"prune", "prune -org NAME [-threshold PERCENT]", "Remove an organization's events that its source no longer lists", runPrune},
 	}
+}
 
-	i := importer.RegisterImporters()
-	for orgName, org := range cfg.Organizations {
-		fmt.Printf("Processing organization: %s\n", orgName)
-
-		events, err := i[org.Importer](org.URL, orgName, org.Options)
-		if err != nil {
-			fmt.Printf("Error processing %s: %v\n", orgName, err)
-			continue
-		}
-
-		err = syncEvents(orgName, events, db.Events)
-		if err != nil {
-			log.Fatalf("failed to sync %v: %v", orgName, err)
-		}
+func main() {
+	flag.Usage = usage
 
-		fmt.Printf("Found %d events for %s\n", len(events), orgName)
+	// no command, or only flags, keeps the old behavior of syncing everything
+	args := os.Args[1:]
+	name := "sync"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	err = reportStats(db.Events)
-	if err != nil {
-		log.Fatalf("failed to report stats: %v", err)
+	if name == "help" {
+		usage()
+		return
 	}
-}
-
-func syncEvents(organization string, events []*event.Event, repo event.Repository) error {
-	for _, newEvent := range events {
-		gi := event.GetEventInput{UID: newEvent.UID}
-		if newEvent.RecurrenceID != nil && *newEvent.RecurrenceID != "" {
-			gi.RecurrenceID = newEvent.RecurrenceID
-		}
 
-		// check if event already exists in DB
-		var noEventsError event.NoEventsError
-		existingEvent, err := repo.GetEvent(&gi)
-		if errors.As(err, &noEventsError) {
-			// new event -- insert and move on
-			insertErr := repo.InsertEvent(newEvent)
-			if insertErr != nil {
-				return insertErr
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(args); err != nil {
+				log.Fatalf("%s: %v", c.name, err)
 			}
-
-			continue
-		}
-		if err != nil {
-			return fmt.Errorf("failed to check existing event: %v", err)
+			return
 		}
+	}
 
-		// event exists
-		si := event.SyncEventInput{
-			Summary:      &newEvent.Summary,
-			Description:  newEvent.Description,
-			Location:     newEvent.Location,
-			StartTime:    &newEvent.StartTime,
-			EndTime:      &newEvent.EndTime,
-			Status:       newEvent.Status,
-			Transparency: newEvent.Transparency,
-			Sequence:     &newEvent.Sequence,
-			RRule:        newEvent.RRule,
-			RDate:        newEvent.RDate,
-			ExDate:       newEvent.ExDate,
-		}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
 
-		if hasSignificantChanges(existingEvent, newEvent) {
-			f := false
-			si.Rejected = &f
-		}
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: events-sync <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun events-sync <command> -h for the command's flags.\n")
+}
 
-		err = repo.SyncEvent(&gi, &si)
-		if err != nil {
-			return err
+// newFlagSet returns the flag set for the named command, with usage that
+// prints the command's synopsis
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		for _, c := range commands {
+			if c.name == name {
+				fmt.Fprintf(fs.Output(), "Usage: events-sync %s\n\n%s\n\n", c.usage, c.summary)
+			}
 		}
+		fs.PrintDefaults()
 	}
 
-	pi := event.PruneOrganizationEventsInput{
-		Organization:   organization,
-		ExistingEvents: []event.GetEventInput{},
-	}
+	return fs
+}
 
-	for _, e := range events {
-		i := event.GetEventInput{UID: e.UID}
-		if e.RecurrenceID != nil && *e.RecurrenceID != "" {
-			i.RecurrenceID = e.RecurrenceID
-		}
-		pi.ExistingEvents = append(pi.ExistingEvents, i)
-	}
+// orgList collects -org flags, which may be repeated or comma separated
+type orgList []string
+
+func (o *orgList) String() string {
+	return strings.Join(*o, ",")
+}
 
-	if err := repo.PruneOrganizationEvents(&pi); err != nil {
-		fmt.Printf("Error deleting events not in source for %s: %v\n", organization, err)
+func (o *orgList) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*o = append(*o, name)
+		}
 	}
 
 	return nil
 }
 
-func hasSignificantChanges(existing *event.Event, new *event.Event) bool {
-	if existing.Summary != new.Summary ||
-		existing.Sequence < new.Sequence {
-		return true
+// selectOrganizations returns the named organizations from the config, or
+// all of them when names is empty
+func selectOrganizations(cfg *config.Config, names []string) (map[string]config.Organization, error) {
+	if len(names) == 0 {
+		return cfg.Organizations, nil
 	}
 
-	// Check if time changed (within 1 minute tolerance)
-	if !existing.StartTime.Equal(new.StartTime) {
-		diff := existing.StartTime.Sub(new.StartTime)
-		if diff < -time.Minute || diff > time.Minute {
-			return true
+	orgs := map[string]config.Organization{}
+	for _, name := range names {
+		org, ok := cfg.Organizations[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown organization %q", name)
 		}
+		orgs[name] = org
 	}
 
-	// Check if location changed
-	existingLocation := ""
-	if existing.Location != nil {
-		existingLocation = *existing.Location
-	}
-
-	newLocation := ""
-	if new.Location != nil {
-		newLocation = *new.Location
-	}
+	return orgs, nil
+}
 
-	if existingLocation != newLocation {
-		return true
+func connect() (*database.Store, error) {
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		return nil, fmt.Errorf("no DATABASE_URL given")
 	}
 
-	return false
-}
-
-func reportStats(repo event.Repository) error {
-	events, err := repo.GetEvents(nil)
+	db, err := database.Connect(dbURL)
 	if err != nil {
-		return fmt.Errorf("Warning: Could not load events from database: %v", err)
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
 	}
 
-	fmt.Printf("\nTotal events found: %d\n", len(events))
+	return db, nil
+}
 
-	rejectedEvents := []*event.Event{}
-	nonRejectedEvents := []*event.Event{}
-	for _, e := range events {
-		if e.Rejected {
-			rejectedEvents = append(rejectedEvents, e)
-		} else {
-			nonRejectedEvents = append(nonRejectedEvents, e)
-		}
+func newSyncer(db *database.Store) *syncer.Syncer {
+	return &syncer.Syncer{
+		Events:    db.Events,
+		FeedCache: db.FeedCache,
+		Importers: importer.RegisterImporters(),
+		Client:    importer.NewHTTPClient(),
+		Runs:      db.SyncRuns,
 	}
+}
 
-	fmt.Println("\n=== Rejected Status Summary ===")
-	fmt.Printf("rejected: %d events\n", len(rejectedEvents))
-	fmt.Printf("approved: %d events\n", len(nonRejectedEvents))
-
-	return nil
+// signalContext is canceled on an interrupt or SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+)
+
+func runPrune(args []string) error {
+	fs := newFlagSet("prune")
+	var orgs orgList
+	fs.Var(&orgs, "org", "Organization to prune; repeat or comma separate for several")
+	threshold := fs.Int("threshold", -1, "Largest percentage of an organization's events the prune may remove, 0 for no limit (defaults to the config's prune_threshold)")
+	fs.Parse(args)
+
+	if len(orgs) == 0 {
+		fs.Usage()
+		return fmt.Errorf("-org is required")
+	}
+	if *threshold < -1 || *threshold > 100 {
+		return fmt.Errorf("-threshold must be between 0 and 100, got %d", *threshold)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	selected, err := selectOrganizations(cfg, orgs)
+	if err != nil {
+		return err
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	s := newSyncer(db)
+
+	failed := 0
+	for _, name := range orgs {
+		r := s.PruneOrganization(ctx, name, selected[name], *threshold)
+		switch {
+		case r.Err != nil:
+			fmt.Printf("%s: %v\n", name, r.Err)
+			failed++
+		case r.Skipped:
+			fmt.Printf("%s: skipped, another run is syncing it\n", name)
+		default:
+			fmt.Printf("%s: pruned %d events\n", name, r.Pruned)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d organizations failed to prune", failed, len(orgs))
+	}
+
+	return nil
+}
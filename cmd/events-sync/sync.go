@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+	"github.com/dallasurbanists/events-sync/internal/syncer"
+	"github.com/dallasurbanists/events-sync/pkg/event"
+)
+
+func runSync(args []string) error {
+	fs := newFlagSet("sync")
+	var orgs orgList
+	fs.Var(&orgs, "org", "Organization to sync; repeat or comma separate for several (defaults to all)")
+	concurrency := fs.Int("concurrency", 0, "Number of organizations to sync at once (defaults to the config's concurrency)")
+	dryRun := fs.Bool("dry-run", false, "Run the importers and print what would change without writing to the database")
+	jsonPath := fs.String("json", "", "With -dry-run, also write the diff as JSON to this file")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	selected, err := selectOrganizations(cfg, orgs)
+	if err != nil {
+		return err
+	}
+
+	if *concurrency <= 0 {
+		*concurrency = cfg.Concurrency
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	s := newSyncer(db)
+
+	if *dryRun {
+		diffs := s.DiffAll(ctx, selected, *concurrency)
+		reportDiffs(diffs)
+
+		if *jsonPath != "" {
+			if err := writeDiffsJSON(*jsonPath, diffs); err != nil {
+				return fmt.Errorf("failed to write diff: %v", err)
+			}
+		}
+
+		failed := 0
+		for _, d := range diffs {
+			if d.Error != "" {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d organizations failed", failed, len(diffs))
+		}
+		return nil
+	}
+
+	results := s.SyncAll(ctx, selected, *concurrency)
+	reportResults(results)
+
+	err = reportStats(db.Events)
+	if err != nil {
+		return fmt.Errorf("failed to report stats: %v", err)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d organizations failed to sync", failed, len(results))
+	}
+
+	return nil
+}
+
+func reportResults(results []syncer.Result) {
+	fmt.Println("\n=== Sync Summary ===")
+	fmt.Printf("%-32s %-10s %4s %6s %8s %7s %6s %8s %9s\n", "organization", "status", "http", "found", "inserted", "updated", "pruned", "restored", "duration")
+
+	failed := []syncer.Result{}
+	blocked := []syncer.Result{}
+	unchanged := []string{}
+	skipped := []string{}
+	for _, r := range results {
+		status := "ok"
+		switch {
+		case r.Err != nil:
+			status = "failed"
+			failed = append(failed, r)
+		case r.Unchanged:
+			status = "unchanged"
+			unchanged = append(unchanged, r.Organization)
+		case r.Skipped:
+			status = "skipped"
+			skipped = append(skipped, r.Organization)
+		case r.PruneBlocked != nil:
+			status = "blocked"
+			blocked = append(blocked, r)
+		}
+
+		fmt.Printf("%-32s %-10s %4d %6d %8d %7d %6d %8d %9s\n",
+			r.Organization, status, r.HTTPStatus, r.Found, r.Inserted, r.Updated, r.Pruned, r.Restored,
+			r.Finished.Sub(r.Started).Round(time.Millisecond))
+	}
+
+	if len(blocked) > 0 {
+		fmt.Println("\n=== Blocked Prunes ===")
+		for _, r := range blocked {
+			fmt.Printf("%s: %v\n", r.Organization, r.PruneBlocked)
+		}
+	}
+
+	if len(unchanged) > 0 {
+		fmt.Println("\n=== Unchanged Organizations ===")
+		for _, orgName := range unchanged {
+			fmt.Printf("%s\n", orgName)
+		}
+	}
+
+	if len(skipped) > 0 {
+		fmt.Println("\n=== Skipped Organizations (synced by another run) ===")
+		for _, orgName := range skipped {
+			fmt.Printf("%s\n", orgName)
+		}
+	}
+
+	if len(failed) > 0 {
+		fmt.Println("\n=== Failed Organizations ===")
+		for _, r := range failed {
+			fmt.Printf("%s: %v\n", r.Organization, r.Err)
+		}
+	}
+}
+
+func reportStats(repo event.Repository) error {
+	events, err := repo.GetEvents(nil)
+	if err != nil {
+		return fmt.Errorf("Warning: Could not load events from database: %v", err)
+	}
+
+	fmt.Printf("\nTotal events found: %d\n", len(events))
+
+	counts := map[string]int{}
+	for _, e := range events {
+		counts[e.ReviewStatus]++
+	}
+
+	fmt.Println("\n=== Review Status Summary ===")
+	for _, status := range []string{event.ReviewPending, event.ReviewNeedsReview, event.ReviewApproved, event.ReviewRejected} {
+		fmt.Printf("%s: %d events\n", status, counts[status])
+	}
+
+	return nil
+}
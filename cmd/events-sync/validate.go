@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+	"github.com/dallasurbanists/events-sync/internal/importer"
+)
+
+func runValidateConfig(args []string) error {
+	fs := newFlagSet("validate-config")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	problems := []string{}
+	for _, err := range cfg.Validate() {
+		problems = append(problems, err.Error())
+	}
+
+	importers := importer.RegisterImporters()
+	for name, org := range cfg.Organizations {
+		for _, err := range importers.Validate(importer.NewSource(name, org)) {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	if len(problems) == 0 {
+		fmt.Printf("config is valid: %d organizations\n", len(cfg.Organizations))
+		return nil
+	}
+
+	sort.Strings(problems)
+	fmt.Println("=== Config Problems ===")
+	for _, p := range problems {
+		fmt.Printf("%s\n", p)
+	}
+
+	return fmt.Errorf("found %d problems in the config", len(problems))
+}
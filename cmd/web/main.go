@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/dallasurbanists/events-sync/internal/config"
 	"github.com/dallasurbanists/events-sync/internal/database"
@@ -12,6 +17,9 @@ import (
 
 var GitCommit string
 
+// shutdownTimeout is how long open requests get to finish on SIGTERM
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	var (
 		port  = flag.String("port", "8080", "Port to run the server on")
@@ -47,6 +55,27 @@ func main() {
 		log.Fatalf("Error creating server: %v", err)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// ListenAndServe returns as soon as Shutdown starts, so wait for it to
+	// finish before closing the database
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		log.Printf("Shutting down server")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down server: %v", err)
+		}
+	}()
+
 	log.Printf("Starting server on port %s", *port)
-	log.Fatal(srv.Server.ListenAndServe())
+	if err := srv.Server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
 }
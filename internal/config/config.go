@@ -5,19 +5,231 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/robfig/cron/v3"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
 
+// DefaultTimeout bounds a single organization's import when the
+// organization does not configure its own timeout
+const DefaultTimeout = 60 * time.Second
+
+// DefaultEventLength is how long an event without an end time is assumed to
+// last when the organization does not configure its own length
+const DefaultEventLength = 1 * time.Hour
+
+// DefaultPruneThreshold is the largest percentage of an organization's
+// events a single sync may remove when the organization does not configure
+// its own threshold. Prunes of up to event.PruneFloor events are allowed
+// unless they would remove every event.
+const DefaultPruneThreshold = 50
+
+// DefaultSyncInterval is how often the daemon syncs an organization that
+// configures neither an interval nor a schedule
+const DefaultSyncInterval = 1 * time.Hour
+
+// DefaultTimezone is the zone floating times are read in and the feed is
+// written in when the config sets no timezone
+const DefaultTimezone = "America/Chicago"
+
 type Organization struct {
 	URL      string            `json:"url"`
 	Importer string            `json:"importer"`
 	Options  map[string]string `json:"options,omitempty"`
+	Timeout  Duration          `json:"timeout,omitempty"`
+
+	DefaultEventLength Duration `json:"default_event_length,omitempty"`
+
+	// PruneThreshold is a percentage; 100 allows any prune
+	PruneThreshold int `json:"prune_threshold,omitempty"`
+
+	// PublishPending publishes events awaiting review in the iCal feed
+	// instead of holding them back until approved
+	PublishPending bool `json:"publish_pending,omitempty"`
+
+	// Interval is how often the daemon syncs the organization. Schedule, a
+	// five-field cron expression such as "0 */6 * * *", is used instead when
+	// set; it runs in the server's local time unless prefixed with
+	// "CRON_TZ=America/Chicago " or similar.
+	Interval Duration `json:"interval,omitempty"`
+	Schedule string   `json:"schedule,omitempty"`
+
+	// Timezone is the IANA zone the organization's floating times are read
+	// in, overriding the config's timezone
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// ImportTimeout returns the organization's configured timeout, or
+// DefaultTimeout when none is set
+func (o Organization) ImportTimeout() time.Duration {
+	if o.Timeout.Duration <= 0 {
+		return DefaultTimeout
+	}
+
+	return o.Timeout.Duration
+}
+
+// EventLength returns the organization's configured default event length,
+// or DefaultEventLength when none is set
+func (o Organization) EventLength() time.Duration {
+	if o.DefaultEventLength.Duration <= 0 {
+		return DefaultEventLength
+	}
+
+	return o.DefaultEventLength.Duration
+}
+
+// PruneLimit returns the organization's configured prune threshold, or
+// DefaultPruneThreshold when none is set
+func (o Organization) PruneLimit() int {
+	if o.PruneThreshold <= 0 {
+		return DefaultPruneThreshold
+	}
+
+	return o.PruneThreshold
+}
+
+// SyncSchedule returns when the daemon syncs the organization: its cron
+// schedule, its interval, or DefaultSyncInterval when neither is set
+func (o Organization) SyncSchedule() (cron.Schedule, error) {
+	if o.Schedule != "" {
+		schedule, err := cron.ParseStandard(o.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", o.Schedule, err)
+		}
+		return schedule, nil
+	}
+
+	if o.Interval.Duration <= 0 {
+		return cron.Every(DefaultSyncInterval), nil
+	}
+
+	return cron.Every(o.Interval.Duration), nil
+}
+
+// Duration wraps time.Duration so it can be written as "30s" or "2m" in
+// config.json
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	// null leaves the duration unset, as it would a time.Duration
+	if string(b) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %v", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %v", s, err)
+	}
+
+	d.Duration = parsed
+	return nil
 }
 
 type Config struct {
 	Organizations map[string]Organization `json:"organizations"`
+
+	// Concurrency is how many organizations events-sync imports at once
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// Timezone is the IANA zone of the deployment, DefaultTimezone when
+	// unset. It can be overridden with CONFIG_TIMEZONE.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// Location returns the deployment's zone, falling back to
+// DefaultTimezone when c is nil or sets none
+func (c *Config) Location() (*time.Location, error) {
+	name := DefaultTimezone
+	if c != nil && c.Timezone != "" {
+		name = c.Timezone
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %v", name, err)
+	}
+
+	return loc, nil
+}
+
+// OrganizationLocation returns the zone of the named organization: its own
+// timezone when set, otherwise the deployment's
+func (c *Config) OrganizationLocation(name string) (*time.Location, error) {
+	if c != nil {
+		if org, ok := c.Organizations[name]; ok && org.Timezone != "" {
+			loc, err := time.LoadLocation(org.Timezone)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timezone %q: %v", org.Timezone, err)
+			}
+			return loc, nil
+		}
+	}
+
+	return c.Location()
+}
+
+// Validate checks the settings that don't depend on a particular importer
+// and returns every problem found
+func (c *Config) Validate() []error {
+	errs := []error{}
+
+	if len(c.Organizations) == 0 {
+		errs = append(errs, fmt.Errorf("no organizations configured"))
+	}
+	if c.Concurrency < 0 {
+		errs = append(errs, fmt.Errorf("concurrency must not be negative, got %d", c.Concurrency))
+	}
+	if _, err := c.Location(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for name, org := range c.Organizations {
+		if strings.TrimSpace(name) == "" {
+			errs = append(errs, fmt.Errorf("organization with an empty name"))
+		}
+		if org.Importer == "" {
+			errs = append(errs, fmt.Errorf("%s: no importer set", name))
+		}
+		if org.Timeout.Duration < 0 {
+			errs = append(errs, fmt.Errorf("%s: timeout must not be negative, got %v", name, org.Timeout))
+		}
+		if org.DefaultEventLength.Duration < 0 {
+			errs = append(errs, fmt.Errorf("%s: default_event_length must not be negative, got %v", name, org.DefaultEventLength))
+		}
+		if org.Interval.Duration < 0 {
+			errs = append(errs, fmt.Errorf("%s: interval must not be negative, got %v", name, org.Interval))
+		}
+		if org.Schedule != "" && org.Interval.Duration != 0 {
+			errs = append(errs, fmt.Errorf("%s: set either interval or schedule, not both", name))
+		}
+		if _, err := org.SyncSchedule(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		}
+		if org.PruneThreshold < 0 || org.PruneThreshold > 100 {
+			errs = append(errs, fmt.Errorf("%s: prune_threshold must be a percentage between 0 and 100, got %d", name, org.PruneThreshold))
+		}
+		if org.Timezone != "" && org.Timezone != c.Timezone {
+			if _, err := time.LoadLocation(org.Timezone); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid timezone %q: %v", name, org.Timezone, err))
+			}
+		}
+	}
+
+	return errs
 }
 
 // DiscordConfig holds Discord OAuth configuration from environment variables
@@ -46,9 +258,14 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("error decoding config file: %v", err)
 	}
 
+	if tz := os.Getenv("CONFIG_TIMEZONE"); tz != "" {
+		config.Timezone = tz
+	}
+
 	prefix := "CONFIG_ORGANIZATIONS_"
 	url_suffix := "_URL"
 	importer_suffix := "_IMPORTER"
+	timeout_suffix := "_TIMEOUT"
 	options_prefix := "_OPTIONS_"
 
 	envs := os.Environ()
@@ -72,6 +289,8 @@ func LoadConfig() (*Config, error) {
 						key = optionParts[0]
 						option = strings.ToLower(optionParts[1])
 					}
+				} else if strings.HasSuffix(key, timeout_suffix) {
+					key = strings.TrimSuffix(key, timeout_suffix)
 				} else {
 					continue
 				}
@@ -93,6 +312,12 @@ func LoadConfig() (*Config, error) {
 					org.Importer = parts[1]
 				} else if strings.Contains(original_key, options_prefix) && option != "" {
 					org.Options[option] = parts[1]
+				} else if strings.HasSuffix(original_key, timeout_suffix) {
+					timeout, err := time.ParseDuration(parts[1])
+					if err != nil {
+						return nil, fmt.Errorf("invalid timeout in %v: %v", original_key, err)
+					}
+					org.Timeout = Duration{timeout}
 				}
 
 				config.Organizations[key] = org
@@ -100,6 +325,14 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	// organizations without a timezone of their own share the deployment's
+	for name, org := range config.Organizations {
+		if org.Timezone == "" {
+			org.Timezone = config.Timezone
+			config.Organizations[name] = org
+		}
+	}
+
 	return &config, nil
 }
 
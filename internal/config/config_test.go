@@ -0,0 +1,229 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestDurationUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json   string
+		want   time.Duration
+		hasErr bool
+	}{
+		{json: `"30s"`, want: 30 * time.Second},
+		{json: `"1h30m"`, want: 90 * time.Minute},
+		{json: `"0s"`, want: 0},
+		{json: `"-5m"`, want: -5 * time.Minute},
+		{json: `30`, hasErr: true},
+		{json: `"30"`, hasErr: true},
+		{json: `"soon"`, hasErr: true},
+		{json: `null`, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var d Duration
+			err := json.Unmarshal([]byte(tt.json), &d)
+			if tt.hasErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", d)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if d.Duration != tt.want {
+				t.Errorf("got %v, want %v", d.Duration, tt.want)
+			}
+		})
+	}
+
+	// round trip through an organization, as config.json is read
+	var org Organization
+	if err := json.Unmarshal([]byte(`{"importer": "ical", "timeout": "45s", "interval": "15m"}`), &org); err != nil {
+		t.Fatal(err)
+	}
+	if org.Timeout.Duration != 45*time.Second || org.Interval.Duration != 15*time.Minute {
+		t.Errorf("got timeout %v, interval %v", org.Timeout, org.Interval)
+	}
+	b, err := json.Marshal(org.Timeout)
+	if err != nil || string(b) != `"45s"` {
+		t.Errorf("Marshal() = %s, %v, want \"45s\"", b, err)
+	}
+}
+
+func TestSyncSchedule(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 7, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		org    Organization
+		want   time.Time
+		hasErr bool
+	}{
+		{
+			name: "default interval",
+			org:  Organization{},
+			want: start.Add(DefaultSyncInterval),
+		},
+		{
+			name: "interval",
+			org:  Organization{Interval: Duration{15 * time.Minute}},
+			want: start.Add(15 * time.Minute),
+		},
+		{
+			name: "schedule",
+			org:  Organization{Schedule: "0 */6 * * *"},
+			want: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "schedule in a time zone",
+			org:  Organization{Schedule: "CRON_TZ=America/Chicago 0 6 * * *"},
+			want: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "invalid schedule",
+			org:    Organization{Schedule: "every day"},
+			hasErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := tt.org.SyncSchedule()
+			if tt.hasErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := schedule.Next(start); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", start, got, tt.want)
+			}
+		})
+	}
+
+	// the scheduler syncs intervals straight away but waits for cron times
+	if schedule, _ := (Organization{Interval: Duration{time.Minute}}).SyncSchedule(); !isConstantDelay(schedule) {
+		t.Error("an interval should be a constant delay schedule")
+	}
+	if schedule, _ := (Organization{Schedule: "0 * * * *"}).SyncSchedule(); isConstantDelay(schedule) {
+		t.Error("a cron schedule shouldn't be a constant delay schedule")
+	}
+}
+
+func isConstantDelay(s cron.Schedule) bool {
+	_, ok := s.(cron.ConstantDelaySchedule)
+	return ok
+}
+
+func TestValidate(t *testing.T) {
+	valid := Organization{URL: "https://example.com/events.ics", Importer: "ical"}
+	with := func(change func(*Organization)) map[string]Organization {
+		org := valid
+		change(&org)
+		return map[string]Organization{"Dallas Urbanists": org}
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   []string
+	}{
+		{
+			name:   "valid",
+			config: Config{Organizations: map[string]Organization{"Dallas Urbanists": valid}, Timezone: "America/Chicago"},
+		},
+		{
+			name:   "no organizations",
+			config: Config{},
+			want:   []string{"no organizations configured"},
+		},
+		{
+			name:   "negative concurrency",
+			config: Config{Organizations: with(func(*Organization) {}), Concurrency: -1},
+			want:   []string{"concurrency must not be negative"},
+		},
+		{
+			name:   "invalid timezone",
+			config: Config{Organizations: with(func(*Organization) {}), Timezone: "America/Nowhere"},
+			want:   []string{`invalid timezone "America/Nowhere"`},
+		},
+		{
+			name:   "empty name",
+			config: Config{Organizations: map[string]Organization{" ": valid}},
+			want:   []string{"organization with an empty name"},
+		},
+		{
+			name:   "no importer",
+			config: Config{Organizations: with(func(o *Organization) { o.Importer = "" })},
+			want:   []string{"Dallas Urbanists: no importer set"},
+		},
+		{
+			name: "negative durations",
+			config: Config{Organizations: with(func(o *Organization) {
+				o.Timeout = Duration{-time.Second}
+				o.DefaultEventLength = Duration{-time.Hour}
+				o.Interval = Duration{-time.Minute}
+			})},
+			want: []string{
+				"Dallas Urbanists: timeout must not be negative",
+				"Dallas Urbanists: default_event_length must not be negative",
+				"Dallas Urbanists: interval must not be negative",
+			},
+		},
+		{
+			name: "interval and schedule",
+			config: Config{Organizations: with(func(o *Organization) {
+				o.Interval = Duration{time.Hour}
+				o.Schedule = "0 * * * *"
+			})},
+			want: []string{"Dallas Urbanists: set either interval or schedule, not both"},
+		},
+		{
+			name:   "invalid schedule",
+			config: Config{Organizations: with(func(o *Organization) { o.Schedule = "hourly-ish" })},
+			want:   []string{`Dallas Urbanists: invalid schedule "hourly-ish"`},
+		},
+		{
+			name:   "prune threshold over 100",
+			config: Config{Organizations: with(func(o *Organization) { o.PruneThreshold = 101 })},
+			want:   []string{"Dallas Urbanists: prune_threshold must be a percentage between 0 and 100"},
+		},
+		{
+			name:   "invalid organization timezone",
+			config: Config{Organizations: with(func(o *Organization) { o.Timezone = "Mars/Olympus_Mons" })},
+			want:   []string{`Dallas Urbanists: invalid timezone "Mars/Olympus_Mons"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.config.Validate()
+			if len(errs) != len(tt.want) {
+				t.Fatalf("got %d errors %v, want %d", len(errs), errs, len(tt.want))
+			}
+
+			for _, want := range tt.want {
+				found := false
+				for _, err := range errs {
+					if strings.Contains(err.Error(), want) {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("no error containing %q in %v", want, errs)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
 	"reflect"
 	"strings"
@@ -8,6 +9,8 @@ import (
 
 	"github.com/dallasurbanists/events-sync/pkg/discord"
 	"github.com/dallasurbanists/events-sync/pkg/event"
+	"github.com/dallasurbanists/events-sync/pkg/feed"
+	"github.com/dallasurbanists/events-sync/pkg/syncrun"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
@@ -16,6 +19,8 @@ type Store struct {
 	*sqlx.DB
 	Events                    event.Repository
 	AuthenticatedDiscordUsers discord.UserRepository
+	FeedCache                 feed.Repository
+	SyncRuns                  syncrun.Repository
 }
 
 type DB struct {
@@ -36,11 +41,23 @@ func Connect(connStr string) (*Store, error) {
 
 	return &Store{
 		db,
-		&EventRepository{db},
+		&EventRepository{queryer: db, db: db},
 		&AuthenticatedDiscordUserRepository{db},
+		&FeedCacheRepository{db},
+		&SyncRunRepository{db},
 	}, nil
 }
 
+// queryer is the part of sqlx shared by *sqlx.DB and *sqlx.Tx, so a
+// repository can run the same queries inside or outside a transaction
+type queryer interface {
+	sqlx.Ext
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	NamedQuery(query string, arg interface{}) (*sqlx.Rows, error)
+	NamedExec(query string, arg interface{}) (sql.Result, error)
+}
+
 var columnsCache sync.Map // map[reflect.Type]string
 
 func DBColumns[T any]() string {
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/pkg/event"
+)
+
+// EventRevision represents a row of event_revisions
+type EventRevision struct {
+	ID        int       `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+
+	UID          string  `db:"uid"`
+	RecurrenceID string  `db:"recurrence_id"`
+	Action       string  `db:"action"`
+	ActorType    string  `db:"actor_type"`
+	ActorID      string  `db:"actor_id"`
+	ActorName    *string `db:"actor_name"`
+	Changes      string  `db:"changes"`
+}
+
+func marshalRevision(d *EventRevision) (*event.Revision, error) {
+	r := event.Revision{
+		ID:           d.ID,
+		UID:          d.UID,
+		RecurrenceID: d.RecurrenceID,
+		Action:       d.Action,
+		Actor: event.Actor{
+			Type: d.ActorType,
+			ID:   d.ActorID,
+		},
+		Created: d.CreatedAt,
+	}
+
+	if d.ActorName != nil {
+		r.Actor.Name = *d.ActorName
+	}
+
+	if err := json.Unmarshal([]byte(d.Changes), &r.Changes); err != nil {
+		return nil, fmt.Errorf("failed to decode changes of revision %d: %v", d.ID, err)
+	}
+
+	return &r, nil
+}
+
+// withRevision runs update in a transaction and records the difference it
+// made to the event identified by gi. The event may not exist before the
+// update (an insert) but must exist after it.
+func (db *EventRepository) withRevision(gi *event.GetEventInput, actor event.Actor, action string, update func(*EventRepository) error) error {
+	// pruning and restoring change whether the event is deleted
+	gi = &event.GetEventInput{UID: gi.UID, RecurrenceID: gi.RecurrenceID, IncludeDeleted: true}
+
+	return db.inTx(func(tx *EventRepository) error {
+		var noEventsError event.NoEventsError
+		before, err := tx.GetEvent(gi)
+		if err != nil && !errors.As(err, &noEventsError) {
+			return fmt.Errorf("failed to load event before %s: %v", action, err)
+		}
+
+		if err := update(tx); err != nil {
+			return err
+		}
+
+		after, err := tx.GetEvent(gi)
+		if err != nil {
+			return fmt.Errorf("failed to load event after %s: %v", action, err)
+		}
+
+		return tx.insertRevision(before, after, actor, action)
+	})
+}
+
+const insertRevisionQuery = `
+  INSERT INTO event_revisions (
+    uid, recurrence_id, action,
+    actor_type, actor_id, actor_name,
+    changes
+  ) VALUES (
+    :uid, :recurrence_id, :action,
+    :actor_type, :actor_id, :actor_name,
+    :changes
+  )
+`
+
+func (db *EventRepository) insertRevision(before, after *event.Event, actor event.Actor, action string) error {
+	d, err := newRevision(before, after, actor, action)
+	if err != nil || d == nil {
+		return err
+	}
+
+	_, err = db.NamedExec(insertRevisionQuery, d)
+	if err != nil {
+		return fmt.Errorf("failed to record revision: %v", err)
+	}
+
+	return nil
+}
+
+// insertRevisions records a batch of revisions in one statement
+func (db *EventRepository) insertRevisions(revisions []*EventRevision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	_, err := db.NamedExec(insertRevisionQuery, revisions)
+	if err != nil {
+		return fmt.Errorf("failed to record revisions: %v", err)
+	}
+
+	return nil
+}
+
+// newRevision builds the revision row for a change to an event, or returns
+// nil if nothing changed
+func newRevision(before, after *event.Event, actor event.Actor, action string) (*EventRevision, error) {
+	changes, err := event.Diff(before, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff event %s: %v", after.UID, err)
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode changes: %v", err)
+	}
+
+	if actor.Type == "" {
+		actor.Type = event.ActorSystem
+	}
+
+	d := EventRevision{
+		UID:       after.UID,
+		Action:    action,
+		ActorType: actor.Type,
+		ActorID:   actor.ID,
+		Changes:   string(b),
+	}
+	if after.RecurrenceID != nil {
+		d.RecurrenceID = *after.RecurrenceID
+	}
+	if actor.Name != "" {
+		d.ActorName = &actor.Name
+	}
+
+	return &d, nil
+}
+
+func (db *EventRepository) GetRevisions(i *event.GetRevisionsInput) ([]*event.Revision, error) {
+	query := fmt.Sprintf("SELECT %v FROM event_revisions WHERE uid = $1 ", DBColumns[EventRevision]())
+	args := []interface{}{i.UID}
+
+	if i.RecurrenceID != nil {
+		args = append(args, *i.RecurrenceID)
+		query += fmt.Sprintf("AND recurrence_id = $%d ", len(args))
+	}
+
+	query += "ORDER BY id DESC"
+
+	var rows []*EventRevision
+	if err := db.Select(&rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get revisions: %v", err)
+	}
+
+	revisions := []*event.Revision{}
+	for _, row := range rows {
+		r, err := marshalRevision(row)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	return revisions, nil
+}
+
+func (db *EventRepository) GetRevision(id int) (*event.Revision, error) {
+	query := fmt.Sprintf("SELECT %v FROM event_revisions WHERE id = $1", DBColumns[EventRevision]())
+
+	var row EventRevision
+	err := db.Get(&row, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get revision %d: %v", id, err)
+	}
+
+	return marshalRevision(&row)
+}
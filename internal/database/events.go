@@ -5,15 +5,67 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/dallasurbanists/events-sync/pkg/event"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type EventRepository struct {
-	*sqlx.DB
+	queryer
+
+	// db is nil when the repository is bound to a transaction
+	db *sqlx.DB
+}
+
+func (r *EventRepository) Transaction(fn func(event.Repository) error) error {
+	return r.inTx(func(tx *EventRepository) error {
+		return fn(tx)
+	})
+}
+
+func (r *EventRepository) inTx(fn func(*EventRepository) error) error {
+	// already inside a transaction, join it
+	if r.db == nil {
+		return fn(r)
+	}
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	// a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	if err := fn(&EventRepository{queryer: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// syncLockClass namespaces the advisory locks taken by TryLockOrganization,
+// keeping them apart from any other advisory locks on the database
+const syncLockClass = 1701
+
+func (r *EventRepository) TryLockOrganization(organization string) (bool, error) {
+	if r.db != nil {
+		return false, fmt.Errorf("organization locks can only be taken inside a transaction")
+	}
+
+	var locked bool
+	err := r.Get(&locked, "SELECT pg_try_advisory_xact_lock($1, hashtext($2))", syncLockClass, organization)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock organization %s: %v", organization, err)
+	}
+
+	return locked, nil
 }
 
 // Event represents an event in the database
@@ -29,11 +81,18 @@ type Event struct {
 	Location     *string    `db:"location"`
 	StartTime    time.Time  `db:"start_time"`
 	EndTime      time.Time  `db:"end_time"`
+	AllDay       bool       `db:"all_day"`
+	TZID         *string    `db:"tzid"`
 	CreatedTime  *time.Time `db:"created_time"`
 	ModifiedTime *time.Time `db:"modified_time"`
+	DeletedAt    *time.Time `db:"deleted_at"`
 	Status       *string    `db:"status"`
 	Transparency *string    `db:"transparency"`
-	Rejected     bool       `db:"rejected"`
+	URL          *string    `db:"url"`
+	ReviewStatus string     `db:"review_status"`
+	ReviewedBy   *string    `db:"reviewed_by"`
+	ReviewedByID *string    `db:"reviewed_by_id"`
+	ReviewedAt   *time.Time `db:"reviewed_at"`
 	Sequence     int        `db:"sequence"`
 	RecurrenceID *string    `db:"recurrence_id"`
 	RRule        *string    `db:"rrule"`
@@ -42,6 +101,9 @@ type Event struct {
 	ExDateManual *string    `db:"exdate_manual"`
 	Type         string     `db:"type"`
 	Overlay      *string    `db:"overlay"`
+	Fingerprint  *string    `db:"fingerprint"`
+
+	OccurrenceOverlays *string `db:"occurrence_overlays"`
 }
 
 func marshal(d *Event) *event.Event {
@@ -53,11 +115,19 @@ func marshal(d *Event) *event.Event {
 		Location:     d.Location,
 		StartTime:    d.StartTime,
 		EndTime:      d.EndTime,
+		AllDay:       d.AllDay,
+		TZID:         d.TZID,
 		Created:      d.CreatedTime,
 		Modified:     d.ModifiedTime,
-		Rejected:     d.Rejected,
+		DeletedAt:    d.DeletedAt,
+		ReviewStatus: d.ReviewStatus,
+		ReviewedBy:   d.ReviewedBy,
+		ReviewedByID: d.ReviewedByID,
+		ReviewedAt:   d.ReviewedAt,
+		Rejected:     d.ReviewStatus == event.ReviewRejected,
 		Status:       d.Status,
 		Transparency: d.Transparency,
+		URL:          d.URL,
 		Sequence:     d.Sequence,
 		RecurrenceID: d.RecurrenceID,
 		RRule:        d.RRule,
@@ -65,6 +135,7 @@ func marshal(d *Event) *event.Event {
 		ExDate:       d.ExDate,
 		ExDateManual: d.ExDateManual,
 		Type:         d.Type,
+		Fingerprint:  d.Fingerprint,
 	}
 
 	// Handle overlay JSON conversion
@@ -74,6 +145,12 @@ func marshal(d *Event) *event.Event {
 			e.Overlay = overlay
 		}
 	}
+	if d.OccurrenceOverlays != nil && *d.OccurrenceOverlays != "" {
+		var overlays map[string]map[string]event.EventOverlay
+		if err := json.Unmarshal([]byte(*d.OccurrenceOverlays), &overlays); err == nil {
+			e.OccurrenceOverlays = overlays
+		}
+	}
 
 	return &e
 }
@@ -87,18 +164,33 @@ func unmarshal(e *event.Event) *Event {
 		Location:     e.Location,
 		StartTime:    e.StartTime,
 		EndTime:      e.EndTime,
+		AllDay:       e.AllDay,
+		TZID:         e.TZID,
 		CreatedTime:  e.Created,
 		ModifiedTime: e.Modified,
+		DeletedAt:    e.DeletedAt,
 		Status:       e.Status,
 		Transparency: e.Transparency,
+		URL:          e.URL,
 		Sequence:     e.Sequence,
 		RecurrenceID: e.RecurrenceID,
 		RRule:        e.RRule,
 		RDate:        e.RDate,
 		ExDate:       e.ExDate,
 		ExDateManual: e.ExDateManual,
-		Rejected:     e.Rejected,
+		ReviewStatus: e.ReviewStatus,
+		ReviewedBy:   e.ReviewedBy,
+		ReviewedByID: e.ReviewedByID,
+		ReviewedAt:   e.ReviewedAt,
 		Type:         e.Type,
+		Fingerprint:  e.Fingerprint,
+	}
+
+	if d.ReviewStatus == "" {
+		d.ReviewStatus = event.ReviewPending
+		if e.Rejected {
+			d.ReviewStatus = event.ReviewRejected
+		}
 	}
 
 	empty := ""
@@ -114,6 +206,13 @@ func unmarshal(e *event.Event) *Event {
 			d.Overlay = &overlayStr
 		}
 	}
+	if len(e.OccurrenceOverlays) > 0 {
+		overlaysJSON, err := json.Marshal(e.OccurrenceOverlays)
+		if err == nil {
+			overlaysStr := string(overlaysJSON)
+			d.OccurrenceOverlays = &overlaysStr
+		}
+	}
 
 	return &d
 }
@@ -122,31 +221,37 @@ const insertEventQuery = `
   INSERT INTO events (
     uid, organization,
     summary, description,
-    location, start_time, end_time,
+    location, start_time, end_time, all_day, tzid,
     created_time, modified_time,
-    status, transparency, sequence,
+    status, transparency, url, sequence,
     recurrence_id, rrule, rdate, exdate, exdate_manual,
-    rejected, type, overlay
+    review_status, type, overlay, fingerprint
   ) VALUES (
     :uid, :organization,
     :summary, :description,
-    :location, :start_time, :end_time,
+    :location, :start_time, :end_time, :all_day, :tzid,
     :created_time, :modified_time,
-    :status, :transparency, :sequence,
+    :status, :transparency, :url, :sequence,
     :recurrence_id, :rrule, :rdate, :exdate, :exdate_manual,
-    :rejected, :type, :overlay
+    :review_status, :type, :overlay, :fingerprint
   )
 `
 
-func (db *EventRepository) InsertEvent(e *event.Event) error {
-	d := unmarshal(e)
-	rows, err := db.NamedQuery(insertEventQuery, d)
-	if err != nil {
-		return fmt.Errorf("failed to insert event: %v", err)
-	}
-	defer rows.Close()
+func (db *EventRepository) InsertEvent(e *event.Event, actor event.Actor) error {
+	gi := &event.GetEventInput{UID: e.UID, RecurrenceID: e.RecurrenceID}
 
-	return nil
+	return db.withRevision(gi, actor, event.ActionInsert, func(tx *EventRepository) error {
+		d := unmarshal(e)
+		fingerprint := e.SourceFingerprint()
+		d.Fingerprint = &fingerprint
+		rows, err := tx.NamedQuery(insertEventQuery, d)
+		if err != nil {
+			return fmt.Errorf("failed to insert event: %v", err)
+		}
+		defer rows.Close()
+
+		return nil
+	})
 }
 
 func (db *EventRepository) GetEvent(i *event.GetEventInput) (*event.Event, error) {
@@ -156,6 +261,12 @@ func (db *EventRepository) GetEvent(i *event.GetEventInput) (*event.Event, error
 			uid = $1 AND
 			recurrence_id = $2
 	`, DBColumns[Event]())
+	if !i.IncludeDeleted {
+		getEventQuery += "AND deleted_at IS NULL"
+	}
+	if i.ForUpdate {
+		getEventQuery += " FOR UPDATE"
+	}
 
 	existing := &Event{}
 
@@ -182,9 +293,14 @@ func (db *EventRepository) GetEvents(i *event.GetEventsInput) ([]*event.Event, e
 
 	var dbEvents []*Event
 
-	if i != nil {
-		filterPrefix := "WHERE"
+	filterPrefix := "WHERE"
+
+	if i == nil || !i.IncludeDeleted {
+		getEventQuery += fmt.Sprintf("%v deleted_at IS NULL ", filterPrefix)
+		filterPrefix = "AND"
+	}
 
+	if i != nil {
 		if i.UID != nil {
 			idx++
 			getEventQuery += fmt.Sprintf("%v uid = $%d ", filterPrefix, idx)
@@ -192,10 +308,10 @@ func (db *EventRepository) GetEvents(i *event.GetEventsInput) ([]*event.Event, e
 			filterPrefix = "AND"
 		}
 
-		if i.Rejected != nil {
+		if i.ReviewStatus != nil {
 			idx++
-			getEventQuery += fmt.Sprintf("%v rejected = $%d ", filterPrefix, idx)
-			args = append(args, i.Rejected)
+			getEventQuery += fmt.Sprintf("%v review_status = $%d ", filterPrefix, idx)
+			args = append(args, i.ReviewStatus)
 			filterPrefix = "AND"
 		}
 
@@ -214,8 +330,12 @@ func (db *EventRepository) GetEvents(i *event.GetEventsInput) ([]*event.Event, e
 		}
 
 		if i.UpcomingOnly {
-			getEventQuery += fmt.Sprintf("%v start_time > NOW() ", filterPrefix)
-			filterPrefix = "AND"
+			// every row of a series is kept, since its root may have started
+			// long ago and its overrides are needed to expand it
+			getEventQuery += fmt.Sprintf(`%v (start_time > NOW() OR uid IN (
+				SELECT uid FROM events
+				WHERE deleted_at IS NULL AND (COALESCE(rrule, '') <> '' OR COALESCE(rdate, '') <> '')
+			)) `, filterPrefix)
 		}
 	}
 
@@ -237,31 +357,54 @@ func (db *EventRepository) GetEvents(i *event.GetEventsInput) ([]*event.Event, e
 }
 
 func (db *EventRepository) PatchEvent(gi *event.GetEventInput, pi *event.PatchEventInput) error {
-	updateQuery := "UPDATE events SET "
-	args := []interface{}{}
-
 	if pi == nil {
 		return errors.New("failed to patch event, no patch input given")
 	}
 
+	action := pi.Action
+	if action == "" {
+		action = event.ActionPatch
+	}
+
+	return db.withRevision(gi, pi.Actor, action, func(tx *EventRepository) error {
+		return tx.patchEvent(gi, pi)
+	})
+}
+
+func (db *EventRepository) patchEvent(gi *event.GetEventInput, pi *event.PatchEventInput) error {
+	updateQuery := "UPDATE events SET "
+	args := []interface{}{}
+
+	updatePrefix := ""
+
 	if pi.Organization != nil {
 		args = append(args, *pi.Organization)
-		updateQuery += fmt.Sprintf("organization = $%d ", len(args))
+		updateQuery += fmt.Sprintf("%v organization = $%d ", updatePrefix, len(args))
+		updatePrefix = ","
 	}
 
-	if pi.Rejected != nil {
-		args = append(args, *pi.Rejected)
-		updateQuery += fmt.Sprintf("rejected = $%d ", len(args))
+	if pi.ReviewStatus != nil {
+		reviewer := pi.Actor.Name
+		if reviewer == "" {
+			reviewer = pi.Actor.ID
+		}
+
+		args = append(args, *pi.ReviewStatus, reviewer, pi.Actor.ID)
+		updateQuery += fmt.Sprintf("%v review_status = $%d, reviewed_by = $%d, reviewed_by_id = $%d, reviewed_at = NOW() ",
+			updatePrefix, len(args)-2, len(args)-1, len(args))
+		updatePrefix = ","
 	}
 
 	if pi.Type != nil {
 		args = append(args, *pi.Type)
-		updateQuery += fmt.Sprintf("type = $%d ", len(args))
+		updateQuery += fmt.Sprintf("%v type = $%d ", updatePrefix, len(args))
+		updatePrefix = ","
 	}
 
 	if pi.ExDateManual != nil {
 		args = append(args, *pi.ExDateManual)
-		updateQuery += fmt.Sprintf("exdate_manual = $%d ", len(args))
+		updateQuery += fmt.Sprintf("%v exdate_manual = $%d ", updatePrefix, len(args))
+		updatePrefix = ","
 	}
 
 	if pi.Overlay != nil {
@@ -270,7 +413,22 @@ func (db *EventRepository) PatchEvent(gi *event.GetEventInput, pi *event.PatchEv
 			return fmt.Errorf("failed to marshal overlay: %v", err)
 		}
 		args = append(args, string(overlayJSON))
-		updateQuery += fmt.Sprintf("overlay = $%d ", len(args))
+		updateQuery += fmt.Sprintf("%v overlay = $%d ", updatePrefix, len(args))
+		updatePrefix = ","
+	}
+
+	if pi.OccurrenceOverlays != nil {
+		overlaysJSON, err := json.Marshal(pi.OccurrenceOverlays)
+		if err != nil {
+			return fmt.Errorf("failed to marshal occurrence overlays: %v", err)
+		}
+		args = append(args, string(overlaysJSON))
+		updateQuery += fmt.Sprintf("%v occurrence_overlays = $%d ", updatePrefix, len(args))
+		updatePrefix = ","
+	}
+
+	if updatePrefix == "" {
+		return errors.New("failed to patch event, no fields given")
 	}
 
 	args = append(args, gi.UID)
@@ -287,99 +445,204 @@ func (db *EventRepository) PatchEvent(gi *event.GetEventInput, pi *event.PatchEv
 	return err
 }
 
-func (db *EventRepository) SyncEvent(gi *event.GetEventInput, si *event.SyncEventInput) error {
-	updateQuery := "UPDATE events SET "
-	args := []interface{}{}
+// upsertBatchSize bounds the rows written per statement, keeping each well
+// under Postgres' limit of 65535 parameters
+const upsertBatchSize = 500
+
+// upsertSet assigns each of event.SyncColumns only when its value changed
+func upsertSet() string {
+	set := []string{}
+	for _, c := range event.SyncColumns {
+		changed := fmt.Sprintf("events.%[1]v IS DISTINCT FROM EXCLUDED.%[1]v", c.Name)
+		if c.Coalesce {
+			changed = fmt.Sprintf("EXCLUDED.%v IS NOT NULL AND %v", c.Name, changed)
+		}
+		set = append(set, fmt.Sprintf("%[1]v = CASE WHEN %[2]v THEN EXCLUDED.%[1]v ELSE events.%[1]v END", c.Name, changed))
+	}
 
-	updatePrefix := ""
+	return strings.Join(set, ",\n    ")
+}
 
-	if si.Summary != nil {
-		args = append(args, si.Summary)
-		updateQuery += fmt.Sprintf("%v summary = $%d ", updatePrefix, len(args))
-		updatePrefix = ","
+// upsertEventQuery inserts new events and, for existing ones whose source
+// version changed or that were pruned, writes only the source fields that
+// changed. Rows with the same fingerprint are left alone and not returned.
+// review_status is sent as needs_review when the change is significant.
+var upsertEventQuery = insertEventQuery + fmt.Sprintf(`
+  ON CONFLICT (uid, recurrence_id) DO UPDATE SET
+    %[5]v,
+    review_status = CASE
+      WHEN EXCLUDED.review_status = '%[1]v' AND events.review_status IN ('%[2]v', '%[3]v') THEN '%[1]v'
+      ELSE events.review_status
+    END,
+    deleted_at = NULL
+  WHERE events.fingerprint IS DISTINCT FROM EXCLUDED.fingerprint OR events.deleted_at IS NOT NULL
+  RETURNING %[4]v
+`, event.ReviewNeedsReview, event.ReviewApproved, event.ReviewRejected, DBColumns[Event](), upsertSet())
+
+// UpsertEvents inserts new events and updates changed ones in batches,
+// skipping the events whose source version is already stored
+func (db *EventRepository) UpsertEvents(ui *event.UpsertEventsInput) (*event.UpsertEventsResult, error) {
+	result := &event.UpsertEventsResult{}
+
+	// a statement can't touch the same row twice, so a feed listing an
+	// event more than once keeps the last copy, as syncing one at a time did
+	index := map[string]int{}
+	events := []*event.Event{}
+	for _, e := range ui.Events {
+		key := eventKey(e.UID, e.RecurrenceID)
+		if i, ok := index[key]; ok {
+			events[i] = e
+			continue
+		}
+		index[key] = len(events)
+		events = append(events, e)
 	}
 
-	if si.Description != nil {
-		args = append(args, si.Description)
-		updateQuery += fmt.Sprintf("%v description = $%d ", updatePrefix, len(args))
-		updatePrefix = ","
+	err := db.inTx(func(tx *EventRepository) error {
+		for start := 0; start < len(events); start += upsertBatchSize {
+			end := min(start+upsertBatchSize, len(events))
+			if err := tx.upsertEvents(events[start:end], ui.Actor, result); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if si.Location != nil {
-		args = append(args, si.Location)
-		updateQuery += fmt.Sprintf("%v location = $%d ", updatePrefix, len(args))
-		updatePrefix = ","
-	}
+	return result, nil
+}
 
-	if si.StartTime != nil {
-		args = append(args, si.StartTime)
-		updateQuery += fmt.Sprintf("%v start_time = $%d ", updatePrefix, len(args))
-		updatePrefix = ","
+func (db *EventRepository) upsertEvents(events []*event.Event, actor event.Actor, result *event.UpsertEventsResult) error {
+	existing, err := db.getEventsByKey(events)
+	if err != nil {
+		return err
 	}
 
-	if si.EndTime != nil {
-		args = append(args, si.EndTime)
-		updateQuery += fmt.Sprintf("%v end_time = $%d ", updatePrefix, len(args))
-		updatePrefix = ","
-	}
+	rows := make([]*Event, 0, len(events))
+	sent := map[string]bool{}
+	for _, e := range events {
+		key := eventKey(e.UID, e.RecurrenceID)
+		before := existing[key]
 
-	if si.Rejected != nil {
-		args = append(args, si.Rejected)
-		updateQuery += fmt.Sprintf("%v rejected = $%d ", updatePrefix, len(args))
-		updatePrefix = ","
+		fingerprint := e.SourceFingerprint()
+		if !event.NeedsSync(before, fingerprint) {
+			result.Count(before, false, false)
+			continue
+		}
+
+		d := unmarshal(e)
+		d.Fingerprint = &fingerprint
+		d.ReviewStatus = event.ReviewPending
+		if before != nil && len(event.SignificantChanges(before, e)) > 0 {
+			d.ReviewStatus = event.ReviewNeedsReview
+		}
+		rows = append(rows, d)
+		sent[key] = true
+	}
+	if len(rows) == 0 {
+		return nil
 	}
 
-	if si.Status != nil {
-		args = append(args, si.Status)
-		updateQuery += fmt.Sprintf("%v status = $%d ", updatePrefix, len(args))
-		updatePrefix = ","
+	upserted, err := db.NamedQuery(upsertEventQuery, rows)
+	if err != nil {
+		return fmt.Errorf("failed to upsert events: %v", err)
 	}
+	defer upserted.Close()
 
-	if si.Transparency != nil {
-		args = append(args, si.Transparency)
-		updateQuery += fmt.Sprintf("%v transparency = $%d ", updatePrefix, len(args))
-		updatePrefix = ","
+	revisions := []*EventRevision{}
+	for upserted.Next() {
+		var d Event
+		if err := upserted.StructScan(&d); err != nil {
+			return fmt.Errorf("failed to read upserted event: %v", err)
+		}
+		after := marshal(&d)
+
+		key := eventKey(after.UID, after.RecurrenceID)
+		delete(sent, key)
+
+		before := existing[key]
+		action := event.ActionSync
+		if before == nil {
+			action = event.ActionInsert
+		}
+
+		r, err := newRevision(before, after, actor, action)
+		if err != nil {
+			return err
+		}
+
+		// a write that only set the fingerprint, as for events stored
+		// before it, has no revision and counts as unchanged
+		result.Count(before, true, r != nil)
+
+		if r != nil {
+			revisions = append(revisions, r)
+		}
+	}
+	if err := upserted.Err(); err != nil {
+		return fmt.Errorf("failed to upsert events: %v", err)
 	}
+	upserted.Close()
 
-	if si.Sequence != nil {
-		args = append(args, si.Sequence)
-		updateQuery += fmt.Sprintf("%v sequence = $%d ", updatePrefix, len(args))
-		updatePrefix = ","
+	// rows whose fingerprint matched by the time they were written weren't
+	// updated, so weren't returned
+	for key := range sent {
+		result.Count(existing[key], false, false)
 	}
 
-	if si.RRule != nil {
-		args = append(args, si.RRule)
-		updateQuery += fmt.Sprintf("%v rrule = $%d ", updatePrefix, len(args))
-		updatePrefix = ","
+	return db.insertRevisions(revisions)
+}
+
+// getEventsByKey loads the stored versions of events in one query, deleted
+// or not, keyed by eventKey
+func (db *EventRepository) getEventsByKey(events []*event.Event) (map[string]*event.Event, error) {
+	uids := make([]string, 0, len(events))
+	recurrenceIDs := make([]string, 0, len(events))
+	for _, e := range events {
+		uids = append(uids, e.UID)
+		recurrenceID := ""
+		if e.RecurrenceID != nil {
+			recurrenceID = *e.RecurrenceID
+		}
+		recurrenceIDs = append(recurrenceIDs, recurrenceID)
 	}
 
-	if si.RDate != nil {
-		args = append(args, si.RDate)
-		updateQuery += fmt.Sprintf("%v rdate = $%d ", updatePrefix, len(args))
-		updatePrefix = ","
+	query := fmt.Sprintf(`
+		SELECT %v FROM events
+		WHERE (uid, recurrence_id) IN (SELECT * FROM unnest($1::text[], $2::text[]))
+	`, DBColumns[Event]())
+
+	var rows []*Event
+	if err := db.Select(&rows, query, pq.Array(uids), pq.Array(recurrenceIDs)); err != nil {
+		return nil, fmt.Errorf("failed to get existing events: %v", err)
 	}
 
-	if si.ExDate != nil {
-		args = append(args, si.ExDate)
-		updateQuery += fmt.Sprintf("%v exdate = $%d ", updatePrefix, len(args))
-		updatePrefix = ","
+	existing := map[string]*event.Event{}
+	for _, d := range rows {
+		e := marshal(d)
+		existing[eventKey(e.UID, e.RecurrenceID)] = e
 	}
 
-	args = append(args, gi.UID)
-	updateQuery += fmt.Sprintf("WHERE uid = $%d ", len(args))
+	return existing, nil
+}
 
-	if gi.RecurrenceID != nil {
-		args = append(args, gi.RecurrenceID)
-	} else {
-		args = append(args, "")
+// eventKey identifies an event by its primary key
+func eventKey(uid string, recurrenceID *string) string {
+	if recurrenceID == nil {
+		return uid + ":"
 	}
-	updateQuery += fmt.Sprintf("AND recurrence_id = $%d ", len(args))
 
-	_, err := db.Exec(updateQuery, args...)
-	return err
+	return uid + ":" + *recurrenceID
 }
 
-func (db *EventRepository) PruneOrganizationEvents(pi *event.PruneOrganizationEventsInput) error {
+// PruneOrganizationEvents soft deletes the organization's events that are no
+// longer in its source and returns how many were deleted. Nothing is deleted
+// if event.PruneExceedsThreshold refuses it: when that would remove more
+// than pi.Threshold percent of the organization's events and more than
+// event.PruneFloor, or all of them.
+func (db *EventRepository) PruneOrganizationEvents(pi *event.PruneOrganizationEventsInput) (int, error) {
 	organization := pi.Organization
 	sourceEvents := pi.ExistingEvents
 
@@ -394,9 +657,11 @@ func (db *EventRepository) PruneOrganizationEvents(pi *event.PruneOrganizationEv
 
 	events, err := db.GetEvents(&event.GetEventsInput{Organization: &organization})
 	if err != nil {
-		return fmt.Errorf("failed to get events for organization %s: %v", organization, err)
+		return 0, fmt.Errorf("failed to get events for organization %s: %v", organization, err)
 	}
 
+	deleted := 0
+
 	var eventsToDelete []*event.Event
 	for _, e := range events {
 		key := e.UID
@@ -409,6 +674,15 @@ func (db *EventRepository) PruneOrganizationEvents(pi *event.PruneOrganizationEv
 		}
 	}
 
+	if event.PruneExceedsThreshold(len(eventsToDelete), len(events), pi.Threshold) {
+		return 0, event.PruneBlockedError{
+			Organization: organization,
+			Pruning:      len(eventsToDelete),
+			Total:        len(events),
+			Threshold:    pi.Threshold,
+		}
+	}
+
 	if len(eventsToDelete) > 0 {
 		fmt.Printf("Deleting %d events for organization %s that are no longer in source calendar:\n", len(eventsToDelete), organization)
 
@@ -427,7 +701,7 @@ func (db *EventRepository) PruneOrganizationEvents(pi *event.PruneOrganizationEv
 		}
 
 		for _, dbEvent := range dbEventsToDelete {
-			deleteQuery := "DELETE FROM events WHERE uid = $1 AND organization = $2 "
+			deleteQuery := "UPDATE events SET deleted_at = NOW() WHERE uid = $1 AND organization = $2 "
 			args := []interface{}{dbEvent.UID, dbEvent.Organization}
 
 			if dbEvent.RecurrenceID != nil {
@@ -437,12 +711,17 @@ func (db *EventRepository) PruneOrganizationEvents(pi *event.PruneOrganizationEv
 			}
 			deleteQuery += fmt.Sprintf("AND recurrence_id = $%d ", len(args))
 
-			_, err := db.Exec(deleteQuery, args...)
+			gi := &event.GetEventInput{UID: dbEvent.UID, RecurrenceID: dbEvent.RecurrenceID}
+			err := db.withRevision(gi, pi.Actor, event.ActionPrune, func(tx *EventRepository) error {
+				_, err := tx.Exec(deleteQuery, args...)
+				return err
+			})
 			if err != nil {
-				fmt.Printf("Error deleting event (%s): %v\n", deleteQuery, err)
+				return deleted, fmt.Errorf("failed to delete event %s: %v", dbEvent.UID, err)
 			}
+			deleted++
 		}
 	}
 
-	return nil
+	return deleted, nil
 }
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/pkg/feed"
+	"github.com/jmoiron/sqlx"
+)
+
+type FeedCacheRepository struct {
+	*sqlx.DB
+}
+
+// FeedCache represents a feed's cached validators in the database
+type FeedCache struct {
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+
+	Organization string     `db:"organization"`
+	URL          string     `db:"url"`
+	ETag         *string    `db:"etag"`
+	LastModified *string    `db:"last_modified"`
+	ContentHash  *string    `db:"content_hash"`
+	CheckedAt    *time.Time `db:"checked_at"`
+
+	ImporterVersion int     `db:"importer_version"`
+	SettingsHash    *string `db:"settings_hash"`
+
+	// Pages is the JSON encoded list of per-page validators
+	Pages *string `db:"pages"`
+}
+
+func (db *FeedCacheRepository) GetValidators(organization string) (*feed.Validators, error) {
+	query := fmt.Sprintf("SELECT %v FROM feed_cache WHERE organization = $1", DBColumns[FeedCache]())
+
+	var c FeedCache
+	err := db.Get(&c, query, organization)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get feed cache for %v: %v", organization, err)
+	}
+
+	v := &feed.Validators{
+		Organization: c.Organization,
+		URL:          c.URL,
+		ETag:         c.ETag,
+		LastModified: c.LastModified,
+		ContentHash:  c.ContentHash,
+		CheckedAt:    c.CheckedAt,
+
+		ImporterVersion: c.ImporterVersion,
+		SettingsHash:    c.SettingsHash,
+	}
+	if c.Pages != nil && *c.Pages != "" {
+		if err := json.Unmarshal([]byte(*c.Pages), &v.Pages); err != nil {
+			return nil, fmt.Errorf("failed to parse feed cache pages for %v: %v", organization, err)
+		}
+	}
+
+	return v, nil
+}
+
+const saveValidatorsQuery = `
+  INSERT INTO feed_cache (
+    organization, url, etag, last_modified, content_hash, checked_at,
+    importer_version, settings_hash, pages
+  ) VALUES (
+    :organization, :url, :etag, :last_modified, :content_hash, :checked_at,
+    :importer_version, :settings_hash, :pages
+  )
+  ON CONFLICT (organization) DO UPDATE SET
+    url = EXCLUDED.url,
+    etag = EXCLUDED.etag,
+    last_modified = EXCLUDED.last_modified,
+    content_hash = EXCLUDED.content_hash,
+    checked_at = EXCLUDED.checked_at,
+    importer_version = EXCLUDED.importer_version,
+    settings_hash = EXCLUDED.settings_hash,
+    pages = EXCLUDED.pages
+`
+
+func (db *FeedCacheRepository) SaveValidators(v *feed.Validators) error {
+	c := FeedCache{
+		Organization: v.Organization,
+		URL:          v.URL,
+		ETag:         v.ETag,
+		LastModified: v.LastModified,
+		ContentHash:  v.ContentHash,
+		CheckedAt:    v.CheckedAt,
+
+		ImporterVersion: v.ImporterVersion,
+		SettingsHash:    v.SettingsHash,
+	}
+	if len(v.Pages) > 0 {
+		b, err := json.Marshal(v.Pages)
+		if err != nil {
+			return fmt.Errorf("failed to encode feed cache pages for %v: %v", v.Organization, err)
+		}
+		pages := string(b)
+		c.Pages = &pages
+	}
+
+	if _, err := db.NamedExec(saveValidatorsQuery, c); err != nil {
+		return fmt.Errorf("failed to save feed cache for %v: %v", v.Organization, err)
+	}
+
+	return nil
+}
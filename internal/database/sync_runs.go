@@ -0,0 +1,152 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/pkg/syncrun"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type SyncRunRepository struct {
+	*sqlx.DB
+}
+
+// SyncRun represents one organization's sync in the database
+type SyncRun struct {
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+
+	ID           string     `db:"id"`
+	RunID        string     `db:"run_id"`
+	Organization string     `db:"organization"`
+	Status       string     `db:"status"`
+	StartedAt    time.Time  `db:"started_at"`
+	FinishedAt   *time.Time `db:"finished_at"`
+	Found        int        `db:"found"`
+	Inserted     int        `db:"inserted"`
+	Updated      int        `db:"updated"`
+	Pruned       int        `db:"pruned"`
+	Restored     int        `db:"restored"`
+	HTTPStatus   *int       `db:"http_status"`
+	Error        *string    `db:"error"`
+}
+
+func marshalSyncRun(d *SyncRun) *syncrun.Run {
+	return &syncrun.Run{
+		ID:           d.ID,
+		RunID:        d.RunID,
+		Organization: d.Organization,
+		Status:       d.Status,
+		StartedAt:    d.StartedAt,
+		FinishedAt:   d.FinishedAt,
+		Found:        d.Found,
+		Inserted:     d.Inserted,
+		Updated:      d.Updated,
+		Pruned:       d.Pruned,
+		Restored:     d.Restored,
+		HTTPStatus:   d.HTTPStatus,
+		Error:        d.Error,
+	}
+}
+
+const saveSyncRunQuery = `
+  INSERT INTO sync_runs (
+    id, run_id, organization, status, started_at, finished_at,
+    found, inserted, updated, pruned, restored,
+    http_status, error
+  ) VALUES (
+    :id, :run_id, :organization, :status, :started_at, :finished_at,
+    :found, :inserted, :updated, :pruned, :restored,
+    :http_status, :error
+  )
+  ON CONFLICT (id) DO UPDATE SET
+    status = EXCLUDED.status,
+    finished_at = EXCLUDED.finished_at,
+    found = EXCLUDED.found,
+    inserted = EXCLUDED.inserted,
+    updated = EXCLUDED.updated,
+    pruned = EXCLUDED.pruned,
+    restored = EXCLUDED.restored,
+    http_status = EXCLUDED.http_status,
+    error = EXCLUDED.error
+`
+
+func (db *SyncRunRepository) SaveRun(r *syncrun.Run) error {
+	d := SyncRun{
+		ID:           r.ID,
+		RunID:        r.RunID,
+		Organization: r.Organization,
+		Status:       r.Status,
+		StartedAt:    r.StartedAt,
+		FinishedAt:   r.FinishedAt,
+		Found:        r.Found,
+		Inserted:     r.Inserted,
+		Updated:      r.Updated,
+		Pruned:       r.Pruned,
+		Restored:     r.Restored,
+		HTTPStatus:   r.HTTPStatus,
+		Error:        r.Error,
+	}
+
+	_, err := db.NamedExec(saveSyncRunQuery, d)
+	if err != nil {
+		return fmt.Errorf("failed to save sync run for %v: %v", r.Organization, err)
+	}
+
+	return nil
+}
+
+func (db *SyncRunRepository) GetRuns(i *syncrun.GetRunsInput) ([]*syncrun.Run, error) {
+	query := fmt.Sprintf("SELECT %v FROM sync_runs ", DBColumns[SyncRun]())
+	args := []interface{}{}
+
+	if i != nil && i.Organization != nil {
+		args = append(args, *i.Organization)
+		query += fmt.Sprintf("WHERE organization = $%d ", len(args))
+	}
+
+	query += "ORDER BY started_at DESC "
+
+	if i != nil && i.Limit > 0 {
+		args = append(args, i.Limit)
+		query += fmt.Sprintf("LIMIT $%d", len(args))
+	}
+
+	var rows []*SyncRun
+	if err := db.Select(&rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get sync runs: %v", err)
+	}
+
+	runs := []*syncrun.Run{}
+	for _, row := range rows {
+		runs = append(runs, marshalSyncRun(row))
+	}
+
+	return runs, nil
+}
+
+func (db *SyncRunRepository) GetLatestRuns(i *syncrun.GetLatestRunsInput) ([]*syncrun.Run, error) {
+	query := fmt.Sprintf("SELECT DISTINCT ON (organization) %v FROM sync_runs ", DBColumns[SyncRun]())
+	args := []interface{}{}
+
+	if i != nil && len(i.Statuses) > 0 {
+		args = append(args, pq.Array(i.Statuses))
+		query += fmt.Sprintf("WHERE status = ANY($%d) ", len(args))
+	}
+
+	query += "ORDER BY organization, started_at DESC"
+
+	var rows []*SyncRun
+	if err := db.Select(&rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get latest sync runs: %v", err)
+	}
+
+	runs := []*syncrun.Run{}
+	for _, row := range rows {
+		runs = append(runs, marshalSyncRun(row))
+	}
+
+	return runs, nil
+}
@@ -1,15 +1,17 @@
 package importer
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
 	_ "time/tzdata"
 
 	"github.com/dallasurbanists/events-sync/pkg/event"
+	"github.com/dallasurbanists/events-sync/pkg/feed"
 )
 
 type ActionNetworkAPIResponse struct {
@@ -47,25 +49,30 @@ type ActionNetworkEvent struct {
 	BrowserURL string `json:"browser_url"`
 }
 
-func action_network_api_importer(url string, organization string, options map[string]string) ([]*event.Event, error) {
+func action_network_api_importer(ctx context.Context, client *http.Client, source Source) ([]*event.Event, error) {
+	organization := source.Organization
+
 	baseURL := "https://actionnetwork.org/api/v2/events"
-	if url != "" {
-		baseURL = url // honestly just keeping this here to match the other importers and for future testing/proofing
+	if source.URL != "" {
+		baseURL = source.URL // honestly just keeping this here to match the other importers and for future testing/proofing
 	}
 
-	apiKey, ok := options["api_key"]
+	apiKey, ok := source.Options["api_key"]
 	if !ok {
 		return nil, fmt.Errorf("Action Network api_key not found in options for organization %s", organization)
 	}
 
-	events, err := fetchActionNetworkEvents(apiKey, baseURL)
+	events, err := fetchActionNetworkEvents(ctx, client, source, apiKey, baseURL)
+	if errors.Is(err, ErrNotModified) {
+		return nil, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch events from Action Network API: %v", err)
+		return nil, fmt.Errorf("failed to fetch events from Action Network API: %w", err)
 	}
 
 	var convertedEvents []*event.Event
 	for _, anEvent := range events {
-		e, err := convertActionNetworkEventToEvent(anEvent, organization)
+		e, err := convertActionNetworkEventToEvent(anEvent, source)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert event %s: %v", anEvent.Title, err)
 		}
@@ -75,88 +82,152 @@ func action_network_api_importer(url string, organization string, options map[st
 	return convertedEvents, nil
 }
 
-func fetchActionNetworkEvents(apiKey string, baseURL string) ([]ActionNetworkEvent, error) {
-	var allEvents []ActionNetworkEvent
-	page := 1
+// fetchActionNetworkEvents walks every page of the events endpoint. Each
+// page is requested conditionally against its own validators in
+// source.Validators.Pages; when every page is unchanged ErrNotModified is
+// returned, and otherwise the pages that were are fetched again in full.
+func fetchActionNetworkEvents(ctx context.Context, client *http.Client, source Source, apiKey string, baseURL string) ([]ActionNetworkEvent, error) {
+	v := source.Validators
+
+	var recorded []feed.Validators
+	if v != nil {
+		recorded = v.Pages
+	}
 
-	for {
+	var pages []feed.Validators
+	var responses []*ActionNetworkAPIResponse
+	modified := false
+
+	for page := 1; ; page++ {
 		url := baseURL
 		if page > 1 {
 			url = fmt.Sprintf("%s?page=%d", baseURL, page)
 		}
 
-		events, hasNext, err := fetchActionNetworkPage(url, apiKey)
-		if err != nil {
+		pv := feed.Validators{Organization: source.Organization}
+		if page <= len(recorded) {
+			pv = recorded[page-1]
+		}
+
+		resp, err := fetchActionNetworkPage(ctx, client, source, &pv, url, apiKey)
+		if err != nil && !errors.Is(err, ErrNotModified) {
 			return nil, err
 		}
 
-		allEvents = append(allEvents, events...)
+		pages = append(pages, pv)
+		responses = append(responses, resp)
+
+		// an unchanged page links to the same next page as last time
+		hasNext := page < len(recorded)
+		if resp != nil {
+			modified = true
+			hasNext = resp.Links.Next.Href != ""
+		}
 
 		if !hasNext {
 			break
 		}
-		page++
+	}
+
+	if v != nil {
+		now := time.Now()
+		settings := source.SettingsHash()
+
+		v.URL = baseURL
+		v.ETag, v.LastModified, v.ContentHash = nil, nil, nil
+		v.CheckedAt = &now
+		v.ImporterVersion = ImporterVersion
+		v.SettingsHash = &settings
+	}
+
+	if !modified && len(pages) == len(recorded) {
+		v.Pages = pages
+		return nil, ErrNotModified
+	}
+
+	var allEvents []ActionNetworkEvent
+	for i, resp := range responses {
+		if resp == nil {
+			// another page changed, so this one's events are needed too
+			url := pages[i].URL
+			pages[i] = feed.Validators{Organization: source.Organization}
+
+			var err error
+			if resp, err = fetchActionNetworkPage(ctx, client, source, &pages[i], url, apiKey); err != nil {
+				return nil, err
+			}
+		}
+
+		allEvents = append(allEvents, resp.Embedded.Events...)
+	}
+
+	if v != nil {
+		v.Pages = pages
 	}
 
 	return allEvents, nil
 }
 
-func fetchActionNetworkPage(url, apiKey string) ([]ActionNetworkEvent, bool, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// fetchActionNetworkPage requests one page with the validators recorded
+// for it, updating them in place like fetch does for a whole feed. It
+// returns ErrNotModified without a response when the page hasn't changed.
+func fetchActionNetworkPage(ctx context.Context, client *http.Client, source Source, v *feed.Validators, url, apiKey string) (*ActionNetworkAPIResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	req.Header.Set("OSDI-API-Token", apiKey)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	source.Validators = v
+	body, err := doConditional(client, req, source)
 	if err != nil {
-		return nil, false, fmt.Errorf("failed to make request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return nil, false, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-	if err != nil {
-		return nil, false, fmt.Errorf("failed to read response body: %v", err)
+		return nil, err
 	}
 
 	var apiResponse ActionNetworkAPIResponse
 	if err := json.Unmarshal(body, &apiResponse); err != nil {
-		return nil, false, fmt.Errorf("failed to parse JSON response: %v", err)
+		return nil, fmt.Errorf("failed to parse JSON response: %v", err)
 	}
 
-	hasNext := apiResponse.Links.Next.Href != ""
-
-	return apiResponse.Embedded.Events, hasNext, nil
+	return &apiResponse, nil
 }
 
-func fixTimezone(t time.Time) (time.Time, error) {
-	defaultLoc, err := time.LoadLocation("America/Chicago")
-	if err != nil {
-		return time.Now(), err
-	}
+func fixTimezone(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
 
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), defaultLoc), nil
+func isMidnight(t time.Time) bool {
+	h, m, s := t.Clock()
+	return h == 0 && m == 0 && s == 0
 }
 
-func convertActionNetworkEventToEvent(anEvent ActionNetworkEvent, organization string) (event.Event, error) {
-	startTime, err := fixTimezone(anEvent.StartDate)
+func convertActionNetworkEventToEvent(anEvent ActionNetworkEvent, source Source) (event.Event, error) {
+	organization := source.Organization
+
+	loc, err := source.Location()
 	if err != nil {
 		return event.Event{}, err
 	}
 
-	endTime := startTime.Add(1 * time.Hour)
+	startTime := fixTimezone(anEvent.StartDate, loc)
+
+	endTime := startTime.Add(source.DefaultEventLength)
 	if !anEvent.EndDate.IsZero() {
-		endTime, err = fixTimezone(anEvent.EndDate)
-		if err != nil {
-			return event.Event{}, err
+		endTime = fixTimezone(anEvent.EndDate, loc)
+	}
+
+	// Action Network has no all-day flag; events created without a time
+	// come back starting, and ending if at all, exactly at midnight
+	allDay := isMidnight(startTime) && (anEvent.EndDate.IsZero() || isMidnight(endTime))
+	if allDay {
+		var end time.Time
+		if !anEvent.EndDate.IsZero() {
+			end = endTime
 		}
+		startTime, endTime = allDayRange(startTime, end, loc)
 	}
 
 	createdTime := anEvent.CreatedDate
@@ -191,7 +262,6 @@ func convertActionNetworkEventToEvent(anEvent ActionNetworkEvent, organization s
 	}
 
 	description := fmt.Sprintf("Register for this event from %s on Action Network: %s", organization, anEvent.BrowserURL)
-	escapedDescription := escape(description)
 	status := strings.ToUpper(anEvent.Status)
 	transparency := "OPAQUE"
 
@@ -199,16 +269,20 @@ func convertActionNetworkEventToEvent(anEvent ActionNetworkEvent, organization s
 		Organization: organization,
 		UID:          uid,
 		Summary:      anEvent.Title,
-		Description:  &escapedDescription,
+		Description:  &description,
 		Location:     &location,
 		StartTime:    startTime,
 		EndTime:      endTime,
+		AllDay:       allDay,
 		Created:      &createdTime,
 		Modified:     &createdTime,
 		Status:       &status,
 		Transparency: &transparency,
 		Type:         event.EventTypeSocialGathering,
 	}
+	if anEvent.BrowserURL != "" {
+		e.URL = &anEvent.BrowserURL
+	}
 
 	return e, nil
 }
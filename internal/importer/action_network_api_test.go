@@ -0,0 +1,178 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/pkg/feed"
+)
+
+func TestConvertActionNetworkEventAllDay(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Action Network returns local wall times labeled as UTC
+	wall := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name   string
+		start  time.Time
+		end    time.Time
+		allDay bool
+		wantS  time.Time
+		wantE  time.Time
+	}{
+		{
+			name:   "midnight start without an end",
+			start:  wall(4, 5, 0, 0),
+			allDay: true,
+			wantS:  time.Date(2025, 4, 5, 0, 0, 0, 0, chicago),
+			wantE:  time.Date(2025, 4, 6, 0, 0, 0, 0, chicago),
+		},
+		{
+			name:   "midnight to midnight over several days",
+			start:  wall(4, 10, 0, 0),
+			end:    wall(4, 13, 0, 0),
+			allDay: true,
+			wantS:  time.Date(2025, 4, 10, 0, 0, 0, 0, chicago),
+			wantE:  time.Date(2025, 4, 13, 0, 0, 0, 0, chicago),
+		},
+		{
+			name:  "midnight start with a timed end",
+			start: wall(4, 5, 0, 0),
+			end:   wall(4, 5, 2, 0),
+			wantS: time.Date(2025, 4, 5, 0, 0, 0, 0, chicago),
+			wantE: time.Date(2025, 4, 5, 2, 0, 0, 0, chicago),
+		},
+		{
+			name:  "evening event ending at midnight",
+			start: wall(4, 5, 20, 0),
+			end:   wall(4, 6, 0, 0),
+			wantS: time.Date(2025, 4, 5, 20, 0, 0, 0, chicago),
+			wantE: time.Date(2025, 4, 6, 0, 0, 0, 0, chicago),
+		},
+		{
+			name:  "timed event without an end",
+			start: wall(4, 5, 18, 30),
+			wantS: time.Date(2025, 4, 5, 18, 30, 0, 0, chicago),
+			wantE: time.Date(2025, 4, 5, 19, 30, 0, 0, chicago),
+		},
+	}
+
+	source := Source{Organization: "Test Org", DefaultEventLength: time.Hour}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anEvent := ActionNetworkEvent{
+				Identifiers: []string{"action_network:1234"},
+				Title:       "Test Event",
+				StartDate:   tt.start,
+				EndDate:     tt.end,
+			}
+
+			e, err := convertActionNetworkEventToEvent(anEvent, source)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if e.AllDay != tt.allDay {
+				t.Errorf("AllDay = %v, want %v", e.AllDay, tt.allDay)
+			}
+			if !e.StartTime.Equal(tt.wantS) {
+				t.Errorf("StartTime = %v, want %v", e.StartTime, tt.wantS)
+			}
+			if !e.EndTime.Equal(tt.wantE) {
+				t.Errorf("EndTime = %v, want %v", e.EndTime, tt.wantE)
+			}
+		})
+	}
+}
+
+func TestFetchActionNetworkPagesConditionally(t *testing.T) {
+	// the title of the one event on each page, which is also its ETag
+	titles := map[string]string{"1": "First", "2": "Second"}
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		etag := fmt.Sprintf("%q", titles[page])
+		requests = append(requests, page+" "+r.Header.Get("If-None-Match"))
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		next := ""
+		if page == "1" {
+			next = "/events?page=2"
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, `{"_embedded": {"osdi:events": [{"title": %q, "start_date": "2025-04-05T18:00:00Z"}]}, "_links": {"next": {"href": %q}}}`, titles[page], next)
+	}))
+	defer srv.Close()
+
+	source := Source{
+		Organization: "Dallas Urbanists",
+		URL:          srv.URL + "/events",
+		Importer:     "action_network_api",
+		Options:      map[string]string{"api_key": "key"},
+		Validators:   &feed.Validators{Organization: "Dallas Urbanists"},
+	}
+	run := func() ([]string, error) {
+		requests = nil
+		events, err := action_network_api_importer(context.Background(), srv.Client(), source)
+
+		summaries := []string{}
+		for _, e := range events {
+			summaries = append(summaries, e.Summary)
+		}
+		return summaries, err
+	}
+
+	summaries, err := run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"First", "Second"}; !reflect.DeepEqual(summaries, want) {
+		t.Errorf("first import = %v, want %v", summaries, want)
+	}
+	if len(source.Validators.Pages) != 2 || source.Validators.Pages[1].URL != srv.URL+"/events?page=2" {
+		t.Fatalf("recorded pages = %+v, want both pages keyed on their URLs", source.Validators.Pages)
+	}
+
+	if _, err := run(); !errors.Is(err, ErrNotModified) {
+		t.Errorf("unchanged import = %v, want ErrNotModified", err)
+	}
+	if want := []string{`1 "First"`, `2 "Second"`}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("unchanged import requests = %v, want %v", requests, want)
+	}
+
+	// a change on one page re-imports every page
+	titles["2"] = "Second, moved"
+	summaries, err = run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"First", "Second, moved"}; !reflect.DeepEqual(summaries, want) {
+		t.Errorf("changed import = %v, want %v", summaries, want)
+	}
+	if want := []string{`1 "First"`, `2 "Second"`, "1 "}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("changed import requests = %v, want %v", requests, want)
+	}
+	if got := *source.Validators.Pages[1].ETag; got != `"Second, moved"` {
+		t.Errorf("page 2 ETag = %s, want the new one", got)
+	}
+}
@@ -1,8 +1,10 @@
 package importer
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -44,8 +46,8 @@ type SanityResponse struct {
 	Result []DBCEvent `json:"result"`
 }
 
-func custom_dallas_bicycle_coalition(url string, organization string, options map[string]string) ([]*event.Event, error) {
-	b, err := fetch(url)
+func custom_dallas_bicycle_coalition(ctx context.Context, client *http.Client, source Source) ([]*event.Event, error) {
+	b, err := fetch(ctx, client, source)
 	if err != nil {
 		return nil, err
 	}
@@ -57,15 +59,19 @@ func custom_dallas_bicycle_coalition(url string, organization string, options ma
 
 	converted := []*event.Event{}
 	for _, e := range sanityResp.Result {
-		converted = append(converted, convertToEvent(e, organization))
+		c, err := convertToEvent(e, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert event %s: %v", e.Title, err)
+		}
+		converted = append(converted, c)
 	}
 
 	return converted, nil
 }
 
-func convertToEvent(i DBCEvent, organization string) *event.Event {
+func convertToEvent(i DBCEvent, source Source) (*event.Event, error) {
 	o := event.Event{
-		Organization: organization,
+		Organization: source.Organization,
 		UID:          fmt.Sprintf("dbc_%v", i.ID),
 		Summary:      i.Title,
 		Location:     &i.Location,
@@ -76,17 +82,26 @@ func convertToEvent(i DBCEvent, organization string) *event.Event {
 
 	o.EndTime = i.Date.EndDate
 	if o.EndTime.IsZero() {
-		o.EndTime = i.Date.StartDate.Add(1 * time.Hour)
+		o.EndTime = i.Date.StartDate.Add(source.DefaultEventLength)
+	}
+
+	if i.AllDay {
+		loc, err := source.Location()
+		if err != nil {
+			return nil, err
+		}
+		start, end := allDayRange(i.Date.StartDate, i.Date.EndDate, loc)
+		o.StartTime, o.EndTime, o.AllDay = start, end, true
 	}
 
 	description := ""
 	if strings.TrimSpace(i.Excerpt) != "" {
-		description += fmt.Sprintf("%v\\n", escape(i.Excerpt))
+		description += fmt.Sprintf("%v\n", i.Excerpt)
 	}
 
 	for _, d := range i.Description {
 		for _, c := range d.Children {
-			description += fmt.Sprintf("%v\\n", escape(c.Text))
+			description += fmt.Sprintf("%v\n", c.Text)
 		}
 	}
 
@@ -94,5 +109,5 @@ func convertToEvent(i DBCEvent, organization string) *event.Event {
 		o.Description = &description
 	}
 
-	return &o
+	return &o, nil
 }
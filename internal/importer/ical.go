@@ -1,24 +1,29 @@
 package importer
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/dallasurbanists/events-sync/pkg/event"
+	"github.com/dallasurbanists/events-sync/pkg/ical"
 )
 
-func ical_importer(url string, organization string, options map[string]string) ([]*event.Event, error) {
-	fmt.Printf("Fetching ICS file from: %s\n", url)
+func ical_importer(ctx context.Context, client *http.Client, source Source) ([]*event.Event, error) {
+	fmt.Printf("Fetching ICS file from: %s\n", source.URL)
 
-	content, err := fetchICS(url)
+	content, err := fetchICS(ctx, client, source)
+	if errors.Is(err, ErrNotModified) {
+		return nil, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error fetching ICS: %v", err)
+		return nil, fmt.Errorf("error fetching ICS: %w", err)
 	}
 
-	events, err := ParseICS(content, organization)
+	events, err := ParseICS(content, source)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing ICS: %v", err)
 	}
@@ -26,9 +31,8 @@ func ical_importer(url string, organization string, options map[string]string) (
 	return events, nil
 }
 
-func fetchICS(url string) (string, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+func fetchICS(ctx context.Context, client *http.Client, source Source) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", source.URL, nil)
 	if err != nil {
 		return "", fmt.Errorf("error creating request: %v", err)
 	}
@@ -38,19 +42,9 @@ func fetchICS(url string) (string, error) {
 	req.Header.Set("Accept", "text/calendar,text/plain,*/*")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("error making request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP error: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := doConditional(client, req, source)
 	if err != nil {
-		return "", fmt.Errorf("error reading response body: %v", err)
+		return "", err
 	}
 
 	return string(body), nil
@@ -58,173 +52,240 @@ func fetchICS(url string) (string, error) {
 
 type parseUtils struct {
 	defaultLoc *time.Location
+	timezones  *ical.Timezones
 }
 
-func ParseICS(content string, organization string) ([]*event.Event, error) {
+func ParseICS(content string, source Source) ([]*event.Event, error) {
 	var events []*event.Event
 
-	defaultLoc, err := time.LoadLocation("America/Chicago")
+	organization := source.Organization
+
+	defaultLoc, err := source.Location()
 	if err != nil {
 		return nil, err
 	}
 
-	u := parseUtils{
-		defaultLoc: defaultLoc,
+	components, warnings := ical.Parse(content)
+	for _, w := range warnings {
+		fmt.Printf("Warning: malformed ICS for %s: %v\n", organization, w)
 	}
 
-	lines := strings.Split(content, "\r\n")
-
-	var currentEvent *event.Event
-	var currentValue string
-	var currentKey string
-	var inMultiLineValue bool
-
-	for i, line := range lines {
-		isContinuation := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
-
-		if inMultiLineValue && isContinuation {
-			currentValue += line[1:]
-			continue
-		}
-
-		// If we're in a multi-line value but this is not a continuation line,
-		// we've reached the end of the multi-line value. Process it, then continue
-		// processing the current line
-		if inMultiLineValue && !isContinuation {
-			if currentKey != "" && currentEvent != nil {
-				processEventField(currentEvent, u, currentKey, currentValue)
-			}
-			currentKey = ""
-			currentValue = ""
-			inMultiLineValue = false
-		}
-
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-
-		if strings.TrimSpace(line) == "BEGIN:VEVENT" {
-			currentEvent = &event.Event{Organization: organization, Type: event.EventTypeSocialGathering}
+	calendars := 0
+	for _, c := range components {
+		if c.Name != "VCALENDAR" {
 			continue
 		}
+		calendars++
 
-		if strings.TrimSpace(line) == "END:VEVENT" {
-			if currentEvent != nil {
-				// Process any remaining key-value pair
-				if currentKey != "" {
-					processEventField(currentEvent, u, currentKey, currentValue)
-				}
-				events = append(events, currentEvent)
-				currentEvent = nil
-			}
-			currentKey = ""
-			currentValue = ""
-			inMultiLineValue = false
-
-			continue
+		u := parseUtils{
+			defaultLoc: defaultLoc,
+			timezones:  ical.NewTimezones(c),
 		}
 
-		if strings.Contains(line, ":") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				currentKey = strings.TrimSpace(parts[0])
-				currentValue = strings.TrimSpace(parts[1])
-
-				// Check if the next line is a continuation
-				if i+1 < len(lines) && (strings.HasPrefix(lines[i+1], " ") || strings.HasPrefix(lines[i+1], "\t")) {
-					inMultiLineValue = true
-					continue
-				}
-
-				// Single line value - process immediately
-				if currentEvent != nil {
-					processEventField(currentEvent, u, currentKey, currentValue)
-				}
-				currentKey = ""
-				currentValue = ""
+		// only the event's own properties count, nested components such as
+		// VALARM carry their own DESCRIPTION/SUMMARY
+		for _, vevent := range c.Children("VEVENT") {
+			e := &event.Event{Organization: organization, Type: event.EventTypeSocialGathering}
+			for _, prop := range vevent.Properties {
+				processEventField(e, u, prop)
 			}
+			finalizeEvent(e, vevent, source.DefaultEventLength)
+			events = append(events, e)
 		}
 	}
 
-	if currentKey != "" && currentEvent != nil {
-		processEventField(currentEvent, u, currentKey, currentValue)
+	if calendars == 0 {
+		return nil, fmt.Errorf("no VCALENDAR found in content")
 	}
 
 	return events, nil
 }
 
-func processEventField(event *event.Event, u parseUtils, key, value string) {
+func processEventField(event *event.Event, u parseUtils, prop *ical.Property) {
 	if event == nil {
 		return
 	}
 
-	keyParts := strings.Split(key, ";")
-	keyPrefix := keyParts[0]
+	value := prop.Value
 
-	switch keyPrefix {
+	switch prop.Name {
 	case "UID":
 		event.UID = value
 	case "SUMMARY":
-		event.Summary = value
+		event.Summary = prop.Text()
 	case "DESCRIPTION":
-		event.Description = &value
+		description := prop.Text()
+		event.Description = &description
 	case "LOCATION":
-		event.Location = &value
+		location := prop.Text()
+		event.Location = &location
 	case "DTSTART":
-		if t, err := parseDateTime(value, u, keyParts[1:]); err == nil {
+		if t, err := parseDateTime(value, u, prop); err == nil {
 			event.StartTime = t
+			event.AllDay = isDateValue(prop)
+			event.TZID = zoneName(value, u, prop)
 		}
 	case "DTEND":
-		if t, err := parseDateTime(value, u, keyParts[1:]); err == nil {
+		if t, err := parseDateTime(value, u, prop); err == nil {
 			event.EndTime = t
 		}
 	case "CREATED":
-		if t, err := parseDateTime(value, u, keyParts[1:]); err == nil {
+		if t, err := parseDateTime(value, u, prop); err == nil {
 			event.Created = &t
 		}
 	case "LAST-MODIFIED":
-		if t, err := parseDateTime(value, u, keyParts[1:]); err == nil {
+		if t, err := parseDateTime(value, u, prop); err == nil {
 			event.Modified = &t
 		}
 	case "STATUS":
 		event.Status = &value
 	case "TRANSP":
 		event.Transparency = &value
+	case "URL":
+		event.URL = &value
 	case "SEQUENCE":
 		if seq, err := parseSequence(value); err == nil {
 			event.Sequence = seq
 		}
 	case "RECURRENCE-ID":
-		event.RecurrenceID = &value
+		recurrenceID := normalizeDates(u, prop)
+		event.RecurrenceID = &recurrenceID
 	case "RRULE":
 		event.RRule = &value
 	case "RDATE":
-		event.RDate = &value
+		event.RDate = appendList(event.RDate, normalizeDates(u, prop))
 	case "EXDATE":
-		event.ExDate = &value
+		event.ExDate = appendList(event.ExDate, normalizeDates(u, prop))
 	}
 }
 
+// zoneName returns the IANA name of the zone a DATE-TIME is given in: its
+// TZID's zone, UTC for a UTC time, and nil for floating times, dates and
+// TZIDs only defined by VTIMEZONE rules
+func zoneName(value string, u parseUtils, prop *ical.Property) *string {
+	if isDateValue(prop) {
+		return nil
+	}
+
+	if strings.HasSuffix(strings.ToUpper(value), "Z") {
+		name := "UTC"
+		return &name
+	}
+
+	tzid := prop.Param("TZID")
+	if tzid == "" {
+		return nil
+	}
+
+	tz, err := u.timezones.Load(tzid)
+	if err != nil || tz.Location() == nil {
+		return nil
+	}
+
+	name := tz.Location().String()
+	return &name
+}
+
+// normalizeDates rewrites a RECURRENCE-ID, EXDATE or RDATE value as UTC
+// instants and bare dates, resolving the property's TZID, so instances can
+// be matched no matter how each was written. Values that can't be parsed
+// are kept as they are.
+func normalizeDates(u parseUtils, prop *ical.Property) string {
+	values := []string{}
+	for _, value := range strings.Split(prop.Value, ",") {
+		// a PERIOD ends in a DATE-TIME or a duration
+		start, end, period := strings.Cut(value, "/")
+
+		normalized, err := normalizeDate(start, u, prop)
+		if err == nil && period && !strings.HasPrefix(strings.TrimLeft(end, "+-"), "P") {
+			end, err = normalizeDate(end, u, prop)
+		}
+		if err != nil {
+			fmt.Printf("Warning: keeping unparseable %s %q: %v\n", prop.Name, value, err)
+			values = append(values, value)
+			continue
+		}
+
+		if period {
+			normalized += "/" + end
+		}
+		values = append(values, normalized)
+	}
+
+	return strings.Join(values, ",")
+}
+
+func normalizeDate(value string, u parseUtils, prop *ical.Property) (string, error) {
+	if ical.IsDate(value) {
+		return ical.NormalizeDate(value, u.defaultLoc)
+	}
+
+	t, err := parseDateTime(value, u, prop)
+	if err != nil {
+		return "", err
+	}
+
+	return ical.FormatDateTime(t), nil
+}
+
+// finalizeEvent fills in what can only be derived once every property of
+// the event has been read
+func finalizeEvent(e *event.Event, vevent *ical.Component, defaultLength time.Duration) {
+	if !e.EndTime.IsZero() || e.StartTime.IsZero() {
+		return
+	}
+
+	if prop := vevent.Prop("DURATION"); prop != nil {
+		if d, err := ical.ParseDuration(prop.Value); err == nil {
+			e.EndTime = d.Add(e.StartTime)
+			return
+		}
+		fmt.Printf("Warning: ignoring invalid DURATION %q on %s\n", prop.Value, e.UID)
+	}
+
+	// a date-only DTSTART without DTEND lasts the one day (RFC 5545 3.6.1)
+	if e.AllDay {
+		e.EndTime = e.StartTime.AddDate(0, 0, 1)
+		return
+	}
+
+	e.EndTime = e.StartTime.Add(defaultLength)
+}
+
+// isDateValue reports whether a date-time property holds a DATE rather than
+// a DATE-TIME, either explicitly via VALUE=DATE or by its format
+func isDateValue(prop *ical.Property) bool {
+	if strings.EqualFold(prop.Param("VALUE"), "DATE") {
+		return true
+	}
+
+	return len(prop.Value) == len("20060102") && !strings.Contains(prop.Value, "T")
+}
+
+// appendList joins repeated list properties such as EXDATE into a single
+// comma separated value
+func appendList(existing *string, value string) *string {
+	if existing == nil || *existing == "" {
+		return &value
+	}
+
+	joined := *existing + "," + value
+	return &joined
+}
+
 // parseDateTime parses various date-time formats used in ICS files
-func parseDateTime(value string, u parseUtils, keyParams []string) (time.Time, error) {
+func parseDateTime(value string, u parseUtils, prop *ical.Property) (time.Time, error) {
 	utcFmt := "20060102T150405Z"
 	if strings.HasSuffix(strings.ToLower(value), "z") {
 		return time.Parse(utcFmt, value)
 	}
 
-	var err error
-	var zeroTime time.Time
-
-	loc := u.defaultLoc
-
-	for _, keyParam := range keyParams {
-		if strings.HasPrefix(strings.ToLower(keyParam), "tzid=") {
-			locValue := strings.Split(keyParam, "=")[1]
-			loc, err = time.LoadLocation(locValue)
-			if err != nil {
-				return zeroTime, err
-			}
-			break
+	var tz *ical.Timezone
+	if tzid := prop.Param("TZID"); tzid != "" {
+		var err error
+		tz, err = u.timezones.Load(tzid)
+		if err != nil {
+			return time.Time{}, err
 		}
 	}
 
@@ -239,7 +300,14 @@ func parseDateTime(value string, u parseUtils, keyParams []string) (time.Time, e
 	}
 
 	for _, format := range formats {
-		if t, err := time.ParseInLocation(format, value, loc); err == nil {
+		if tz != nil {
+			if t, err := time.Parse(format, value); err == nil {
+				return tz.FromWallClock(t), nil
+			}
+			continue
+		}
+
+		if t, err := time.ParseInLocation(format, value, u.defaultLoc); err == nil {
 			return t, nil
 		}
 	}
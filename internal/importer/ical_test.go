@@ -0,0 +1,374 @@
+package importer
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/pkg/event"
+	"github.com/dallasurbanists/events-sync/pkg/ical"
+)
+
+func parseTestdata(t *testing.T, name string, source Source) []*event.Event {
+	t.Helper()
+
+	b, err := os.ReadFile("../../testdata/" + name)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+
+	events, err := ParseICS(string(b), source)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", name, err)
+	}
+
+	return events
+}
+
+func eventsByUID(events []*event.Event) map[string]*event.Event {
+	byUID := map[string]*event.Event{}
+	for _, e := range events {
+		key := e.UID
+		if e.RecurrenceID != nil {
+			key += ":" + *e.RecurrenceID
+		}
+		byUID[key] = e
+	}
+
+	return byUID
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+
+	return *s
+}
+
+func TestParseICS(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file        string
+		uid         string
+		summary     string
+		description string
+		location    string
+		start       time.Time
+		end         time.Time
+	}{
+		{
+			file:        "sample.ics",
+			uid:         "test-uid-1234@example.com",
+			summary:     "Test Event",
+			description: "This is a test event",
+			location:    "Test Location",
+			start:       time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			end:         time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			file:        "sample_with_recurrence.ics",
+			uid:         "recurring-event-1234@example.com:20250108T120000Z",
+			summary:     "Modified Recurring Event",
+			description: "This is a modified instance of the recurring event",
+			location:    "Modified Location",
+			start:       time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC),
+			end:         time.Date(2025, 1, 8, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			file:        "malformed_lf_only.ics",
+			uid:         "lf-only-1234@example.com",
+			summary:     "LF Only Event",
+			description: "This feed uses bare LF line endings and a folded description line",
+			location:    "Test Location",
+			start:       time.Date(2025, 3, 1, 18, 0, 0, 0, chicago),
+			end:         time.Date(2025, 3, 1, 20, 0, 0, 0, chicago),
+		},
+		{
+			file:        "malformed_unterminated.ics",
+			uid:         "after-unterminated-5678@example.com",
+			summary:     "Event after the broken one",
+			description: "<nil>",
+			location:    "<nil>",
+			start:       time.Date(2025, 4, 2, 17, 0, 0, 0, time.UTC),
+			end:         time.Date(2025, 4, 2, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			file:        "nested_valarm_escaped.ics",
+			uid:         "escaped-1234@example.com",
+			summary:     "Coffee, Bikes; and Transit",
+			description: "First line\nSecond line with a backslash \\ and, commas",
+			location:    "Main St, Dallas, TX",
+			start:       time.Date(2025, 3, 15, 9, 0, 0, 0, chicago),
+			end:         time.Date(2025, 3, 15, 11, 0, 0, 0, chicago),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			events := eventsByUID(parseTestdata(t, tt.file, Source{Organization: "Test Org"}))
+
+			e, ok := events[tt.uid]
+			if !ok {
+				t.Fatalf("no event %s in %v", tt.uid, events)
+			}
+
+			if e.Organization != "Test Org" {
+				t.Errorf("Organization = %q", e.Organization)
+			}
+			if e.Summary != tt.summary {
+				t.Errorf("Summary = %q, want %q", e.Summary, tt.summary)
+			}
+			if got := stringValue(e.Description); got != tt.description {
+				t.Errorf("Description = %q, want %q", got, tt.description)
+			}
+			if got := stringValue(e.Location); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+			if !e.StartTime.Equal(tt.start) {
+				t.Errorf("StartTime = %v, want %v", e.StartTime, tt.start)
+			}
+			if !e.EndTime.Equal(tt.end) {
+				t.Errorf("EndTime = %v, want %v", e.EndTime, tt.end)
+			}
+		})
+	}
+}
+
+func TestParseICSEventCounts(t *testing.T) {
+	tests := []struct {
+		file  string
+		count int
+	}{
+		{"sample.ics", 1},
+		{"sample_with_recurrence.ics", 3},
+		{"malformed_lf_only.ics", 1},
+		{"malformed_unterminated.ics", 2},
+		{"nested_valarm_escaped.ics", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := len(parseTestdata(t, tt.file, Source{})); got != tt.count {
+				t.Errorf("got %d events, want %d", got, tt.count)
+			}
+		})
+	}
+}
+
+func TestParseICSRecurrence(t *testing.T) {
+	events := eventsByUID(parseTestdata(t, "sample_with_recurrence.ics", Source{}))
+
+	root := events["recurring-event-1234@example.com"]
+	if root == nil {
+		t.Fatal("missing series root")
+	}
+	if got := stringValue(root.RRule); got != "FREQ=WEEKLY;COUNT=10" {
+		t.Errorf("RRule = %q", got)
+	}
+	if root.Sequence != 2 {
+		t.Errorf("Sequence = %d, want 2", root.Sequence)
+	}
+
+	if simple := events["simple-event-5678@example.com"]; simple == nil || simple.Sequence != 1 {
+		t.Errorf("simple event = %+v", simple)
+	}
+}
+
+func TestParseICSNoCalendar(t *testing.T) {
+	if _, err := ParseICS("BEGIN:VEVENT\r\nUID:1\r\nEND:VEVENT\r\n", Source{}); err == nil {
+		t.Error("expected an error for content without a VCALENDAR")
+	}
+}
+
+func TestParseICSAllDay(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := eventsByUID(parseTestdata(t, "all_day.ics", Source{}))
+
+	tests := []struct {
+		uid   string
+		start time.Time
+		end   time.Time
+	}{
+		// a date-only DTSTART without DTEND lasts the one day
+		{"all-day-single@example.com", time.Date(2025, 4, 5, 0, 0, 0, 0, chicago), time.Date(2025, 4, 6, 0, 0, 0, 0, chicago)},
+		{"all-day-multi@example.com", time.Date(2025, 4, 10, 0, 0, 0, 0, chicago), time.Date(2025, 4, 13, 0, 0, 0, 0, chicago)},
+	}
+
+	for _, tt := range tests {
+		e, ok := events[tt.uid]
+		if !ok {
+			t.Errorf("no event %s", tt.uid)
+			continue
+		}
+
+		if !e.AllDay {
+			t.Errorf("%s: AllDay = false", tt.uid)
+		}
+		if e.TZID != nil {
+			t.Errorf("%s: TZID = %q, want none for a date", tt.uid, *e.TZID)
+		}
+		if !e.StartTime.Equal(tt.start) {
+			t.Errorf("%s: StartTime = %v, want %v", tt.uid, e.StartTime, tt.start)
+		}
+		if !e.EndTime.Equal(tt.end) {
+			t.Errorf("%s: EndTime = %v, want %v", tt.uid, e.EndTime, tt.end)
+		}
+	}
+}
+
+func TestParseICSDuration(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := Source{DefaultEventLength: 90 * time.Minute}
+	events := eventsByUID(parseTestdata(t, "duration.ics", source))
+
+	tests := []struct {
+		uid   string
+		start time.Time
+		end   time.Time
+	}{
+		// DURATION before DTSTART still applies
+		{"duration-event@example.com", time.Date(2025, 3, 10, 19, 0, 0, 0, chicago), time.Date(2025, 3, 10, 21, 30, 0, 0, chicago)},
+		// the day is nominal across the DST change
+		{"duration-days-event@example.com", time.Date(2025, 3, 8, 9, 0, 0, 0, chicago), time.Date(2025, 3, 9, 10, 0, 0, 0, chicago)},
+		// neither DTEND nor DURATION, so the source's default length
+		{"no-end-event@example.com", time.Date(2025, 3, 12, 23, 0, 0, 0, time.UTC), time.Date(2025, 3, 13, 0, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		e, ok := events[tt.uid]
+		if !ok {
+			t.Errorf("no event %s", tt.uid)
+			continue
+		}
+
+		if !e.StartTime.Equal(tt.start) {
+			t.Errorf("%s: StartTime = %v, want %v", tt.uid, e.StartTime, tt.start)
+		}
+		if !e.EndTime.Equal(tt.end) {
+			t.Errorf("%s: EndTime = %v, want %v", tt.uid, e.EndTime, tt.end)
+		}
+	}
+}
+
+func TestFinalizeEvent(t *testing.T) {
+	start := time.Date(2025, 5, 1, 18, 0, 0, 0, time.UTC)
+	day := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	defaultLength := 2 * time.Hour
+
+	tests := []struct {
+		name     string
+		vevent   string
+		start    time.Time
+		allDay   bool
+		end      time.Time
+		expected time.Time
+	}{
+		{
+			name:     "DURATION",
+			vevent:   "DURATION:PT45M",
+			start:    start,
+			expected: start.Add(45 * time.Minute),
+		},
+		{
+			name:     "DTEND wins over DURATION",
+			vevent:   "DURATION:PT45M",
+			start:    start,
+			end:      start.Add(3 * time.Hour),
+			expected: start.Add(3 * time.Hour),
+		},
+		{
+			name:     "date-only DTSTART without DTEND",
+			start:    day,
+			allDay:   true,
+			expected: day.AddDate(0, 0, 1),
+		},
+		{
+			name:     "date-only DTSTART with DURATION",
+			vevent:   "DURATION:P3D",
+			start:    day,
+			allDay:   true,
+			expected: day.AddDate(0, 0, 3),
+		},
+		{
+			name:     "default length",
+			start:    start,
+			expected: start.Add(defaultLength),
+		},
+		{
+			name:     "invalid DURATION falls back to the default length",
+			vevent:   "DURATION:2 hours",
+			start:    start,
+			expected: start.Add(defaultLength),
+		},
+		{
+			name: "no start",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\n"
+			if tt.vevent != "" {
+				content += tt.vevent + "\r\n"
+			}
+			content += "END:VEVENT\r\nEND:VCALENDAR\r\n"
+
+			roots, _ := ical.Parse(content)
+			vevent := roots[0].Children("VEVENT")[0]
+
+			e := &event.Event{UID: "test", StartTime: tt.start, EndTime: tt.end, AllDay: tt.allDay}
+			finalizeEvent(e, vevent, defaultLength)
+
+			if !e.EndTime.Equal(tt.expected) {
+				t.Errorf("EndTime = %v, want %v", e.EndTime, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseICSTimezones(t *testing.T) {
+	events := eventsByUID(parseTestdata(t, "outlook_timezones.ics", Source{}))
+
+	tests := []struct {
+		uid   string
+		tzid  string
+		start time.Time
+		end   time.Time
+	}{
+		{"outlook-windows-tzid@example.com", "America/Chicago", time.Date(2025, 1, 16, 0, 30, 0, 0, time.UTC), time.Date(2025, 1, 16, 2, 0, 0, 0, time.UTC)},
+		// only defined by its VTIMEZONE, so no IANA name to keep
+		{"custom-vtimezone-summer@example.com", "<nil>", time.Date(2025, 7, 4, 16, 0, 0, 0, time.UTC), time.Date(2025, 7, 4, 17, 0, 0, 0, time.UTC)},
+		{"custom-vtimezone-winter@example.com", "<nil>", time.Date(2025, 12, 15, 17, 0, 0, 0, time.UTC), time.Date(2025, 12, 15, 18, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		e, ok := events[tt.uid]
+		if !ok {
+			t.Errorf("no event %s", tt.uid)
+			continue
+		}
+
+		if got := stringValue(e.TZID); got != tt.tzid {
+			t.Errorf("%s: TZID = %q, want %q", tt.uid, got, tt.tzid)
+		}
+		if !e.StartTime.Equal(tt.start) {
+			t.Errorf("%s: StartTime = %v, want %v", tt.uid, e.StartTime, tt.start)
+		}
+		if !e.EndTime.Equal(tt.end) {
+			t.Errorf("%s: EndTime = %v, want %v", tt.uid, e.EndTime, tt.end)
+		}
+	}
+}
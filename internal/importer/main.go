@@ -1,34 +1,284 @@
 package importer
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
+	"net/url"
+	"time"
 
+	"github.com/dallasurbanists/events-sync/internal/config"
 	"github.com/dallasurbanists/events-sync/pkg/event"
+	"github.com/dallasurbanists/events-sync/pkg/feed"
 )
 
-type Importers map[string]Importer
+// ErrNotModified is returned by an importer when its source answered 304 or
+// served the same content as on the last successful sync
+var ErrNotModified = errors.New("source not modified since last sync")
+
+// ImporterVersion is recorded with each feed's validators. Bump it whenever
+// a change to the importers alters the events they produce, so feeds that
+// haven't changed upstream are imported again.
+const ImporterVersion = 1
+
+// Source is the typed configuration an importer runs against
+type Source struct {
+	Organization string
+	URL          string
+	Importer     string
+	Options      map[string]string
+	Timeout      time.Duration
+
+	// DefaultEventLength is used for events that only give a start time
+	DefaultEventLength time.Duration
+
+	// Timezone is the IANA zone floating times are read in, or
+	// config.DefaultTimezone when empty
+	Timezone string
+
+	// Validators, when set, are sent as conditional request headers and
+	// updated in place with whatever the source returns
+	Validators *feed.Validators
+
+	// HTTPStatus, when set, receives the status code of the last response
+	// from the source
+	HTTPStatus *int
+}
+
+// NewSource builds a Source from an organization's config entry
+func NewSource(organization string, org config.Organization) Source {
+	return Source{
+		Organization: organization,
+		URL:          org.URL,
+		Importer:     org.Importer,
+		Options:      org.Options,
+		Timeout:      org.ImportTimeout(),
+
+		DefaultEventLength: org.EventLength(),
+		Timezone:           org.Timezone,
+	}
+}
+
+// Location loads the source's timezone
+func (s Source) Location() (*time.Location, error) {
+	name := s.Timezone
+	if name == "" {
+		name = config.DefaultTimezone
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %v", name, err)
+	}
+
+	return loc, nil
+}
+
+// SettingsHash hashes the settings that shape the events imported from the
+// source, so a change to any of them re-imports an unchanged feed
+func (s Source) SettingsHash() string {
+	// encoding/json sorts map keys, so equal settings always hash the same
+	b, _ := json.Marshal(struct {
+		Importer           string
+		URL                string
+		Options            map[string]string
+		DefaultEventLength time.Duration
+		Timezone           string
+	}{s.Importer, s.URL, s.Options, s.DefaultEventLength, s.Timezone})
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Importer fetches and converts the events of a single source. Implementations
+// must honor ctx cancellation and make every request through the given client.
+type Importer interface {
+	Import(ctx context.Context, client *http.Client, source Source) ([]*event.Event, error)
+}
 
-type Importer func(string, string, map[string]string) ([]*event.Event, error)
+// ImporterFunc adapts a plain function to the Importer interface
+type ImporterFunc func(ctx context.Context, client *http.Client, source Source) ([]*event.Event, error)
+
+func (f ImporterFunc) Import(ctx context.Context, client *http.Client, source Source) ([]*event.Event, error) {
+	return f(ctx, client, source)
+}
+
+type Importers map[string]Importer
 
 func RegisterImporters() Importers {
 	i := Importers{}
-	i["custom_dallas_bicycle_coalition"] = custom_dallas_bicycle_coalition
-	i["action_network_api"] = action_network_api_importer
-	i["ical"] = ical_importer
+	i["custom_dallas_bicycle_coalition"] = ImporterFunc(custom_dallas_bicycle_coalition)
+	i["action_network_api"] = ImporterFunc(action_network_api_importer)
+	i["ical"] = ImporterFunc(ical_importer)
 
 	return i
 }
 
-func fetch(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+// requiredURL lists the importers that can't run without a URL
+var requiredURL = map[string]bool{
+	"ical":                            true,
+	"custom_dallas_bicycle_coalition": true,
+}
+
+// requiredOptions lists the options each importer can't run without
+var requiredOptions = map[string][]string{
+	"action_network_api": {"api_key"},
+}
+
+// Validate checks that the source names a registered importer and has
+// everything that importer needs
+func (i Importers) Validate(source Source) []error {
+	errs := []error{}
+
+	if _, ok := i[source.Importer]; !ok {
+		errs = append(errs, fmt.Errorf("unknown importer %q", source.Importer))
+	}
+
+	if source.URL != "" {
+		if u, err := url.Parse(source.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid url %q", source.URL))
+		}
+	} else if requiredURL[source.Importer] {
+		errs = append(errs, fmt.Errorf("%s importer needs a url", source.Importer))
+	}
+
+	for _, option := range requiredOptions[source.Importer] {
+		if source.Options[option] == "" {
+			errs = append(errs, fmt.Errorf("%s importer needs the %q option", source.Importer, option))
+		}
+	}
+
+	return errs
+}
+
+// Import runs the importer registered for the source, bounded by the
+// source's timeout
+func (i Importers) Import(ctx context.Context, client *http.Client, source Source) ([]*event.Event, error) {
+	imp, ok := i[source.Importer]
+	if !ok {
+		return nil, fmt.Errorf("unknown importer %q for organization %s", source.Importer, source.Organization)
+	}
+
+	if source.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, source.Timeout)
+		defer cancel()
+	}
+
+	if source.HTTPStatus != nil {
+		recording := *client
+		recording.Transport = &statusRecorder{next: client.Transport, status: source.HTTPStatus}
+		client = &recording
+	}
+
+	return imp.Import(ctx, client, source)
+}
+
+// statusRecorder notes the status code of every response it passes through
+type statusRecorder struct {
+	next   http.RoundTripper
+	status *int
+}
+
+func (t *statusRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err == nil {
+		*t.status = resp.StatusCode
+	}
+
+	return resp, err
+}
+
+// NewHTTPClient returns the client shared by every importer. Per-request
+// deadlines come from the context handed to each importer.
+func NewHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			MaxIdleConnsPerHost:   4,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+		},
+	}
+}
+
+// allDayRange snaps an all-day event to midnight boundaries in loc. The
+// JSON APIs report the last day inclusively, so the returned end is moved
+// to the following midnight as iCalendar expects, unless it already falls
+// on a midnight.
+func allDayRange(start, end time.Time, loc *time.Location) (time.Time, time.Time) {
+	s := start.In(loc)
+	startDay := time.Date(s.Year(), s.Month(), s.Day(), 0, 0, 0, 0, loc)
+	endDay := startDay.AddDate(0, 0, 1)
+
+	if !end.IsZero() {
+		e := end.In(loc)
+		candidate := time.Date(e.Year(), e.Month(), e.Day(), 0, 0, 0, 0, loc)
+		if !e.Equal(candidate) {
+			candidate = candidate.AddDate(0, 0, 1)
+		}
+		if candidate.After(startDay) {
+			endDay = candidate
+		}
+	}
+
+	return startDay, endDay
+}
+
+func fetch(ctx context.Context, client *http.Client, source Source) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", source.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	return doConditional(client, req, source)
+}
+
+// doConditional sends req with the source's validators, if they were
+// recorded for the same URL, importer version and settings, and records the
+// response's validators back into them. ErrNotModified is returned on a 304
+// or when the body hashes to the same value as last time.
+func doConditional(client *http.Client, req *http.Request, source Source) ([]byte, error) {
+	url := req.URL.String()
+	settings := source.SettingsHash()
+
+	v := source.Validators
+	current := v != nil && v.URL == url && v.ImporterVersion == ImporterVersion &&
+		v.SettingsHash != nil && *v.SettingsHash == settings
+
+	if current {
+		if v.ETag != nil && *v.ETag != "" {
+			req.Header.Set("If-None-Match", *v.ETag)
+		}
+		if v.LastModified != nil && *v.LastModified != "" {
+			req.Header.Set("If-Modified-Since", *v.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch data: %v", err)
+		return nil, fmt.Errorf("failed to fetch data: %w", err)
 	}
 	defer resp.Body.Close()
 
+	now := time.Now()
+
+	if resp.StatusCode == http.StatusNotModified && current {
+		v.CheckedAt = &now
+		return nil, ErrNotModified
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("request failed with status: %s", resp.Status)
 	}
@@ -38,20 +288,34 @@ func fetch(url string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to read response body: %v", err)
 	}
 
-	return body, nil
-}
+	if v == nil {
+		return body, nil
+	}
 
-func escape(i string) string {
-	o := i
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	unchanged := current && v.ContentHash != nil && *v.ContentHash == hash
 
-	o = strings.ReplaceAll(o, "\\", "\\\\")
+	v.URL = url
+	v.ETag = headerValue(resp.Header, "ETag")
+	v.LastModified = headerValue(resp.Header, "Last-Modified")
+	v.ContentHash = &hash
+	v.CheckedAt = &now
+	v.ImporterVersion = ImporterVersion
+	v.SettingsHash = &settings
 
-	o = strings.ReplaceAll(o, ";", "\\;")
+	if unchanged {
+		return nil, ErrNotModified
+	}
 
-	o = strings.ReplaceAll(o, ",", "\\,")
+	return body, nil
+}
 
-	o = strings.ReplaceAll(o, "\n", "\\n")
-	o = strings.ReplaceAll(o, "\r", "\\r")
+func headerValue(h http.Header, key string) *string {
+	value := h.Get(key)
+	if value == "" {
+		return nil
+	}
 
-	return o
+	return &value
 }
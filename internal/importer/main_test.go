@@ -0,0 +1,290 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/pkg/feed"
+)
+
+const feedBody = "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"
+
+func TestDoConditional(t *testing.T) {
+	var ifNoneMatch, ifModifiedSince string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = r.Header.Get("If-None-Match")
+		ifModifiedSince = r.Header.Get("If-Modified-Since")
+
+		if ifNoneMatch == `"v1"` && r.URL.Path == "/not-modified" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 01 Jan 2025 00:00:00 GMT")
+		w.Write([]byte(feedBody))
+	}))
+	defer srv.Close()
+
+	str := func(s string) *string { return &s }
+	source := func(path string) Source {
+		return Source{Organization: "Dallas Urbanists", URL: srv.URL + path, Importer: "ical"}
+	}
+	recorded := func(s Source) *feed.Validators {
+		return &feed.Validators{
+			Organization:    s.Organization,
+			URL:             s.URL,
+			ETag:            str(`"v1"`),
+			LastModified:    str("Wed, 01 Jan 2025 00:00:00 GMT"),
+			ContentHash:     str("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"),
+			ImporterVersion: ImporterVersion,
+			SettingsHash:    str(s.SettingsHash()),
+		}
+	}
+	withHash := func(v *feed.Validators, s Source) *feed.Validators {
+		c := *recorded(s)
+		c.ContentHash = v.ContentHash
+		return &c
+	}
+
+	// the hash of feedBody, as doConditional records it
+	first := source("/feed")
+	first.Validators = &feed.Validators{Organization: first.Organization}
+	if _, err := doConditional(srv.Client(), newRequest(t, first), first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bodyHash := first.Validators
+
+	tests := []struct {
+		name        string
+		source      Source
+		validators  func(Source) *feed.Validators
+		wantHeaders bool
+		want304     bool
+	}{
+		{
+			name:        "no validators yet",
+			source:      source("/feed"),
+			validators:  func(s Source) *feed.Validators { return &feed.Validators{Organization: s.Organization} },
+			wantHeaders: false,
+		},
+		{
+			name:        "304 from the source",
+			source:      source("/not-modified"),
+			validators:  recorded,
+			wantHeaders: true,
+			want304:     true,
+		},
+		{
+			name:        "identical content",
+			source:      source("/feed"),
+			validators:  func(s Source) *feed.Validators { return withHash(bodyHash, s) },
+			wantHeaders: true,
+			want304:     true,
+		},
+		{
+			name:   "changed URL",
+			source: source("/feed"),
+			validators: func(s Source) *feed.Validators {
+				v := withHash(bodyHash, s)
+				v.URL = srv.URL + "/old"
+				return v
+			},
+			wantHeaders: false,
+		},
+		{
+			name:   "older importer version",
+			source: source("/not-modified"),
+			validators: func(s Source) *feed.Validators {
+				v := withHash(bodyHash, s)
+				v.ImporterVersion = ImporterVersion - 1
+				return v
+			},
+			wantHeaders: false,
+		},
+		{
+			name:   "changed settings",
+			source: source("/feed"),
+			validators: func(s Source) *feed.Validators {
+				v := withHash(bodyHash, s)
+				changed := s
+				changed.Timezone = "America/New_York"
+				v.SettingsHash = str(changed.SettingsHash())
+				return v
+			},
+			wantHeaders: false,
+		},
+		{
+			name:        "changed content",
+			source:      source("/feed"),
+			validators:  recorded,
+			wantHeaders: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.source
+			s.Validators = tt.validators(s)
+
+			body, err := doConditional(srv.Client(), newRequest(t, s), s)
+
+			if sent := ifNoneMatch != "" || ifModifiedSince != ""; sent != tt.wantHeaders {
+				t.Errorf("conditional headers sent = %v, want %v (If-None-Match %q, If-Modified-Since %q)",
+					sent, tt.wantHeaders, ifNoneMatch, ifModifiedSince)
+			}
+
+			if tt.want304 {
+				if !errors.Is(err, ErrNotModified) {
+					t.Fatalf("expected ErrNotModified, got body %q, err %v", body, err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if string(body) != feedBody {
+					t.Errorf("body = %q, want %q", body, feedBody)
+				}
+			}
+
+			v := s.Validators
+			if v.CheckedAt == nil {
+				t.Error("CheckedAt was not recorded")
+			}
+			if v.URL != s.URL || v.ImporterVersion != ImporterVersion || v.SettingsHash == nil || *v.SettingsHash != s.SettingsHash() {
+				t.Errorf("validators not recorded for the current source: %+v", v)
+			}
+		})
+	}
+}
+
+func TestSettingsHash(t *testing.T) {
+	base := Source{
+		URL:      "https://example.com/feed.ics",
+		Importer: "ical",
+		Options:  map[string]string{"a": "1", "b": "2"},
+		Timezone: "America/Chicago",
+	}
+
+	same := base
+	same.Options = map[string]string{"b": "2", "a": "1"}
+	same.Timeout = base.Timeout + 1
+	if base.SettingsHash() != same.SettingsHash() {
+		t.Error("equal settings hashed differently")
+	}
+
+	for name, change := range map[string]func(*Source){
+		"importer":             func(s *Source) { s.Importer = "custom_dallas_bicycle_coalition" },
+		"url":                  func(s *Source) { s.URL += "?v=2" },
+		"options":              func(s *Source) { s.Options = map[string]string{"a": "1"} },
+		"default event length": func(s *Source) { s.DefaultEventLength = 1 },
+		"timezone":             func(s *Source) { s.Timezone = "UTC" },
+	} {
+		changed := base
+		change(&changed)
+		if base.SettingsHash() == changed.SettingsHash() {
+			t.Errorf("changing the %s kept the same hash", name)
+		}
+	}
+}
+
+func TestImportTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// never answers until the client gives up
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	// importWith runs the ical importer against the blocking server, failing
+	// the test if it hangs
+	importWith := func(ctx context.Context, timeout time.Duration) error {
+		source := Source{Organization: "Dallas Urbanists", URL: srv.URL, Importer: "ical", Timeout: timeout}
+		done := make(chan error, 1)
+		go func() {
+			_, err := RegisterImporters().Import(ctx, srv.Client(), source)
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("Import() hung")
+			return nil
+		}
+	}
+
+	if err := importWith(context.Background(), 50*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Import() past the source's timeout = %v, want context.DeadlineExceeded", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := importWith(ctx, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("Import() after cancellation = %v, want context.Canceled", err)
+	}
+}
+
+func TestImportRecordsStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(feedBody))
+	}))
+	defer srv.Close()
+
+	client := srv.Client()
+	transport := client.Transport
+
+	for path, want := range map[string]int{"/feed": http.StatusOK, "/missing": http.StatusNotFound} {
+		status := 0
+		source := Source{Organization: "Dallas Urbanists", URL: srv.URL + path, Importer: "ical", HTTPStatus: &status}
+
+		_, err := RegisterImporters().Import(context.Background(), client, source)
+		if (err != nil) != (want != http.StatusOK) {
+			t.Errorf("Import(%s) = %v", path, err)
+		}
+		if status != want {
+			t.Errorf("Import(%s) recorded status %d, want %d", path, status, want)
+		}
+	}
+
+	if client.Transport != transport {
+		t.Error("Import() replaced the shared client's transport")
+	}
+
+	// without a transport of its own the client's requests go through
+	// http.DefaultTransport
+	status := 0
+	recorder := &statusRecorder{status: &status}
+	resp, err := recorder.RoundTrip(newRequest(t, Source{URL: srv.URL + "/missing"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if status != http.StatusNotFound {
+		t.Errorf("statusRecorder recorded %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func newRequest(t *testing.T, s Source) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest("GET", s.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return req
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/dallasurbanists/events-sync/pkg/discord"
+	"github.com/dallasurbanists/events-sync/pkg/event"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -108,3 +109,14 @@ func GetUserFromContext(ctx context.Context) (*Claims, bool) {
 	user, ok := ctx.Value("user").(*Claims)
 	return user, ok
 }
+
+// actorFromRequest identifies the authenticated user making a change, for
+// the event's revision history
+func actorFromRequest(r *http.Request) event.Actor {
+	user, ok := GetUserFromContext(r.Context())
+	if !ok {
+		return event.Actor{Type: event.ActorSystem}
+	}
+
+	return event.Actor{Type: event.ActorDiscordUser, ID: user.DiscordID, Name: user.Username}
+}
@@ -2,13 +2,18 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/dallasurbanists/events-sync/internal/config"
 	"github.com/dallasurbanists/events-sync/pkg/event"
+	"github.com/dallasurbanists/events-sync/pkg/ical"
 )
 
 type EventResponse struct {
@@ -19,6 +24,10 @@ type EventResponse struct {
 	Location     *string    `json:"location"`
 	StartTime    time.Time  `json:"start_time"`
 	EndTime      time.Time  `json:"end_time"`
+	AllDay       bool       `json:"all_day"`
+	ReviewStatus string     `json:"review_status"`
+	ReviewedBy   *string    `json:"reviewed_by"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
 	Rejected     bool       `json:"rejected"`
 	RecurrenceID *string    `json:"recurrence_id"`
 	RRule        *string    `json:"rrule"`
@@ -28,12 +37,23 @@ type EventResponse struct {
 	Created      *time.Time `json:"created"`
 	Modified     *time.Time `json:"modified"`
 	Type         string     `json:"type"`
+	Status       *string    `json:"status"`
+	URL          *string    `json:"url"`
 	Overlay      map[string]event.EventOverlay `json:"overlay,omitempty"`
+
+	// Occurrence marks an instance expanded from its series' rules, which
+	// has no row of its own
+	Occurrence bool `json:"occurrence,omitempty"`
 }
 
+// defaultUpcomingDays is how far ahead recurring events are expanded when
+// the request doesn't give a days parameter
+const defaultUpcomingDays = 180
+
 type UpdateEventRequest struct {
 	RecurrenceID string  `json:"recurrence_id"`
-	Rejected     *bool   `json:"rejected,omitempty"`
+	ReviewStatus *string `json:"review_status,omitempty"`
+	Rejected     *bool   `json:"rejected,omitempty"` // shorthand for review_status rejected/approved
 	Organization *string `json:"organization,omitempty"`
 	Type         *string `json:"type,omitempty"`
 }
@@ -41,6 +61,23 @@ type UpdateEventRequest struct {
 func (s *Server) getUpcomingEvents(w http.ResponseWriter, r *http.Request) {
 	l := s.getLogger(r)
 
+	days := defaultUpcomingDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	loc, err := s.config.Location()
+	if err != nil {
+		l.Error(fmt.Sprintf("Failed to load timezone: %v", err))
+		http.Error(w, fmt.Sprintf("Failed to load timezone: %v", err), http.StatusInternalServerError)
+		return
+	}
+
 	l.Debug("getting upcoming events")
 	events, err := s.db.Events.GetEvents(&event.GetEventsInput{UpcomingOnly: true})
 	if err != nil {
@@ -49,35 +86,49 @@ func (s *Server) getUpcomingEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	now := time.Now()
+	occurrences := event.ExpandOccurrences(events, now, now.AddDate(0, 0, days), loc)
+
 	// Convert to response format
 	var result []EventResponse
-	for _, event := range events {
-		eventResp := EventResponse{
-			UID:          event.UID,
-			Organization: event.Organization,
-			Summary:      event.Summary,
-			Description:  event.Description,
-			Location:     event.Location,
-			StartTime:    event.StartTime,
-			EndTime:      event.EndTime,
-			Rejected:     event.Rejected,
-			RecurrenceID: event.RecurrenceID,
-			RRule:        event.RRule,
-			RDate:        event.RDate,
-			ExDate:       event.ExDate,
-			ExDateManual: event.ExDateManual,
-			Created:      event.Created,
-			Modified:     event.Modified,
-			Type:         event.Type,
-			Overlay:      event.Overlay,
-		}
-		result = append(result, eventResp)
+	for _, o := range occurrences {
+		result = append(result, newEventResponse(o))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
 
+// newEventResponse converts an occurrence to its API form
+func newEventResponse(o *event.Occurrence) EventResponse {
+	return EventResponse{
+		UID:          o.UID,
+		Organization: o.Organization,
+		Summary:      o.Summary,
+		Description:  o.Description,
+		Location:     o.Location,
+		StartTime:    o.StartTime,
+		EndTime:      o.EndTime,
+		AllDay:       o.AllDay,
+		ReviewStatus: o.ReviewStatus,
+		ReviewedBy:   o.ReviewedBy,
+		ReviewedAt:   o.ReviewedAt,
+		Rejected:     o.Rejected,
+		RecurrenceID: o.RecurrenceID,
+		RRule:        o.RRule,
+		RDate:        o.RDate,
+		ExDate:       o.ExDate,
+		ExDateManual: o.ExDateManual,
+		Created:      o.Created,
+		Modified:     o.Modified,
+		Type:         o.Type,
+		Status:       o.Status,
+		URL:          o.URL,
+		Overlay:      o.Overlay,
+		Occurrence:   o.Generated,
+	}
+}
+
 func (s *Server) updateEvent(w http.ResponseWriter, r *http.Request) {
 	uid := r.PathValue("uid")
 	l := s.getLogger(r)
@@ -93,7 +144,7 @@ func (s *Server) updateEvent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate that only allowed fields are being updated
-	allowedFields := map[string]bool{"recurrence_id": true, "rejected": true, "organization": true, "type": true}
+	allowedFields := map[string]bool{"recurrence_id": true, "review_status": true, "rejected": true, "organization": true, "type": true}
 	for key := range rawData {
 		if !allowedFields[key] {
 			http.Error(w, fmt.Sprintf("Field '%s' is not allowed to be updated", key), http.StatusBadRequest)
@@ -114,23 +165,9 @@ func (s *Server) updateEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gi := &event.GetEventInput{UID: uid}
-	if req.RecurrenceID != "" {
-		gi.RecurrenceID = &req.RecurrenceID
-	}
-
-	pi := &event.PatchEventInput{}
-
-	if req.Rejected != nil {
-		pi.Rejected = req.Rejected
-	}
-
-	if req.Organization != nil {
-		if *req.Organization == "" {
-			http.Error(w, "Organization cannot be empty", http.StatusBadRequest)
-			return
-		}
-		pi.Organization = req.Organization
+	if req.Organization != nil && *req.Organization == "" {
+		http.Error(w, "Organization cannot be empty", http.StatusBadRequest)
+		return
 	}
 
 	if req.Type != nil {
@@ -142,73 +179,188 @@ func (s *Server) updateEvent(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid event type", http.StatusBadRequest)
 			return
 		}
-		pi.Type = req.Type
 	}
 
-	if pi.Rejected == nil && pi.Organization == nil && pi.Type == nil {
-		http.Error(w, "At least one field (rejected, organization, or type) must be provided", http.StatusBadRequest)
+	if req.ReviewStatus == nil && req.Rejected == nil && req.Organization == nil && req.Type == nil {
+		http.Error(w, "At least one field (review_status, rejected, organization, or type) must be provided", http.StatusBadRequest)
 		return
 	}
 
-	l.Info(fmt.Sprintf("updating event %v - %v", *gi, *pi))
-	if err := s.db.Events.PatchEvent(gi, pi); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update: %v", err), http.StatusInternalServerError)
-		return
+	gi := &event.GetEventInput{UID: uid, ForUpdate: true}
+	if req.RecurrenceID != "" {
+		recurrenceID := s.normalizeRecurrenceID(req.RecurrenceID)
+		gi.RecurrenceID = &recurrenceID
 	}
 
-	if pi.Type != nil {
-		l.Info(fmt.Sprintf("updating sibling event types %v - %v", *gi, *pi))
-		err = s.updateEventType(gi, *pi.Type)
+	actor := actorFromRequest(r)
+
+	// read and write the event, its siblings and its series' manual exdates
+	// in one transaction, so concurrent moderators can't undo each other
+	var notFoundErr, reviewErr error
+	err = s.db.Events.Transaction(func(tx event.Repository) error {
+		existingEvent, occurrence, err := s.getEventOrOccurrence(tx, gi)
 		if err != nil {
-			l.Error(fmt.Sprintf("Failed to update sibling event types %v to %v: %v", gi, pi, err))
-			http.Error(w, fmt.Sprintf("Failed to update sibling event types: %v", err), http.StatusInternalServerError)
-			return
+			notFoundErr = err
+			return err
 		}
-	}
 
-	if pi.Rejected != nil {
-		l.Info(fmt.Sprintf("updating parent event rejection status %v - %v", *gi, *pi))
-		err = s.updateRootExdate(gi, *pi.Rejected)
-		if err != nil {
-			l.Error(fmt.Sprintf("Failed to update root exdate for %v to %v: %v", gi, pi, err))
-			http.Error(w, fmt.Sprintf("Failed to update root exdate: %v", err), http.StatusInternalServerError)
-			return
+		// an expanded instance has no row and takes its status from the
+		// root, which getEventOrOccurrence locked
+		var root *event.Event
+		if occurrence {
+			root, err = tx.GetEvent(&event.GetEventInput{UID: uid})
+			if err != nil {
+				notFoundErr = err
+				return err
+			}
+		}
+
+		pi := &event.PatchEventInput{Actor: actor, Organization: req.Organization, Type: req.Type}
+
+		reviewStatus := req.ReviewStatus
+		if req.Rejected != nil && reviewStatus == nil {
+			status := event.ReviewApproved
+			if occurrence {
+				status = root.ReviewStatus
+			}
+			if *req.Rejected {
+				status = event.ReviewRejected
+			}
+			reviewStatus = &status
 		}
+
+		if reviewStatus != nil {
+			if occurrence {
+				err = event.CheckOccurrenceReview(existingEvent.ReviewStatus, root.ReviewStatus, *reviewStatus)
+			} else {
+				err = event.CheckReviewTransition(existingEvent.ReviewStatus, *reviewStatus)
+			}
+			if err != nil {
+				reviewErr = err
+				return err
+			}
+
+			pi.ReviewStatus = reviewStatus
+		}
+
+		patchGi := &event.GetEventInput{UID: uid, RecurrenceID: gi.RecurrenceID}
+		if occurrence {
+			// rejecting or un-rejecting an expanded instance goes through
+			// the series' manual exdates, and its organization and type are
+			// the series'
+			patchGi = &event.GetEventInput{UID: uid}
+			pi.ReviewStatus = nil
+		}
+
+		if pi.ReviewStatus != nil || pi.Organization != nil || pi.Type != nil {
+			l.Info(fmt.Sprintf("updating event %v - %v", *patchGi, *pi))
+			if err := tx.PatchEvent(patchGi, pi); err != nil {
+				return fmt.Errorf("failed to update: %v", err)
+			}
+		}
+
+		if pi.Type != nil {
+			l.Info(fmt.Sprintf("updating sibling event types %v - %v", *gi, *pi))
+			if err := s.updateEventType(tx, patchGi, *pi.Type, actor); err != nil {
+				return fmt.Errorf("failed to update sibling event types: %v", err)
+			}
+		}
+
+		if rejected, changed := rejectionChanged(existingEvent.ReviewStatus, reviewStatus); changed {
+			l.Info(fmt.Sprintf("updating parent event rejection status %v - %v", *gi, *pi))
+			if err := s.updateRootExdate(tx, gi, rejected, actor); err != nil {
+				return fmt.Errorf("failed to update root exdate: %v", err)
+			}
+		}
+
+		return nil
+	})
+
+	switch {
+	case notFoundErr != nil:
+		l.Error(fmt.Sprintf("failed to get event %v: %v", uid, notFoundErr))
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	case reviewErr != nil:
+		http.Error(w, reviewErr.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		l.Error(fmt.Sprintf("failed to update event %v: %v", uid, err))
+		http.Error(w, fmt.Sprintf("Failed to update event: %v", err), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+// getEventOrOccurrence loads the event gi names from repo. When gi names an
+// instance of a series that has no row of its own, the instance is built
+// from the series root and reported as an occurrence, rejected if it's
+// among the root's manual exdates. A RECURRENCE-ID the series doesn't
+// produce isn't found. With gi.ForUpdate the row read, the instance's or
+// its root's, is locked.
+func (s *Server) getEventOrOccurrence(repo event.Repository, gi *event.GetEventInput) (*event.Event, bool, error) {
+	e, notFound := repo.GetEvent(gi)
+	var noEventsError event.NoEventsError
+	if notFound == nil || !errors.As(notFound, &noEventsError) || gi.RecurrenceID == nil {
+		return e, false, notFound
+	}
+
+	root, err := repo.GetEvent(&event.GetEventInput{UID: gi.UID, ForUpdate: gi.ForUpdate})
+	if err != nil || !root.IsRecurring() {
+		return nil, false, notFound
+	}
+
+	loc, err := s.config.Location()
+	if err != nil {
+		return nil, false, err
+	}
+
+	instance, err := event.Instance(root, *gi.RecurrenceID, loc)
+	if err != nil || !event.IsInstance(root, instance.RecurrenceID, loc) {
+		return nil, false, notFound
+	}
+
+	return instance, true, nil
+}
+
+// rejectionChanged reports whether a review status change rejects or
+// un-rejects an event, and which
+func rejectionChanged(previous string, next *string) (bool, bool) {
+	if next == nil {
+		return false, false
+	}
+
+	wasRejected := previous == event.ReviewRejected
+	rejected := *next == event.ReviewRejected
+
+	return rejected, wasRejected != rejected
+}
+
 func (s *Server) getEventStats(w http.ResponseWriter, r *http.Request) {
 	stats := make(map[string]int)
 	l := s.getLogger(r)
 
 	l.Info("getting event stats")
 
-	// Get rejected events
-	t := true
-	l.Debug("getting rejected events")
-	rejectedEvents, err := s.db.Events.GetEvents(&event.GetEventsInput{Rejected: &t})
+	// Count events in each review status
+	l.Debug("getting events")
+	events, err := s.db.Events.GetEvents(nil)
 	if err != nil {
-		l.Error(fmt.Sprintf("Failed to get rejected events: %v", err))
+		l.Error(fmt.Sprintf("Failed to get events: %v", err))
 
-		http.Error(w, fmt.Sprintf("Failed to get rejected events: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to get events: %v", err), http.StatusInternalServerError)
 		return
 	}
-	stats["rejected"] = len(rejectedEvents)
 
-	// Get non-rejected events
-	f := false
-	l.Debug("getting non-rejected events")
-	nonRejectedEvents, err := s.db.Events.GetEvents(&event.GetEventsInput{Rejected: &f})
-	if err != nil {
-		l.Error(fmt.Sprintf("Failed to get non-rejected events: %v", err))
-
-		http.Error(w, fmt.Sprintf("Failed to get non-rejected events: %v", err), http.StatusInternalServerError)
-		return
+	stats[event.ReviewPending] = 0
+	stats[event.ReviewApproved] = 0
+	stats[event.ReviewRejected] = 0
+	stats[event.ReviewNeedsReview] = 0
+	for _, e := range events {
+		stats[e.ReviewStatus]++
 	}
-	stats["approved"] = len(nonRejectedEvents)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
@@ -220,10 +372,6 @@ func (s *Server) generateICal(w http.ResponseWriter, r *http.Request) {
 
 	l.Info("generating ical")
 
-	var events []*event.Event
-	var err error
-	gi := &event.GetEventsInput{}
-
 	if eventType != "" {
 		l.Info(fmt.Sprintf("generating ical for type %v", eventType))
 
@@ -237,21 +385,40 @@ func (s *Server) generateICal(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, fmt.Sprintf("Invalid event type. Valid values are: %v", validTypes), http.StatusBadRequest)
 			return
 		}
-
-		gi.Type = &eventType
 	}
 
-	l.Info(fmt.Sprintf("getting all events %v", gi))
-	events, err = s.db.Events.GetEvents(gi)
+	// types are filtered once overlays are applied, as an overlay can
+	// change an event's type and overrides need their series' root
+	l.Info("getting all events")
+	events, err := s.db.Events.GetEvents(&event.GetEventsInput{})
 	if err != nil {
 		l.Error(fmt.Sprintf("Failed to get events: %v", err))
 		http.Error(w, fmt.Sprintf("Failed to get events: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	loc, err := s.config.Location()
+	if err != nil {
+		l.Error(fmt.Sprintf("Failed to load timezone: %v", err))
+		http.Error(w, fmt.Sprintf("Failed to load timezone: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// overlays cascade from the series roots before any are held back
+	published := []*event.Event{}
+	for _, e := range event.CascadeOverlays(events, loc) {
+		if !s.isPublished(e) {
+			continue
+		}
+		if eventType != "" && event.ApplyOverlays(e).Type != eventType {
+			continue
+		}
+		published = append(published, e)
+	}
+
 	// Generate iCal content
 	l.Info("generating ical content")
-	icalContent, err := generateICalContent(events, l)
+	icalContent, err := generateICalContent(published, s.config, l)
 	if err != nil {
 		l.Error(fmt.Sprintf("Failed to write calendar: %v", err))
 		http.Error(w, fmt.Sprintf("Failed to write calendar: %v", err), http.StatusInternalServerError)
@@ -267,57 +434,88 @@ func (s *Server) generateICal(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(icalContent))
 }
 
-func generateICalContent(events []*event.Event, logger *slog.Logger) (string, error) {
+// isPublished reports whether an event belongs in the public feed. Events
+// awaiting review are only published for organizations that opt in.
+func (s *Server) isPublished(e *event.Event) bool {
+	switch e.ReviewStatus {
+	case event.ReviewApproved:
+		return true
+	case event.ReviewRejected:
+		return false
+	}
+
+	if s.config == nil {
+		return false
+	}
+
+	return s.config.Organizations[e.Organization].PublishPending
+}
+
+func generateICalContent(events []*event.Event, cfg *config.Config, logger *slog.Logger) (string, error) {
 	var builder strings.Builder
+	w := ical.NewWriter(&builder)
 
 	l := logger
 	if l == nil {
 		l = slog.Default()
 	}
 
-	loc, err := time.LoadLocation("America/Chicago")
+	defaultLoc, err := cfg.Location()
 	if err != nil {
 		return "", err
 	}
 
+	date := ical.NewParam("VALUE", "DATE")
+
+	// Apply overlays before generating ICAL, and find the zones and years
+	// the VTIMEZONEs need to cover
+	overlaid := []*event.Event{}
+	zones := map[string]*time.Location{}
+	fromYear, toYear := time.Now().Year(), time.Now().Year()+1
+	for _, e := range events {
+		if e.Rejected {
+			continue
+		}
+
+		e = event.ApplyOverlays(e)
+		overlaid = append(overlaid, e)
+
+		if loc := eventLocation(e, cfg, defaultLoc); loc != time.UTC && !e.AllDay {
+			zones[loc.String()] = loc
+		}
+		if year := e.StartTime.Year(); year < fromYear {
+			fromYear = year
+		} else if year > toYear {
+			toYear = year
+		}
+	}
+
 	// Write iCal header
 	l.Debug("writing ical preamble")
 
-	builder.WriteString("BEGIN:VCALENDAR\r\n")
-	builder.WriteString("VERSION:2.0\r\n")
-	builder.WriteString("PRODID:-//Dallas Urbanists//Events Sync//EN\r\n")
-	builder.WriteString("CALSCALE:GREGORIAN\r\n")
-	builder.WriteString("METHOD:PUBLISH\r\n")
-	builder.WriteString("NAME:Dallas Urbanists Synced Events (V3)\r\n")
-	builder.WriteString("X-WR-CALNAME:Dallas Urbanists Synced Events (V3)\r\n")
-	builder.WriteString("X-WR-TIMEZONE:America/Chicago\r\n")
-	builder.WriteString("BEGIN:VTIMEZONE\r\n")
-	builder.WriteString("TZID:America/Chicago\r\n")
-	builder.WriteString("X-LIC-LOCATION:America/Chicago\r\n")
-	builder.WriteString("BEGIN:STANDARD\r\n")
-	builder.WriteString("TZOFFSETFROM:-0500\r\n")
-	builder.WriteString("TZOFFSETTO:-0600\r\n")
-	builder.WriteString("TZNAME:CST\r\n")
-	builder.WriteString("DTSTART:19701101T020000\r\n")
-	builder.WriteString("RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU\r\n")
-	builder.WriteString("END:STANDARD\r\n")
-	builder.WriteString("BEGIN:DAYLIGHT\r\n")
-	builder.WriteString("TZOFFSETFROM:-0600\r\n")
-	builder.WriteString("TZOFFSETTO:-0500\r\n")
-	builder.WriteString("TZNAME:CDT\r\n")
-	builder.WriteString("DTSTART:19700308T020000\r\n")
-	builder.WriteString("RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU\r\n")
-	builder.WriteString("END:DAYLIGHT\r\n")
-	builder.WriteString("END:VTIMEZONE\r\n")
+	w.Begin("VCALENDAR")
+	w.Raw("VERSION", "2.0")
+	w.Text("PRODID", "-//Dallas Urbanists//Events Sync//EN")
+	w.Raw("CALSCALE", "GREGORIAN")
+	w.Raw("METHOD", "PUBLISH")
+	w.Text("NAME", "Dallas Urbanists Synced Events (V3)")
+	w.Text("X-WR-CALNAME", "Dallas Urbanists Synced Events (V3)")
+	w.Text("X-WR-TIMEZONE", defaultLoc.String())
+
+	names := make([]string, 0, len(zones))
+	for name := range zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l.Debug(fmt.Sprintf("writing timezone %v", name))
+		// from a year early, so the earliest event follows an onset
+		w.Component(ical.NewVTimezone(zones[name], fromYear-1, toYear))
+	}
 
 	// Write each event
-	for _, event := range events {
-		if event.Rejected {
-			continue
-		}
-
-		// Apply overlays before generating ICAL
-		eventWithOverlay := applyOverlays(event)
+	for _, eventWithOverlay := range overlaid {
+		loc := eventLocation(eventWithOverlay, cfg, defaultLoc)
 
 		identifier := eventWithOverlay.UID
 		if eventWithOverlay.RecurrenceID != nil {
@@ -325,60 +523,84 @@ func generateICalContent(events []*event.Event, logger *slog.Logger) (string, er
 		}
 		l.Debug(fmt.Sprintf("writing event %v", identifier))
 
-		builder.WriteString("BEGIN:VEVENT\r\n")
+		w.Begin("VEVENT")
 
 		l.Debug(fmt.Sprintf("writing ID and timestamps for %v", identifier))
-		builder.WriteString(fmt.Sprintf("UID:%s\r\n", eventWithOverlay.UID))
-		builder.WriteString(fmt.Sprintf("DTSTAMP:%s\r\n", time.Now().UTC().Format("20060102T150405Z")))
-		builder.WriteString(fmt.Sprintf("DTSTART;TZID=America/Chicago:%s\r\n", eventWithOverlay.StartTime.In(loc).Format("20060102T150405")))
-		builder.WriteString(fmt.Sprintf("DTEND;TZID=America/Chicago:%s\r\n", eventWithOverlay.EndTime.In(loc).Format("20060102T150405")))
+		w.Raw("UID", eventWithOverlay.UID)
+		w.Raw("DTSTAMP", time.Now().UTC().Format("20060102T150405Z"))
+		if eventWithOverlay.AllDay {
+			// all-day events carry bare dates, DTEND being the day after the last
+			start := eventWithOverlay.StartTime.In(loc)
+			end := eventWithOverlay.EndTime.In(loc)
+			if !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			w.Raw("DTSTART", start.Format("20060102"), date)
+			w.Raw("DTEND", end.Format("20060102"), date)
+		} else {
+			writeDateTime(w, "DTSTART", eventWithOverlay.StartTime, loc)
+			// rows imported before end times were defaulted may still lack one
+			if !eventWithOverlay.EndTime.IsZero() {
+				writeDateTime(w, "DTEND", eventWithOverlay.EndTime, loc)
+			}
+		}
 
 		// Optional fields
 		if eventWithOverlay.Summary != "" {
 			l.Debug(fmt.Sprintf("writing summary for %v", identifier))
-			builder.WriteString(fmt.Sprintf("SUMMARY:%s\r\n", eventWithOverlay.Summary))
+			w.Text("SUMMARY", eventWithOverlay.Summary)
 		}
 
 		if eventWithOverlay.Description != nil && *eventWithOverlay.Description != "" {
 			l.Debug(fmt.Sprintf("writing description for %v", identifier))
-			builder.WriteString(fmt.Sprintf("DESCRIPTION:%s\r\n", *eventWithOverlay.Description))
+			w.Text("DESCRIPTION", *eventWithOverlay.Description)
 		}
 
 		if eventWithOverlay.Location != nil && *eventWithOverlay.Location != "" {
 			l.Debug(fmt.Sprintf("writing location for %v", identifier))
-			builder.WriteString(fmt.Sprintf("LOCATION:%s\r\n", *eventWithOverlay.Location))
+			w.Text("LOCATION", *eventWithOverlay.Location)
+		}
+
+		if eventWithOverlay.URL != nil && *eventWithOverlay.URL != "" {
+			l.Debug(fmt.Sprintf("writing url for %v", identifier))
+			w.Raw("URL", *eventWithOverlay.URL)
+		}
+
+		if eventWithOverlay.Status != nil && *eventWithOverlay.Status != "" {
+			l.Debug(fmt.Sprintf("writing status for %v", identifier))
+			w.Raw("STATUS", *eventWithOverlay.Status)
 		}
 
 		if eventWithOverlay.Organization != "" {
 			l.Debug(fmt.Sprintf("writing organization for %v", identifier))
-			builder.WriteString(fmt.Sprintf("X-ORGANIZING-GROUP:%s\r\n", eventWithOverlay.Organization))
-			builder.WriteString(fmt.Sprintf("X-TEAMUP-WHO:%s\r\n", eventWithOverlay.Organization))
+			w.Text("X-ORGANIZING-GROUP", eventWithOverlay.Organization)
+			w.Text("X-TEAMUP-WHO", eventWithOverlay.Organization)
 		}
 
 		l.Debug(fmt.Sprintf("writing custom properties for %v", identifier))
-		builder.WriteString(fmt.Sprintf("X-EVENT-TYPE:%s\r\n", eventWithOverlay.Type))
-		builder.WriteString(fmt.Sprintf("X-REJECTED:%t\r\n", eventWithOverlay.Rejected))
+		w.Text("X-EVENT-TYPE", eventWithOverlay.Type)
+		w.Raw("X-REJECTED", fmt.Sprintf("%t", eventWithOverlay.Rejected))
 
 		// Add sequence if greater than 0
 		if eventWithOverlay.Sequence > 0 {
 			l.Debug(fmt.Sprintf("writing sequence for %v", identifier))
-			builder.WriteString(fmt.Sprintf("SEQUENCE:%d\r\n", eventWithOverlay.Sequence))
+			w.Raw("SEQUENCE", fmt.Sprintf("%d", eventWithOverlay.Sequence))
 		}
 
 		// Add recurrence fields if present
 		if eventWithOverlay.RecurrenceID != nil && *eventWithOverlay.RecurrenceID != "" {
 			l.Debug(fmt.Sprintf("writing recurrence ID for %v", identifier))
-			builder.WriteString(fmt.Sprintf("RECURRENCE-ID:%s\r\n", *eventWithOverlay.RecurrenceID))
+			writeDates(w, "RECURRENCE-ID", []string{*eventWithOverlay.RecurrenceID}, loc)
 		}
 
 		if eventWithOverlay.RRule != nil && *eventWithOverlay.RRule != "" {
 			l.Debug(fmt.Sprintf("writing rrule for %v", identifier))
-			builder.WriteString(fmt.Sprintf("RRULE:%s\r\n", *eventWithOverlay.RRule))
+			w.Raw("RRULE", *eventWithOverlay.RRule)
 		}
 
 		if eventWithOverlay.RDate != nil && *eventWithOverlay.RDate != "" {
 			l.Debug(fmt.Sprintf("writing rdate for %v", identifier))
-			builder.WriteString(fmt.Sprintf("RDATE:%s\r\n", *eventWithOverlay.RDate))
+			writeDates(w, "RDATE", strings.Split(*eventWithOverlay.RDate, ","), loc)
 		}
 
 		l.Debug(fmt.Sprintf("compiling exdate info for %v", identifier))
@@ -395,58 +617,160 @@ func generateICalContent(events []*event.Event, logger *slog.Logger) (string, er
 
 		if len(exdates) > 0 {
 			l.Debug(fmt.Sprintf("combining exdates for %v", identifier))
-			builder.WriteString(fmt.Sprintf("EXDATE:%s\r\n", strings.Join(exdates, ",")))
+			writeDates(w, "EXDATE", exdates, loc)
 		}
 
 		// Add created and modified times if available
 		if eventWithOverlay.Created != nil {
 			l.Debug(fmt.Sprintf("getting created date for %v", identifier))
-			builder.WriteString(fmt.Sprintf("CREATED:%s\r\n", eventWithOverlay.Created.UTC().Format("20060102T150405Z")))
+			w.Raw("CREATED", eventWithOverlay.Created.UTC().Format("20060102T150405Z"))
 		}
 		if eventWithOverlay.Modified != nil {
 			l.Debug(fmt.Sprintf("getting modified date for %v", identifier))
-			builder.WriteString(fmt.Sprintf("LAST-MODIFIED:%s\r\n", eventWithOverlay.Modified.UTC().Format("20060102T150405Z")))
+			w.Raw("LAST-MODIFIED", eventWithOverlay.Modified.UTC().Format("20060102T150405Z"))
 		}
 
 		l.Debug(fmt.Sprintf("ending writing event for %v", identifier))
-		builder.WriteString("END:VEVENT\r\n")
+		w.End("VEVENT")
 	}
 
 	// Write iCal footer
 	l.Debug("completing ical write")
-	builder.WriteString("END:VCALENDAR\r\n")
+	w.End("VCALENDAR")
+
+	if err := w.Err(); err != nil {
+		return "", err
+	}
 
 	return builder.String(), nil
 }
 
-func (s *Server) updateRootExdate(gi *event.GetEventInput, rejected bool) error {
+// eventLocation returns the zone an event is written in: the zone of its
+// DTSTART when known, otherwise its organization's
+func eventLocation(e *event.Event, cfg *config.Config, defaultLoc *time.Location) *time.Location {
+	if e.TZID != nil && *e.TZID != "" {
+		if *e.TZID == "UTC" {
+			return time.UTC
+		}
+		if loc, err := time.LoadLocation(*e.TZID); err == nil {
+			return loc
+		}
+	}
+
+	if loc, err := cfg.OrganizationLocation(e.Organization); err == nil {
+		return loc
+	}
+
+	return defaultLoc
+}
+
+// formatDateTime formats t as a wall clock time in loc along with its TZID
+// parameter, or as a UTC time without one when loc is UTC
+func formatDateTime(t time.Time, loc *time.Location) (string, []ical.Param) {
+	if loc == time.UTC {
+		return ical.FormatDateTime(t), nil
+	}
+
+	return t.In(loc).Format("20060102T150405"), []ical.Param{ical.NewParam("TZID", loc.String())}
+}
+
+func writeDateTime(w *ical.Writer, name string, t time.Time, loc *time.Location) {
+	value, params := formatDateTime(t, loc)
+	w.Raw(name, value, params...)
+}
+
+// writeDates writes a RECURRENCE-ID, EXDATE or RDATE list, DATE-TIMEs as
+// formatDateTime formats them and DATEs with VALUE=DATE, one property for
+// each kind of value. PERIODs are stored in UTC and written as they are.
+func writeDates(w *ical.Writer, name string, values []string, loc *time.Location) {
+	times, dates, periods := []time.Time{}, []string{}, []string{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+		case strings.Contains(value, "/"):
+			periods = append(periods, value)
+		case ical.IsDate(value):
+			dates = append(dates, value)
+		default:
+			if t, err := ical.ParseDateTime(value, loc); err == nil {
+				times = append(times, t)
+			}
+		}
+	}
+
+	if len(times) > 0 {
+		formatted := make([]string, len(times))
+		for i, t := range times {
+			formatted[i], _ = formatDateTime(t, loc)
+		}
+		_, params := formatDateTime(times[0], loc)
+		w.Raw(name, strings.Join(formatted, ","), params...)
+	}
+	if len(dates) > 0 {
+		w.Raw(name, strings.Join(dates, ","), ical.NewParam("VALUE", "DATE"))
+	}
+	if len(periods) > 0 {
+		w.Raw(name, strings.Join(periods, ","), ical.NewParam("VALUE", "PERIOD"))
+	}
+}
+
+// normalizeRecurrenceID rewrites a recurrence ID given by a client in the
+// form it is stored in, leaving values it can't parse alone
+func (s *Server) normalizeRecurrenceID(value string) string {
+	loc, err := s.config.Location()
+	if err != nil {
+		return value
+	}
+
+	normalized, err := ical.NormalizeDate(value, loc)
+	if err != nil {
+		return value
+	}
+
+	return normalized
+}
+
+// updateRootExdate adds the event or instance gi names to its series'
+// manual exdates, or removes it when it's no longer rejected. The root is
+// locked while its exdates are rewritten, so repo must be bound to a
+// transaction.
+func (s *Server) updateRootExdate(repo event.Repository, gi *event.GetEventInput, rejected bool, actor event.Actor) error {
 	rootGi := &event.GetEventInput{UID: gi.UID}
-	rootEvt, err := s.db.Events.GetEvent(rootGi)
+	rootEvt, err := repo.GetEvent(&event.GetEventInput{UID: gi.UID, ForUpdate: true})
 	if err != nil {
 		return fmt.Errorf("failed to find root event: %v", err)
 	}
 
-	loc, err := time.LoadLocation("America/Chicago")
+	loc, err := s.config.Location()
 	if err != nil {
 		return err
 	}
 
-	affectedDateStr := rootEvt.StartTime.In(loc).Format("20060102T150405")
+	affectedDateStr := event.FormatRecurrenceID(rootEvt.StartTime, rootEvt.AllDay, loc)
 	if gi.RecurrenceID != nil && *gi.RecurrenceID != "" {
-		affectedDateStr = *gi.RecurrenceID
+		affectedDateStr = s.normalizeRecurrenceID(*gi.RecurrenceID)
 	}
 
 	if rejected {
+		if event.ContainsDate(rootEvt.ExDateManual, affectedDateStr, loc) {
+			return nil
+		}
+
 		if rootEvt.ExDateManual == nil || *rootEvt.ExDateManual == "" {
 			rootEvt.ExDateManual = &affectedDateStr
 		} else {
 			*rootEvt.ExDateManual += fmt.Sprintf(",%v", affectedDateStr)
 		}
 	} else {
+		if rootEvt.ExDateManual == nil {
+			return nil
+		}
+
 		exdates := strings.Split(*rootEvt.ExDateManual, ",")
 		newExdates := []string{}
 		for _, exdate := range exdates {
-			if exdate != affectedDateStr {
+			if !event.ContainsDate(&exdate, affectedDateStr, loc) {
 				newExdates = append(newExdates, exdate)
 			}
 		}
@@ -454,8 +778,8 @@ func (s *Server) updateRootExdate(gi *event.GetEventInput, rejected bool) error
 		*rootEvt.ExDateManual = strings.Join(newExdates, ",")
 	}
 
-	rootPi := &event.PatchEventInput{ExDateManual: rootEvt.ExDateManual}
-	err = s.db.Events.PatchEvent(rootGi, rootPi)
+	rootPi := &event.PatchEventInput{ExDateManual: rootEvt.ExDateManual, Actor: actor}
+	err = repo.PatchEvent(rootGi, rootPi)
 	if err != nil {
 		return fmt.Errorf("failed to patch root event: %v", err)
 	}
@@ -463,19 +787,32 @@ func (s *Server) updateRootExdate(gi *event.GetEventInput, rejected bool) error
 	return nil
 }
 
-func (s *Server) updateEventType(gi *event.GetEventInput, eventType string) error {
-	evts, err := s.db.Events.GetEvents(&event.GetEventsInput{UID: &gi.UID})
+// updateEventType sets the type of the rows sharing patched's UID, but for
+// patched, which was given the type along with the rest of its patch, so
+// every row gets a single revision
+func (s *Server) updateEventType(repo event.Repository, patched *event.GetEventInput, eventType string, actor event.Actor) error {
+	evts, err := repo.GetEvents(&event.GetEventsInput{UID: &patched.UID})
 	if err != nil {
 		return fmt.Errorf("could not get sibling events: %v", err)
 	}
 
+	patchedRecurrenceID := ""
+	if patched.RecurrenceID != nil {
+		patchedRecurrenceID = *patched.RecurrenceID
+	}
+
 	for _, evt := range evts {
 		evtGi := &event.GetEventInput{UID: evt.UID}
+		recurrenceID := ""
 		if evt.RecurrenceID != nil && *evt.RecurrenceID != "" {
 			evtGi.RecurrenceID = evt.RecurrenceID
+			recurrenceID = *evt.RecurrenceID
+		}
+		if recurrenceID == patchedRecurrenceID {
+			continue
 		}
 
-		err = s.db.Events.PatchEvent(evtGi, &event.PatchEventInput{Type: &eventType})
+		err = repo.PatchEvent(evtGi, &event.PatchEventInput{Type: &eventType, Actor: actor})
 		if err != nil {
 			return fmt.Errorf("could not update sibling events: %v", err)
 		}
@@ -484,58 +821,84 @@ func (s *Server) updateEventType(gi *event.GetEventInput, eventType string) erro
 	return nil
 }
 
-// applyOverlays applies overlay values to event fields based on merge logic
-func applyOverlays(e *event.Event) *event.Event {
-	// Create a copy to avoid modifying the original
-	eventCopy := *e
+// overlayTarget is where the overlays of an event or instance are kept:
+// on its own row, or for an instance of a series without a row of its own,
+// in the root's occurrence overlays under its RECURRENCE-ID
+type overlayTarget struct {
+	event   *event.Event
+	overlay map[string]event.EventOverlay
+
+	root         *event.Event
+	recurrenceID string
+}
 
-	// Initialize overlay map if nil
-	if eventCopy.Overlay == nil {
-		eventCopy.Overlay = make(map[string]event.EventOverlay)
+// scope reports what the target's overlays are set on
+func (t *overlayTarget) scope() event.OverlayScope {
+	switch {
+	case t.event.IsRecurring():
+		return event.OverlayOnSeries
+	case t.event.RecurrenceID != nil && *t.event.RecurrenceID != "":
+		return event.OverlayOnOccurrence
 	}
 
-	// Apply overlays for each field
-	for field, overlay := range e.Overlay {
-		switch field {
-		case "location":
-			if shouldApplyOverlay(eventCopy.Location, overlay) {
-				if locationStr, ok := overlay.Value.(string); ok {
-					eventCopy.Location = &locationStr
-				}
-			}
-		case "description":
-			if shouldApplyOverlay(eventCopy.Description, overlay) {
-				if descStr, ok := overlay.Value.(string); ok {
-					eventCopy.Description = &descStr
-				}
-			}
-		case "summary":
-			if overlay.MergeLogic == "overwrite_all" ||
-			   (overlay.MergeLogic == "overwrite_empty" && eventCopy.Summary == "") {
-				if summaryStr, ok := overlay.Value.(string); ok {
-					eventCopy.Summary = summaryStr
-				}
-			}
-		// Add more fields as needed
+	return event.OverlayOnEvent
+}
+
+// getOverlayTarget loads the overlays of the event with the given UID, or
+// of one of its instances when recurrenceID is set. The row they're kept on
+// is locked, so repo must be bound to a transaction.
+func (s *Server) getOverlayTarget(repo event.Repository, uid, recurrenceID string) (*overlayTarget, error) {
+	gi := &event.GetEventInput{UID: uid, ForUpdate: true}
+	if recurrenceID != "" {
+		gi.RecurrenceID = &recurrenceID
+	}
+
+	e, occurrence, err := s.getEventOrOccurrence(repo, gi)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &overlayTarget{event: e, overlay: map[string]event.EventOverlay{}}
+	own := e.Overlay
+	if occurrence {
+		t.root, err = repo.GetEvent(&event.GetEventInput{UID: uid})
+		if err != nil {
+			return nil, err
 		}
+		t.recurrenceID = *e.RecurrenceID
+		own = t.root.OccurrenceOverlays[t.recurrenceID]
+	}
+
+	for field, overlay := range own {
+		t.overlay[field] = overlay
 	}
 
-	return &eventCopy
+	return t, nil
 }
 
-// shouldApplyOverlay determines if an overlay should be applied based on merge logic
-func shouldApplyOverlay(currentValue *string, overlay event.EventOverlay) bool {
-	switch overlay.MergeLogic {
-	case "overwrite_empty":
-		return currentValue == nil || *currentValue == ""
-	case "overwrite_all":
-		return true
-	default:
-		return false
+// saveOverlayTarget writes a target's overlays back
+func (s *Server) saveOverlayTarget(repo event.Repository, t *overlayTarget, actor event.Actor) error {
+	if t.root == nil {
+		gi := &event.GetEventInput{UID: t.event.UID, RecurrenceID: t.event.RecurrenceID}
+		return repo.PatchEvent(gi, &event.PatchEventInput{Overlay: t.overlay, Actor: actor})
+	}
+
+	overlays := map[string]map[string]event.EventOverlay{}
+	for recurrenceID, overlay := range t.root.OccurrenceOverlays {
+		overlays[recurrenceID] = overlay
 	}
+	if len(t.overlay) == 0 {
+		delete(overlays, t.recurrenceID)
+	} else {
+		overlays[t.recurrenceID] = t.overlay
+	}
+
+	gi := &event.GetEventInput{UID: t.root.UID}
+	return repo.PatchEvent(gi, &event.PatchEventInput{OccurrenceOverlays: overlays, Actor: actor})
 }
 
-// setEventOverlay handles setting an overlay for a specific event field
+// setEventOverlay handles setting an overlay for a specific event field,
+// on a whole series or, given a recurrence ID, on one of its occurrences
 func (s *Server) setEventOverlay(w http.ResponseWriter, r *http.Request) {
 	uid := r.PathValue("uid")
 	l := s.getLogger(r)
@@ -543,10 +906,11 @@ func (s *Server) setEventOverlay(w http.ResponseWriter, r *http.Request) {
 	l.Debug(fmt.Sprintf("setting overlay for event %v", uid))
 
 	var req struct {
-		Field      string      `json:"field"`
-		Value      interface{} `json:"value"`
-		MergeLogic string      `json:"mergeLogic"`
-		Reason     string      `json:"reason,omitempty"`
+		Field        string      `json:"field"`
+		Value        interface{} `json:"value"`
+		MergeLogic   string      `json:"mergeLogic"`
+		Reason       string      `json:"reason,omitempty"`
+		RecurrenceID string      `json:"recurrence_id,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -555,36 +919,6 @@ func (s *Server) setEventOverlay(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate field name
-	allowedFields := map[string]bool{"location": true, "description": true, "summary": true}
-	if !allowedFields[req.Field] {
-		l.Error(fmt.Sprintf("invalid field '%s' for overlay on event %v", req.Field, uid))
-		http.Error(w, fmt.Sprintf("Field '%s' is not allowed to be overridden", req.Field), http.StatusBadRequest)
-		return
-	}
-
-	// Validate merge logic
-	allowedMergeLogic := map[string]bool{"overwrite_empty": true, "overwrite_all": true}
-	if !allowedMergeLogic[req.MergeLogic] {
-		l.Error(fmt.Sprintf("invalid merge logic '%s' for overlay on event %v", req.MergeLogic, uid))
-		http.Error(w, fmt.Sprintf("Merge logic '%s' is not supported", req.MergeLogic), http.StatusBadRequest)
-		return
-	}
-
-	// Get the event
-	gi := &event.GetEventInput{UID: uid}
-	existingEvent, err := s.db.Events.GetEvent(gi)
-	if err != nil {
-		l.Error(fmt.Sprintf("failed to get event %v: %v", uid, err))
-		http.Error(w, "Event not found", http.StatusNotFound)
-		return
-	}
-
-	// Initialize overlay if nil
-	if existingEvent.Overlay == nil {
-		existingEvent.Overlay = make(map[string]event.EventOverlay)
-	}
-
 	// Create overlay entry
 	overlay := event.EventOverlay{
 		Value:      req.Value,
@@ -594,16 +928,37 @@ func (s *Server) setEventOverlay(w http.ResponseWriter, r *http.Request) {
 		Reason:     req.Reason,
 	}
 
-	// Set the overlay
-	existingEvent.Overlay[req.Field] = overlay
+	// read and write the overlays in one transaction, so concurrent edits
+	// of the same event can't overwrite each other
+	var targetErr, overlayErr error
+	err := s.db.Events.Transaction(func(tx event.Repository) error {
+		target, err := s.getOverlayTarget(tx, uid, s.normalizeRecurrenceID(req.RecurrenceID))
+		if err != nil {
+			targetErr = err
+			return err
+		}
+
+		if err := event.ValidateOverlay(req.Field, overlay, target.scope()); err != nil {
+			overlayErr = err
+			return err
+		}
 
-	// Update the event in database using PatchEvent
-	pi := &event.PatchEventInput{
-		Overlay: existingEvent.Overlay,
-	}
+		// Set the overlay
+		target.overlay[req.Field] = overlay
 
-	err = s.db.Events.PatchEvent(gi, pi)
-	if err != nil {
+		return s.saveOverlayTarget(tx, target, actorFromRequest(r))
+	})
+
+	switch {
+	case targetErr != nil:
+		l.Error(fmt.Sprintf("failed to get event %v %v: %v", uid, req.RecurrenceID, targetErr))
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	case overlayErr != nil:
+		l.Error(fmt.Sprintf("invalid overlay on event %v: %v", uid, overlayErr))
+		http.Error(w, overlayErr.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
 		l.Error(fmt.Sprintf("failed to update overlay for event %v: %v", uid, err))
 		http.Error(w, "Failed to update overlay", http.StatusInternalServerError)
 		return
@@ -616,46 +971,45 @@ func (s *Server) setEventOverlay(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// removeEventOverlay handles removing an overlay for a specific event field
+// removeEventOverlay handles removing an overlay for a specific event
+// field, from one occurrence when given a recurrence_id query parameter
 func (s *Server) removeEventOverlay(w http.ResponseWriter, r *http.Request) {
 	uid := r.PathValue("uid")
 	field := r.PathValue("field")
+	recurrenceID := s.normalizeRecurrenceID(r.URL.Query().Get("recurrence_id"))
 	l := s.getLogger(r)
 
-	l.Debug(fmt.Sprintf("removing overlay for event %v field %v", uid, field))
+	l.Debug(fmt.Sprintf("removing overlay for event %v %v field %v", uid, recurrenceID, field))
 
-	// Get the event
-	gi := &event.GetEventInput{UID: uid}
-	existingEvent, err := s.db.Events.GetEvent(gi)
-	if err != nil {
-		l.Error(fmt.Sprintf("failed to get event %v: %v", uid, err))
-		http.Error(w, "Event not found", http.StatusNotFound)
-		return
-	}
-
-	// Check if overlay exists
-	if existingEvent.Overlay == nil {
-		l.Error(fmt.Sprintf("no overlay found for event %v when trying to remove field %v", uid, field))
-		http.Error(w, "No overlay found for this event", http.StatusNotFound)
-		return
-	}
+	var targetErr, missingErr error
+	err := s.db.Events.Transaction(func(tx event.Repository) error {
+		target, err := s.getOverlayTarget(tx, uid, recurrenceID)
+		if err != nil {
+			targetErr = err
+			return err
+		}
 
-	if _, exists := existingEvent.Overlay[field]; !exists {
-		l.Error(fmt.Sprintf("no overlay found for field '%s' on event %v", field, uid))
-		http.Error(w, fmt.Sprintf("No overlay found for field '%s'", field), http.StatusNotFound)
-		return
-	}
+		if _, exists := target.overlay[field]; !exists {
+			missingErr = fmt.Errorf("no overlay found for field '%s'", field)
+			return missingErr
+		}
 
-	// Remove the overlay
-	delete(existingEvent.Overlay, field)
+		// Remove the overlay
+		delete(target.overlay, field)
 
-	// Update the event in database using PatchEvent
-	pi := &event.PatchEventInput{
-		Overlay: existingEvent.Overlay,
-	}
+		return s.saveOverlayTarget(tx, target, actorFromRequest(r))
+	})
 
-	err = s.db.Events.PatchEvent(gi, pi)
-	if err != nil {
+	switch {
+	case targetErr != nil:
+		l.Error(fmt.Sprintf("failed to get event %v %v: %v", uid, recurrenceID, targetErr))
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	case missingErr != nil:
+		l.Error(fmt.Sprintf("no overlay found for field '%s' on event %v %v", field, uid, recurrenceID))
+		http.Error(w, fmt.Sprintf("No overlay found for field '%s'", field), http.StatusNotFound)
+		return
+	case err != nil:
 		l.Error(fmt.Sprintf("failed to remove overlay for event %v: %v", uid, err))
 		http.Error(w, "Failed to remove overlay", http.StatusInternalServerError)
 		return
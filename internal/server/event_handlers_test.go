@@ -0,0 +1,382 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+	"github.com/dallasurbanists/events-sync/pkg/event"
+	"github.com/dallasurbanists/events-sync/pkg/ical"
+)
+
+func TestGenerateICalContentRoundTrip(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := func(s string) *string { return &s }
+	long := strings.Repeat("A long description that has to be folded, more than once; ", 5)
+
+	events := []*event.Event{
+		{
+			UID:          "timed@example.com",
+			Organization: "Dallas Urbanists",
+			Summary:      "Coffee, Bikes; and Transit",
+			Description:  str("First line\nSecond line with a backslash \\\n" + long),
+			Location:     str("Main St, Dallas, TX"),
+			StartTime:    time.Date(2025, 7, 4, 18, 30, 0, 0, chicago),
+			EndTime:      time.Date(2025, 7, 4, 20, 0, 0, 0, chicago),
+			TZID:         str("America/Chicago"),
+			Type:         event.EventTypeSocialGathering,
+		},
+		{
+			UID:          "all-day@example.com",
+			Organization: "Dallas Urbanists",
+			Summary:      "Three Day Festival",
+			StartTime:    time.Date(2025, 4, 10, 0, 0, 0, 0, chicago),
+			EndTime:      time.Date(2025, 4, 13, 0, 0, 0, 0, chicago),
+			AllDay:       true,
+			Type:         event.EventTypeSocialGathering,
+		},
+		{
+			UID:          "utc@example.com",
+			Organization: "Dallas Urbanists",
+			Summary:      "Online Meeting",
+			StartTime:    time.Date(2025, 1, 15, 1, 0, 0, 0, time.UTC),
+			EndTime:      time.Date(2025, 1, 15, 2, 0, 0, 0, time.UTC),
+			TZID:         str("UTC"),
+			Type:         event.EventTypeSocialGathering,
+		},
+	}
+
+	content, err := generateICalContent(events, &config.Config{Timezone: "America/Chicago"}, nil)
+	if err != nil {
+		t.Fatalf("failed to generate ical: %v", err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(content, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line is %d octets: %q", len(line), line)
+		}
+	}
+
+	roots, warnings := ical.Parse(content)
+	if len(warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	if len(roots) != 1 {
+		t.Fatalf("expected a single VCALENDAR, got %d", len(roots))
+	}
+	cal := roots[0]
+
+	timezones := cal.Children("VTIMEZONE")
+	if len(timezones) != 1 || timezones[0].Prop("TZID").Value != "America/Chicago" {
+		t.Errorf("expected one America/Chicago VTIMEZONE, got %d", len(timezones))
+	}
+
+	vevents := map[string]*ical.Component{}
+	for _, c := range cal.Children("VEVENT") {
+		vevents[c.Prop("UID").Value] = c
+	}
+	if len(vevents) != len(events) {
+		t.Fatalf("expected %d VEVENTs, got %d", len(events), len(vevents))
+	}
+
+	timed := vevents["timed@example.com"]
+	for name, want := range map[string]string{
+		"SUMMARY":            events[0].Summary,
+		"DESCRIPTION":        *events[0].Description,
+		"LOCATION":           *events[0].Location,
+		"X-ORGANIZING-GROUP": "Dallas Urbanists",
+	} {
+		if got := timed.Prop(name).Text(); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	checkDateTime(t, timed.Prop("DTSTART"), "America/Chicago", events[0].StartTime)
+	checkDateTime(t, timed.Prop("DTEND"), "America/Chicago", events[0].EndTime)
+
+	allDay := vevents["all-day@example.com"]
+	for name, want := range map[string]string{"DTSTART": "20250410", "DTEND": "20250413"} {
+		p := allDay.Prop(name)
+		if p.Value != want || p.Param("VALUE") != "DATE" {
+			t.Errorf("%s = %q VALUE=%q, want %q VALUE=DATE", name, p.Value, p.Param("VALUE"), want)
+		}
+	}
+
+	utc := vevents["utc@example.com"]
+	if p := utc.Prop("DTSTART"); p.Value != "20250115T010000Z" || p.Param("TZID") != "" {
+		t.Errorf("DTSTART = %q TZID=%q, want a UTC time", p.Value, p.Param("TZID"))
+	}
+}
+
+func checkDateTime(t *testing.T, p *ical.Property, tzid string, want time.Time) {
+	t.Helper()
+
+	if got := p.Param("TZID"); got != tzid {
+		t.Errorf("%s TZID = %q, want %q", p.Name, got, tzid)
+		return
+	}
+
+	loc, err := time.LoadLocation(tzid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := time.ParseInLocation("20060102T150405", p.Value, loc)
+	if err != nil {
+		t.Fatalf("%s: %v", p.Name, err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("%s = %v, want %v", p.Name, got, want)
+	}
+}
+
+func TestWriteDates(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		values []string
+		loc    *time.Location
+		want   []string
+	}{
+		{
+			name:   "UTC instants get the series' TZID",
+			values: []string{"20250310T230000Z", "20250317T230000Z"},
+			loc:    chicago,
+			want:   []string{"EXDATE;TZID=America/Chicago:20250310T180000,20250317T180000"},
+		},
+		{
+			name:   "instants on both sides of DST keep the wall clock",
+			values: []string{"20250304T000000Z", "20250311T230000Z"},
+			loc:    chicago,
+			want:   []string{"EXDATE;TZID=America/Chicago:20250303T180000,20250311T180000"},
+		},
+		{
+			name:   "UTC series stay in UTC",
+			values: []string{"20250310T230000Z"},
+			loc:    time.UTC,
+			want:   []string{"EXDATE:20250310T230000Z"},
+		},
+		{
+			name:   "a DATE stays a DATE",
+			values: []string{"20250310", "20250311T000000Z"},
+			loc:    chicago,
+			want: []string{
+				"EXDATE;TZID=America/Chicago:20250310T190000",
+				"EXDATE;VALUE=DATE:20250310",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			w := ical.NewWriter(&b)
+			writeDates(w, "EXDATE", tt.values, tt.loc)
+			if err := w.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateICalContentRecurrenceDates(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := func(s string) *string { return &s }
+	start := time.Date(2025, 3, 3, 18, 0, 0, 0, chicago)
+
+	// stored the way the importer normalizes them, as UTC instants
+	events := []*event.Event{
+		{
+			UID:          "weekly@example.com",
+			Organization: "Dallas Urbanists",
+			Summary:      "Weekly Ride",
+			StartTime:    start,
+			EndTime:      start.Add(time.Hour),
+			TZID:         str("America/Chicago"),
+			RRule:        str("FREQ=WEEKLY"),
+			ExDate:       str("20250317T230000Z"),
+			ExDateManual: str("20250324T230000Z"),
+			Type:         event.EventTypeSocialGathering,
+		},
+		{
+			UID:          "weekly@example.com",
+			Organization: "Dallas Urbanists",
+			Summary:      "Weekly Ride, moved",
+			StartTime:    start.AddDate(0, 0, 7).Add(time.Hour),
+			EndTime:      start.AddDate(0, 0, 7).Add(2 * time.Hour),
+			TZID:         str("America/Chicago"),
+			RecurrenceID: str("20250310T230000Z"),
+			Type:         event.EventTypeSocialGathering,
+		},
+	}
+
+	content, err := generateICalContent(events, &config.Config{Timezone: "America/Chicago"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots, warnings := ical.Parse(content)
+	if len(warnings) > 0 || len(roots) != 1 {
+		t.Fatalf("failed to parse the feed: %v", warnings)
+	}
+
+	var root, override *ical.Component
+	for _, c := range roots[0].Children("VEVENT") {
+		if c.Prop("RECURRENCE-ID") == nil {
+			root = c
+		} else {
+			override = c
+		}
+	}
+	if root == nil || override == nil {
+		t.Fatalf("expected the root and its override in\n%s", content)
+	}
+
+	exdate := root.Prop("EXDATE")
+	if exdate == nil || exdate.Param("TZID") != "America/Chicago" || exdate.Value != "20250317T180000,20250324T180000" {
+		t.Errorf("EXDATE = %+v, want both in America/Chicago", exdate)
+	}
+
+	checkDateTime(t, override.Prop("RECURRENCE-ID"), "America/Chicago", time.Date(2025, 3, 10, 18, 0, 0, 0, chicago))
+}
+
+// weeklySeries returns an approved weekly series from March 3rd, 2025 at
+// 6pm Chicago time with no rows but its root
+func weeklySeries(t *testing.T) *memoryEvents {
+	t.Helper()
+
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := func(s string) *string { return &s }
+	start := time.Date(2025, 3, 3, 18, 0, 0, 0, chicago)
+
+	return &memoryEvents{events: []*event.Event{
+		{
+			UID:          "weekly@example.com",
+			Organization: "Dallas Urbanists",
+			Summary:      "Weekly Ride",
+			StartTime:    start,
+			EndTime:      start.Add(time.Hour),
+			RecurrenceID: str(""),
+			RRule:        str("FREQ=WEEKLY"),
+			ReviewStatus: event.ReviewApproved,
+			Type:         event.EventTypeSocialGathering,
+		},
+	}}
+}
+
+func TestUnknownOccurrence(t *testing.T) {
+	events := weeklySeries(t)
+	s := newTestServer(events)
+
+	// a Tuesday, which the Monday series never produces
+	for name, w := range map[string]*httptest.ResponseRecorder{
+		"update":         serve(t, s.updateEvent, http.MethodPatch, "weekly@example.com", `{"recurrence_id": "20250311T230000Z", "rejected": true}`),
+		"set overlay":    serve(t, s.setEventOverlay, http.MethodPut, "weekly@example.com", `{"recurrence_id": "20250311T230000Z", "field": "summary", "value": "Ride", "mergeLogic": "overwrite_all"}`),
+		"remove overlay": serve(t, s.removeEventOverlay, http.MethodDelete, "weekly@example.com?recurrence_id=20250311T230000Z", ""),
+	} {
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want %d", name, w.Code, http.StatusNotFound)
+		}
+	}
+	if len(events.patches) != 0 {
+		t.Errorf("unknown occurrences patched the series: %+v", events.patches)
+	}
+
+	w := serve(t, s.updateEvent, http.MethodPatch, "weekly@example.com", `{"recurrence_id": "20250310T230000Z", "rejected": true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if root := events.events[0]; root.ExDateManual == nil || *root.ExDateManual != "20250310T230000Z" {
+		t.Errorf("root exdates = %v, want the occurrence rejected", root.ExDateManual)
+	}
+}
+
+// withOverride adds a row of its own for the series' March 10th instance
+func withOverride(events *memoryEvents, status string) *memoryEvents {
+	root := events.events[0]
+	recurrenceID := "20250310T230000Z"
+	override := *root
+	override.StartTime = root.StartTime.AddDate(0, 0, 7).Add(time.Hour)
+	override.EndTime = override.StartTime.Add(time.Hour)
+	override.RecurrenceID = &recurrenceID
+	override.RRule = nil
+	override.ReviewStatus = status
+	events.events = append(events.events, &override)
+
+	return events
+}
+
+func TestUpdateEventLocksRows(t *testing.T) {
+	events := withOverride(weeklySeries(t), event.ReviewApproved)
+	s := newTestServer(events)
+
+	w := serve(t, s.updateEvent, http.MethodPatch, "weekly@example.com", `{"recurrence_id": "20250310T230000Z", "rejected": true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	if len(events.patchesTo("20250310T230000Z")) != 1 || len(events.patchesTo("")) != 1 {
+		t.Fatalf("patches = %+v, want the override's status and the root's exdates", events.patches)
+	}
+	for _, p := range events.patches {
+		if !p.locked {
+			t.Errorf("patched %v without locking it first", p.gi)
+		}
+	}
+	if root := events.events[0]; root.ExDateManual == nil || *root.ExDateManual != "20250310T230000Z" {
+		t.Errorf("root exdates = %v, want the override rejected", root.ExDateManual)
+	}
+}
+
+func TestUpdateEventTypePatchesEachRowOnce(t *testing.T) {
+	events := withOverride(weeklySeries(t), event.ReviewApproved)
+	s := newTestServer(events)
+
+	w := serve(t, s.updateEvent, http.MethodPatch, "weekly@example.com", `{"type": "civic_meeting", "organization": "Bike Friendly Dallas"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	if root := events.patchesTo(""); len(root) != 1 || root[0].Type == nil || root[0].Organization == nil {
+		t.Errorf("root patches = %+v, want its organization and type patched together", root)
+	}
+	if override := events.patchesTo("20250310T230000Z"); len(override) != 1 {
+		t.Errorf("override patches = %+v, want one", override)
+	}
+	for _, e := range events.events {
+		if e.Type != event.EventTypeCivicMeeting {
+			t.Errorf("%v has type %s, want civic_meeting", e.RecurrenceID, e.Type)
+		}
+	}
+
+	events.patches = nil
+	w = serve(t, s.updateEvent, http.MethodPatch, "weekly@example.com", `{"type": "social_gathering"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if root := events.patchesTo(""); len(root) != 1 {
+		t.Errorf("root patches = %+v, want only the type patched, once", root)
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dallasurbanists/events-sync/internal/database"
+	"github.com/dallasurbanists/events-sync/pkg/event"
+)
+
+// memoryEvents is an event.Repository over a slice of rows, recording the
+// patches made to them. Transaction rolls the rows back when fn fails.
+type memoryEvents struct {
+	events    []*event.Event
+	revisions []*event.Revision
+	patches   []memoryPatch
+
+	// inTx is set inside Transaction, and locked holds the keys of the
+	// rows it read ForUpdate
+	inTx   bool
+	locked map[string]bool
+}
+
+type memoryPatch struct {
+	gi event.GetEventInput
+	pi event.PatchEventInput
+
+	// locked reports whether the row was read ForUpdate earlier in the
+	// same transaction
+	locked bool
+}
+
+func memoryKey(uid string, recurrenceID *string) string {
+	if recurrenceID == nil || *recurrenceID == "" {
+		return uid
+	}
+
+	return uid + ":" + *recurrenceID
+}
+
+var errNotImplemented = errors.New("not implemented by memoryEvents")
+
+func (m *memoryEvents) find(uid string, recurrenceID *string) *event.Event {
+	rid := ""
+	if recurrenceID != nil {
+		rid = *recurrenceID
+	}
+
+	for _, e := range m.events {
+		eventRID := ""
+		if e.RecurrenceID != nil {
+			eventRID = *e.RecurrenceID
+		}
+		if e.UID == uid && eventRID == rid {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func (m *memoryEvents) InsertEvent(e *event.Event, actor event.Actor) error {
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *memoryEvents) GetEvent(i *event.GetEventInput) (*event.Event, error) {
+	e := m.find(i.UID, i.RecurrenceID)
+	if e == nil {
+		return nil, event.NewNoEventsError(sql.ErrNoRows)
+	}
+	if i.ForUpdate && m.inTx {
+		m.locked[memoryKey(e.UID, i.RecurrenceID)] = true
+	}
+
+	copied := *e
+	return &copied, nil
+}
+
+func (m *memoryEvents) GetEvents(i *event.GetEventsInput) ([]*event.Event, error) {
+	out := []*event.Event{}
+	for _, e := range m.events {
+		if i == nil || i.UID == nil || *i.UID == e.UID {
+			copied := *e
+			out = append(out, &copied)
+		}
+	}
+
+	return out, nil
+}
+
+func (m *memoryEvents) PatchEvent(gi *event.GetEventInput, pi *event.PatchEventInput) error {
+	e := m.find(gi.UID, gi.RecurrenceID)
+	if e == nil {
+		return event.NewNoEventsError(sql.ErrNoRows)
+	}
+
+	if pi.Organization != nil {
+		e.Organization = *pi.Organization
+	}
+	if pi.ReviewStatus != nil {
+		e.ReviewStatus = *pi.ReviewStatus
+		e.Rejected = *pi.ReviewStatus == event.ReviewRejected
+	}
+	if pi.Type != nil {
+		e.Type = *pi.Type
+	}
+	if pi.ExDateManual != nil {
+		exdates := *pi.ExDateManual
+		e.ExDateManual = &exdates
+	}
+	if pi.Overlay != nil {
+		e.Overlay = pi.Overlay
+	}
+	if pi.OccurrenceOverlays != nil {
+		e.OccurrenceOverlays = pi.OccurrenceOverlays
+	}
+
+	m.patches = append(m.patches, memoryPatch{gi: *gi, pi: *pi, locked: m.locked[memoryKey(gi.UID, gi.RecurrenceID)]})
+
+	return nil
+}
+
+func (m *memoryEvents) UpsertEvents(*event.UpsertEventsInput) (*event.UpsertEventsResult, error) {
+	return nil, errNotImplemented
+}
+
+func (m *memoryEvents) PruneOrganizationEvents(*event.PruneOrganizationEventsInput) (int, error) {
+	return 0, errNotImplemented
+}
+
+func (m *memoryEvents) TryLockOrganization(string) (bool, error) {
+	return true, nil
+}
+
+func (m *memoryEvents) GetRevisions(i *event.GetRevisionsInput) ([]*event.Revision, error) {
+	out := []*event.Revision{}
+	for _, r := range m.revisions {
+		if r.UID == i.UID && (i.RecurrenceID == nil || r.RecurrenceID == *i.RecurrenceID) {
+			out = append(out, r)
+		}
+	}
+
+	return out, nil
+}
+
+func (m *memoryEvents) GetRevision(id int) (*event.Revision, error) {
+	for _, r := range m.revisions {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (m *memoryEvents) Transaction(fn func(event.Repository) error) error {
+	rows := make([]event.Event, len(m.events))
+	for i, e := range m.events {
+		rows[i] = *e
+	}
+	patches := len(m.patches)
+
+	m.inTx, m.locked = true, map[string]bool{}
+	defer func() { m.inTx, m.locked = false, nil }()
+
+	if err := fn(m); err != nil {
+		for i := range rows {
+			*m.events[i] = rows[i]
+		}
+		m.patches = m.patches[:patches]
+		return err
+	}
+
+	return nil
+}
+
+// patchesTo returns the patches made to the row with the recurrence ID,
+// "" for the root
+func (m *memoryEvents) patchesTo(recurrenceID string) []event.PatchEventInput {
+	out := []event.PatchEventInput{}
+	for _, p := range m.patches {
+		rid := ""
+		if p.gi.RecurrenceID != nil {
+			rid = *p.gi.RecurrenceID
+		}
+		if rid == recurrenceID {
+			out = append(out, p.pi)
+		}
+	}
+
+	return out
+}
+
+// newTestServer returns a Server over events in the default timezone
+func newTestServer(events *memoryEvents) *Server {
+	return &Server{
+		db:     &database.Store{Events: events},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// serve calls handler with a request for /api/events/ followed by target,
+// an event's UID and optionally a query
+func serve(t *testing.T, handler http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(method, "/api/events/"+target, strings.NewReader(body))
+	uid, _, _ := strings.Cut(target, "?")
+	r.SetPathValue("uid", uid)
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	return w
+}
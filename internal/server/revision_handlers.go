@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dallasurbanists/events-sync/pkg/event"
+)
+
+// getEventHistory lists an event's revisions, newest first. The
+// recurrence_id query parameter limits them to a single instance.
+func (s *Server) getEventHistory(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	l := s.getLogger(r)
+
+	l.Debug(fmt.Sprintf("getting history for event %v", uid))
+
+	gi := &event.GetRevisionsInput{UID: uid}
+	if r.URL.Query().Has("recurrence_id") {
+		recurrenceID := r.URL.Query().Get("recurrence_id")
+		if recurrenceID != "" {
+			recurrenceID = s.normalizeRecurrenceID(recurrenceID)
+		}
+		gi.RecurrenceID = &recurrenceID
+	}
+
+	revisions, err := s.db.Events.GetRevisions(gi)
+	if err != nil {
+		l.Error(fmt.Sprintf("Failed to get history for event %v: %v", uid, err))
+		http.Error(w, fmt.Sprintf("Failed to get history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// revertEvent restores an event's moderation state (review status,
+// organization, type, manual exdates and overlays) to how it was right after
+// the given revision. Changes made by sync since then are kept.
+func (s *Server) revertEvent(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	l := s.getLogger(r)
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid revision id", http.StatusBadRequest)
+		return
+	}
+
+	l.Debug(fmt.Sprintf("reverting event %v to revision %v", uid, id))
+
+	revision, err := s.db.Events.GetRevision(id)
+	if err != nil {
+		l.Error(fmt.Sprintf("failed to get revision %v: %v", id, err))
+		http.Error(w, fmt.Sprintf("Failed to get revision: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if revision == nil || revision.UID != uid {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	}
+
+	gi := &event.GetEventInput{UID: uid, ForUpdate: true}
+	if revision.RecurrenceID != "" {
+		gi.RecurrenceID = &revision.RecurrenceID
+	}
+
+	actor := actorFromRequest(r)
+
+	// read the event and its history and write the revert in one
+	// transaction, so a concurrent moderator or sync can't slip in between
+	var notFoundErr error
+	unchanged := false
+	err = s.db.Events.Transaction(func(tx event.Repository) error {
+		existingEvent, err := tx.GetEvent(gi)
+		if err != nil {
+			notFoundErr = err
+			return err
+		}
+
+		revisions, err := tx.GetRevisions(&event.GetRevisionsInput{UID: uid, RecurrenceID: &revision.RecurrenceID})
+		if err != nil {
+			return fmt.Errorf("failed to get history: %v", err)
+		}
+
+		pi, err := event.RevertModeration(existingEvent, revisions, id)
+		if err != nil {
+			return fmt.Errorf("failed to compute revert: %v", err)
+		}
+		if pi == nil {
+			unchanged = true
+			return nil
+		}
+
+		pi.Actor = actor
+
+		l.Info(fmt.Sprintf("reverting event %v to revision %v", *gi, id))
+		patchGi := &event.GetEventInput{UID: uid, RecurrenceID: gi.RecurrenceID}
+		if err := tx.PatchEvent(patchGi, pi); err != nil {
+			return fmt.Errorf("failed to revert: %v", err)
+		}
+
+		// keep the series' manual exdates in step, as updateEvent does
+		if rejected, changed := rejectionChanged(existingEvent.ReviewStatus, pi.ReviewStatus); changed {
+			if err := s.updateRootExdate(tx, gi, rejected, actor); err != nil {
+				return fmt.Errorf("failed to update root exdate: %v", err)
+			}
+		}
+
+		return nil
+	})
+
+	switch {
+	case notFoundErr != nil:
+		l.Error(fmt.Sprintf("failed to get event %v: %v", uid, notFoundErr))
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	case err != nil:
+		l.Error(fmt.Sprintf("failed to revert %v to revision %v: %v", uid, id, err))
+		http.Error(w, fmt.Sprintf("Failed to revert: %v", err), http.StatusInternalServerError)
+		return
+	case unchanged:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Event already matches this revision",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Event reverted to revision %d", id),
+	})
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dallasurbanists/events-sync/pkg/event"
+)
+
+func TestRevertEvent(t *testing.T) {
+	events := withOverride(weeklySeries(t), event.ReviewRejected)
+	exdates := "20250310T230000Z"
+	events.events[0].ExDateManual = &exdates
+
+	moderator := event.Actor{Type: event.ActorDiscordUser, ID: "1", Name: "moderator"}
+	change := func(from, to string) map[string]event.Change {
+		return map[string]event.Change{"review_status": {From: json.RawMessage(from), To: json.RawMessage(to)}}
+	}
+	events.revisions = []*event.Revision{
+		{ID: 2, UID: "weekly@example.com", RecurrenceID: exdates, Actor: moderator, Changes: change(`"approved"`, `"rejected"`)},
+		{ID: 1, UID: "weekly@example.com", RecurrenceID: exdates, Actor: moderator, Changes: change(`"pending"`, `"approved"`)},
+	}
+
+	s := newTestServer(events)
+	revert := func(id string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/events/weekly@example.com/revisions/"+id+"/revert", nil)
+		r.SetPathValue("uid", "weekly@example.com")
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		s.revertEvent(w, r)
+		return w
+	}
+
+	if w := revert("1"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	if override := events.events[1]; override.ReviewStatus != event.ReviewApproved {
+		t.Errorf("override status = %s, want approved", override.ReviewStatus)
+	}
+	if root := events.events[0]; root.ExDateManual == nil || *root.ExDateManual != "" {
+		t.Errorf("root exdates = %v, want the override un-rejected", root.ExDateManual)
+	}
+	for _, p := range events.patches {
+		if !p.locked {
+			t.Errorf("patched %v without locking it first", p.gi)
+		}
+	}
+
+	if w := revert("3"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown revision: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
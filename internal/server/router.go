@@ -35,6 +35,13 @@ func (s *Server) newConfiguredRouter() *http.ServeMux {
 	router.Handle("GET /api/events/stats", authed_ms(http.HandlerFunc(s.getEventStats)))
 	router.Handle("POST /api/events/{uid}/overlay", authed_ms(http.HandlerFunc(s.setEventOverlay)))
 	router.Handle("DELETE /api/events/{uid}/overlay/{field}", authed_ms(http.HandlerFunc(s.removeEventOverlay)))
+	router.Handle("GET /api/events/{uid}/occurrences", authed_ms(http.HandlerFunc(s.getSeriesOccurrences)))
+	router.Handle("POST /api/events/{uid}/series/review", authed_ms(http.HandlerFunc(s.reviewSeries)))
+	router.Handle("GET /api/events/{uid}/history", authed_ms(http.HandlerFunc(s.getEventHistory)))
+	router.Handle("POST /api/events/{uid}/revisions/{id}/revert", authed_ms(http.HandlerFunc(s.revertEvent)))
+	router.Handle("GET /api/sync-runs", authed_ms(http.HandlerFunc(s.getSyncRuns)))
+	router.Handle("POST /api/organizations/{name}/sync", authed_ms(http.HandlerFunc(s.syncOrganization)))
+	router.Handle("GET /api/sync-jobs/{id}", authed_ms(http.HandlerFunc(s.getSyncJob)))
 	router.Handle("GET /api/version", open_ms(http.HandlerFunc(s.getVersion)))
 
 	// Wrap the entire router with panic recovery for public routes too
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/pkg/event"
+)
+
+// errDryRun rolls back a series review that was only previewed
+var errDryRun = errors.New("dry run")
+
+type ReviewSeriesRequest struct {
+	ReviewStatus string `json:"review_status"`
+
+	// From and To limit the review to the occurrences starting in
+	// [From, To), given as RFC 3339 times or dates. Leaving both out
+	// reviews the whole series. An open end covers event.SeriesHorizonYears
+	// from the start, and the response gives the range used.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+
+	// DryRun returns the occurrences as they would be without saving
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// getSeriesOccurrences lists a series' occurrences between the from and to
+// query parameters, by default those of the next defaultUpcomingDays days
+func (s *Server) getSeriesOccurrences(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	l := s.getLogger(r)
+
+	loc, err := s.config.Location()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load timezone: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	from, to, err := parseRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"), loc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	start, end := occurrenceWindow(from, to)
+
+	l.Debug(fmt.Sprintf("getting occurrences of %v from %v to %v", uid, start, end))
+
+	rows, err := s.db.Events.GetEvents(&event.GetEventsInput{UID: &uid})
+	if err != nil {
+		l.Error(fmt.Sprintf("Failed to get events for %v: %v", uid, err))
+		http.Error(w, fmt.Sprintf("Failed to get events: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if len(rows) == 0 {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(occurrenceResponses(rows, start, end, loc))
+}
+
+// reviewSeries sets the review status of every occurrence of a series, or
+// of those in a date range, and returns the series' occurrences afterwards
+func (s *Server) reviewSeries(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	l := s.getLogger(r)
+
+	var req ReviewSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		l.Error(fmt.Sprintf("couldn't decode request body to review series %v: %v", uid, err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := event.CheckReviewTransition(req.ReviewStatus, req.ReviewStatus); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	loc, err := s.config.Location()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load timezone: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	from, to, err := parseRange(req.From, req.To, loc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	actor := actorFromRequest(r)
+	l.Info(fmt.Sprintf("reviewing series %v as %v from %v to %v", uid, req.ReviewStatus, req.From, req.To))
+
+	var review *event.SeriesReview
+	var result []EventResponse
+	var reviewErr error
+	err = s.db.Events.Transaction(func(tx event.Repository) error {
+		rows, err := tx.GetEvents(&event.GetEventsInput{UID: &uid})
+		if err != nil {
+			return fmt.Errorf("failed to get events: %v", err)
+		}
+
+		review, err = event.ReviewSeries(rows, req.ReviewStatus, from, to, loc)
+		if err != nil {
+			reviewErr = err
+			return err
+		}
+
+		for _, p := range review.Patches {
+			p.Patch.Actor = actor
+			if err := tx.PatchEvent(&p.Event, &p.Patch); err != nil {
+				return fmt.Errorf("failed to patch %v: %v", p.Event, err)
+			}
+		}
+
+		rows, err = tx.GetEvents(&event.GetEventsInput{UID: &uid})
+		if err != nil {
+			return fmt.Errorf("failed to reload events: %v", err)
+		}
+
+		start, end := occurrenceWindow(from, to)
+		result = occurrenceResponses(rows, start, end, loc)
+
+		if req.DryRun {
+			return errDryRun
+		}
+
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, errDryRun):
+	case errors.Is(reviewErr, event.ErrNotRecurring):
+		http.Error(w, reviewErr.Error(), http.StatusNotFound)
+		return
+	case reviewErr != nil:
+		http.Error(w, reviewErr.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		l.Error(fmt.Sprintf("Failed to review series %v: %v", uid, err))
+		http.Error(w, fmt.Sprintf("Failed to review series: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success":     true,
+		"dry_run":     req.DryRun,
+		"changed":     len(review.Patches),
+		"occurrences": result,
+	}
+	if !review.To.IsZero() {
+		response["from"] = review.From
+		response["to"] = review.To
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseRange parses optional from and to values, given as RFC 3339 times
+// or as dates in loc
+func parseRange(fromValue, toValue string, loc *time.Location) (*time.Time, *time.Time, error) {
+	parse := func(name, value string) (*time.Time, error) {
+		if value == "" {
+			return nil, nil
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return &t, nil
+		}
+		t, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q, expected an RFC 3339 time or a date", name, value)
+		}
+
+		return &t, nil
+	}
+
+	from, err := parse("from", fromValue)
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := parse("to", toValue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if from != nil && to != nil && !to.After(*from) {
+		return nil, nil, fmt.Errorf("to must be after from")
+	}
+
+	return from, to, nil
+}
+
+// occurrenceWindow fills in an open range for listing occurrences, from
+// now through the next defaultUpcomingDays days
+func occurrenceWindow(from, to *time.Time) (time.Time, time.Time) {
+	start := time.Now()
+	if from != nil {
+		start = *from
+	}
+
+	end := start.AddDate(0, 0, defaultUpcomingDays)
+	if to != nil {
+		end = *to
+	}
+
+	return start, end
+}
+
+// occurrenceResponses expands a series' rows into the occurrences starting
+// in [from, to)
+func occurrenceResponses(rows []*event.Event, from, to time.Time, loc *time.Location) []EventResponse {
+	result := []EventResponse{}
+	for _, o := range event.ExpandOccurrences(rows, from, to, loc) {
+		if o.StartTime.Before(to) {
+			result = append(result, newEventResponse(o))
+		}
+	}
+
+	return result
+}
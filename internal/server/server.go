@@ -1,13 +1,16 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
 
 	"github.com/dallasurbanists/events-sync/internal/config"
 	"github.com/dallasurbanists/events-sync/internal/database"
+	"github.com/dallasurbanists/events-sync/internal/importer"
 	"github.com/dallasurbanists/events-sync/internal/logger"
+	"github.com/dallasurbanists/events-sync/internal/syncer"
 )
 
 type Server struct {
@@ -18,6 +21,7 @@ type Server struct {
 	host          string
 	port          string
 	gitCommit     string
+	syncJobs      *syncJobs
 
 	Logger        *slog.Logger
 	Server        http.Server
@@ -54,6 +58,16 @@ func NewServer(db *database.Store, o NewAppOpts) (*Server, error) {
 		Logger:        l,
 	}
 
+	s.syncJobs = newSyncJobs(&syncer.Syncer{
+		Events:    db.Events,
+		FeedCache: db.FeedCache,
+		Importers: importer.RegisterImporters(),
+		Client:    importer.NewHTTPClient(),
+		Runs:      db.SyncRuns,
+	})
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	go s.syncJobs.run(jobsCtx)
+
 	addr := o.Host
 	if o.Port != "" {
 		addr += fmt.Sprintf(":%v", o.Port)
@@ -64,5 +78,8 @@ func NewServer(db *database.Store, o NewAppOpts) (*Server, error) {
 		Handler: s.newConfiguredRouter(),
 	}
 
+	// cancel the running sync job and stop taking new ones on shutdown
+	s.Server.RegisterOnShutdown(stopJobs)
+
 	return s, nil
 }
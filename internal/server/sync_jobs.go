@@ -0,0 +1,278 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+	"github.com/dallasurbanists/events-sync/internal/syncer"
+	"github.com/dallasurbanists/events-sync/pkg/event"
+	"github.com/dallasurbanists/events-sync/pkg/syncrun"
+	"github.com/google/uuid"
+)
+
+// Sync job statuses before the job finishes; a finished job takes the
+// status of its sync run
+const (
+	SyncJobQueued  = "queued"
+	SyncJobRunning = "running"
+)
+
+// syncJobRetention is how long finished jobs stay available
+const syncJobRetention = 1 * time.Hour
+
+// syncJobEvictInterval is how often the runner drops expired jobs while
+// idle
+const syncJobEvictInterval = 5 * time.Minute
+
+// errSyncJobsStopped fails the jobs still queued when the server shuts down
+var errSyncJobsStopped = errors.New("server shut down before the sync started")
+
+// syncJobQueueSize bounds how many jobs can wait to run
+const syncJobQueueSize = 32
+
+// SyncJob is a sync of one organization requested through the API
+type SyncJob struct {
+	ID           string       `json:"id"`
+	Organization string       `json:"organization"`
+	Status       string       `json:"status"`
+	RequestedBy  event.Actor  `json:"requested_by"`
+	Queued       time.Time    `json:"queued"`
+	Started      *time.Time   `json:"started,omitempty"`
+	Finished     *time.Time   `json:"finished,omitempty"`
+	Run          *syncrun.Run `json:"run,omitempty"`
+	Error        string       `json:"error,omitempty"`
+
+	org config.Organization
+}
+
+func (j *SyncJob) done() bool {
+	return j.Status != SyncJobQueued && j.Status != SyncJobRunning
+}
+
+// syncJobs runs requested syncs one at a time, in the order they were
+// requested. Jobs are kept in memory only.
+type syncJobs struct {
+	syncer *syncer.Syncer
+	queue  chan *SyncJob
+
+	mu   sync.Mutex
+	jobs map[string]*SyncJob
+
+	// stopped is set once the runner has shut down and no job will run
+	stopped bool
+}
+
+func newSyncJobs(s *syncer.Syncer) *syncJobs {
+	return &syncJobs{
+		syncer: s,
+		queue:  make(chan *SyncJob, syncJobQueueSize),
+		jobs:   map[string]*SyncJob{},
+	}
+}
+
+// enqueue queues a sync of the organization, or returns the job already
+// waiting for or running it. The bool reports whether a new job was queued.
+func (q *syncJobs) enqueue(name string, org config.Organization, actor event.Actor) (SyncJob, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopped {
+		return SyncJob{}, false, errSyncJobsStopped
+	}
+
+	for _, j := range q.jobs {
+		if !j.done() && j.Organization == name {
+			return *j, false, nil
+		}
+	}
+
+	j := &SyncJob{
+		ID:           uuid.NewString(),
+		Organization: name,
+		Status:       SyncJobQueued,
+		RequestedBy:  actor,
+		Queued:       time.Now(),
+
+		org: org,
+	}
+
+	select {
+	case q.queue <- j:
+	default:
+		return SyncJob{}, false, fmt.Errorf("too many syncs queued")
+	}
+	q.jobs[j.ID] = j
+
+	return *j, true, nil
+}
+
+// get returns a copy of the job, or false if there is none. Expired jobs
+// are dropped first, so a job is never reported past its retention.
+func (q *syncJobs) get(id string) (SyncJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.evictLocked(time.Now())
+	j, ok := q.jobs[id]
+	if !ok {
+		return SyncJob{}, false
+	}
+
+	return *j, true
+}
+
+// run works through the queue until ctx is canceled, dropping finished jobs
+// older than syncJobRetention as it goes. Jobs still queued when ctx is
+// canceled are marked failed.
+func (q *syncJobs) run(ctx context.Context) {
+	ticker := time.NewTicker(syncJobEvictInterval)
+	defer ticker.Stop()
+
+	for {
+		// shutting down wins over starting another queued job
+		if ctx.Err() != nil {
+			q.stop()
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			q.stop()
+			return
+		case now := <-ticker.C:
+			q.evict(now)
+		case j := <-q.queue:
+			q.runJob(ctx, j)
+			q.evict(time.Now())
+		}
+	}
+}
+
+// stop refuses new jobs and fails the ones left in the queue
+func (q *syncJobs) stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.stopped = true
+	now := time.Now()
+	for {
+		select {
+		case j := <-q.queue:
+			j.Status = syncrun.StatusFailed
+			j.Finished = &now
+			j.Error = errSyncJobsStopped.Error()
+		default:
+			return
+		}
+	}
+}
+
+// runJob syncs the job's organization, marking the job failed if the sync
+// panics so the runner keeps going
+func (q *syncJobs) runJob(ctx context.Context, j *SyncJob) {
+	q.update(j, func(j *SyncJob) {
+		now := time.Now()
+		j.Status = SyncJobRunning
+		j.Started = &now
+	})
+
+	defer func() {
+		if r := recover(); r != nil {
+			q.update(j, func(j *SyncJob) {
+				now := time.Now()
+				j.Status = syncrun.StatusFailed
+				j.Finished = &now
+				j.Error = fmt.Sprintf("panic: %v", r)
+			})
+		}
+	}()
+
+	result := q.syncer.SyncOrganization(ctx, j.Organization, j.org)
+
+	q.update(j, func(j *SyncJob) {
+		j.Status = result.Status()
+		j.Finished = &result.Finished
+		j.Run = result.Run()
+		if result.Err != nil {
+			j.Error = result.Err.Error()
+		}
+	})
+}
+
+// evict drops the jobs that finished more than syncJobRetention before now
+func (q *syncJobs) evict(now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.evictLocked(now)
+}
+
+func (q *syncJobs) evictLocked(now time.Time) {
+	for id, j := range q.jobs {
+		if j.done() && now.Sub(*j.Finished) > syncJobRetention {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+func (q *syncJobs) update(j *SyncJob, fn func(*SyncJob)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	fn(j)
+}
+
+// syncOrganization queues an immediate sync of one organization and
+// responds with the job, whose URL is in the Location header
+func (s *Server) syncOrganization(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	l := s.getLogger(r)
+
+	if s.config == nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	org, ok := s.config.Organizations[name]
+	if !ok {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	job, queued, err := s.syncJobs.enqueue(name, org, actorFromRequest(r))
+	if err != nil {
+		l.Error(fmt.Sprintf("failed to queue sync of %v: %v", name, err))
+		http.Error(w, fmt.Sprintf("Failed to queue sync: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	if queued {
+		l.Info(fmt.Sprintf("queued sync job %v for %v", job.ID, name))
+	} else {
+		l.Debug(fmt.Sprintf("sync of %v already queued as job %v", name, job.ID))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/sync-jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// getSyncJob reports a sync job's status and, once it has finished, its
+// results
+func (s *Server) getSyncJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.syncJobs.get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Sync job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+	"github.com/dallasurbanists/events-sync/internal/syncer"
+	"github.com/dallasurbanists/events-sync/pkg/event"
+	"github.com/dallasurbanists/events-sync/pkg/syncrun"
+)
+
+func TestSyncJobsRun(t *testing.T) {
+	// a syncer without a feed cache panics as soon as it runs
+	q := newSyncJobs(&syncer.Syncer{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		q.run(ctx)
+		close(stopped)
+	}()
+
+	job, queued, err := q.enqueue("Dallas Urbanists", config.Organization{Importer: "ical"}, event.Actor{})
+	if err != nil || !queued {
+		t.Fatalf("enqueue() = %v, %v", queued, err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, _ = q.get(job.ID)
+		if job.done() || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Status != syncrun.StatusFailed || job.Error == "" || job.Finished == nil {
+		t.Errorf("job = %+v, want it failed with an error", job)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run didn't stop when its context was canceled")
+	}
+}
+
+func TestSyncJobsEvict(t *testing.T) {
+	now := time.Now()
+	finished := func(ago time.Duration) *time.Time {
+		t := now.Add(-ago)
+		return &t
+	}
+
+	q := newSyncJobs(nil)
+	q.jobs = map[string]*SyncJob{
+		"expired": {ID: "expired", Status: syncrun.StatusSucceeded, Finished: finished(syncJobRetention + time.Minute)},
+		"recent":  {ID: "recent", Status: syncrun.StatusFailed, Finished: finished(time.Minute)},
+		"running": {ID: "running", Status: SyncJobRunning},
+		"queued":  {ID: "queued", Status: SyncJobQueued},
+	}
+
+	q.evict(now)
+
+	for _, id := range []string{"recent", "running", "queued"} {
+		if _, ok := q.get(id); !ok {
+			t.Errorf("%s job was evicted", id)
+		}
+	}
+	if _, ok := q.get("expired"); ok {
+		t.Error("expired job was kept")
+	}
+}
+
+func TestSyncJobsStop(t *testing.T) {
+	q := newSyncJobs(&syncer.Syncer{})
+
+	job, queued, err := q.enqueue("Dallas Urbanists", config.Organization{Importer: "ical"}, event.Actor{})
+	if err != nil || !queued {
+		t.Fatalf("enqueue() = %v, %v", queued, err)
+	}
+
+	// the server shuts down before the job gets to run
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q.run(ctx)
+
+	job, ok := q.get(job.ID)
+	if !ok {
+		t.Fatal("queued job was dropped")
+	}
+	if job.Status != syncrun.StatusFailed || job.Error != errSyncJobsStopped.Error() || job.Finished == nil {
+		t.Errorf("job = %+v, want it failed by the shutdown", job)
+	}
+
+	if _, _, err := q.enqueue("Dallas Urbanists", config.Organization{Importer: "ical"}, event.Actor{}); err == nil {
+		t.Error("enqueue() after shutdown succeeded")
+	}
+}
+
+func TestSyncJobsGetEvicts(t *testing.T) {
+	expired := time.Now().Add(-syncJobRetention - time.Minute)
+
+	q := newSyncJobs(nil)
+	q.jobs = map[string]*SyncJob{
+		"expired": {ID: "expired", Status: syncrun.StatusSucceeded, Finished: &expired},
+	}
+
+	if _, ok := q.get("expired"); ok {
+		t.Error("expired job was returned")
+	}
+	if len(q.jobs) != 0 {
+		t.Errorf("%d jobs kept, want the expired one dropped", len(q.jobs))
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/dallasurbanists/events-sync/pkg/syncrun"
+)
+
+const defaultSyncRunsLimit = 50
+
+type OrganizationSyncStatus struct {
+	Organization string       `json:"organization"`
+	LastRun      *syncrun.Run `json:"last_run"`
+	LastSuccess  *syncrun.Run `json:"last_success"`
+}
+
+type SyncRunsResponse struct {
+	Organizations []OrganizationSyncStatus `json:"organizations"`
+	Runs          []*syncrun.Run           `json:"runs"`
+}
+
+// getSyncRuns lists recent sync runs, newest first, along with each
+// organization's latest run and latest successful fetch. The organization
+// and limit query parameters narrow the list of runs.
+func (s *Server) getSyncRuns(w http.ResponseWriter, r *http.Request) {
+	l := s.getLogger(r)
+
+	gi := &syncrun.GetRunsInput{Limit: defaultSyncRunsLimit}
+	if organization := r.URL.Query().Get("organization"); organization != "" {
+		gi.Organization = &organization
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		gi.Limit = n
+	}
+
+	l.Debug(fmt.Sprintf("getting sync runs %v", gi))
+	runs, err := s.db.SyncRuns.GetRuns(gi)
+	if err != nil {
+		l.Error(fmt.Sprintf("Failed to get sync runs: %v", err))
+		http.Error(w, fmt.Sprintf("Failed to get sync runs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	latest, err := s.db.SyncRuns.GetLatestRuns(nil)
+	if err != nil {
+		l.Error(fmt.Sprintf("Failed to get latest sync runs: %v", err))
+		http.Error(w, fmt.Sprintf("Failed to get latest sync runs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	succeeded, err := s.db.SyncRuns.GetLatestRuns(&syncrun.GetLatestRunsInput{Statuses: syncrun.SuccessStatuses})
+	if err != nil {
+		l.Error(fmt.Sprintf("Failed to get latest successful sync runs: %v", err))
+		http.Error(w, fmt.Sprintf("Failed to get latest successful sync runs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	statuses := map[string]*OrganizationSyncStatus{}
+	for _, run := range latest {
+		statuses[run.Organization] = &OrganizationSyncStatus{Organization: run.Organization, LastRun: run}
+	}
+	for _, run := range succeeded {
+		if status, ok := statuses[run.Organization]; ok {
+			status.LastSuccess = run
+		}
+	}
+
+	// list configured organizations that have never been synced too
+	if s.config != nil {
+		for name := range s.config.Organizations {
+			if _, ok := statuses[name]; !ok {
+				statuses[name] = &OrganizationSyncStatus{Organization: name}
+			}
+		}
+	}
+
+	resp := SyncRunsResponse{Organizations: []OrganizationSyncStatus{}, Runs: runs}
+	for _, status := range statuses {
+		resp.Organizations = append(resp.Organizations, *status)
+	}
+	sort.Slice(resp.Organizations, func(i, j int) bool {
+		return resp.Organizations[i].Organization < resp.Organizations[j].Organization
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
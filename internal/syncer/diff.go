@@ -0,0 +1,258 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+	"github.com/dallasurbanists/events-sync/internal/importer"
+	"github.com/dallasurbanists/events-sync/pkg/event"
+)
+
+// Diff actions
+const (
+	DiffNew      = "new"
+	DiffChanged  = "changed"
+	DiffRestored = "restored"
+	DiffPruned   = "pruned"
+)
+
+// EventDiff is what a sync would do to one event
+type EventDiff struct {
+	Action       string                  `json:"action"`
+	UID          string                  `json:"uid"`
+	RecurrenceID string                  `json:"recurrence_id,omitempty"`
+	Summary      string                  `json:"summary"`
+	StartTime    time.Time               `json:"start_time"`
+	Changes      map[string]event.Change `json:"changes,omitempty"`
+
+	// Significant lists the changed fields that send the event back for
+	// review
+	Significant []string `json:"significant,omitempty"`
+}
+
+// OrganizationDiff is what a sync of one organization would do
+type OrganizationDiff struct {
+	Organization string      `json:"organization"`
+	Found        int         `json:"found"`
+	Unchanged    int         `json:"unchanged"`
+	Events       []EventDiff `json:"events"`
+
+	// PruneBlocked explains why the prune would be refused, if it would
+	PruneBlocked string `json:"prune_blocked,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// DiffAll works out what SyncAll would do without writing anything,
+// fetching every feed in full
+func (s *Syncer) DiffAll(ctx context.Context, orgs map[string]config.Organization, concurrency int) []OrganizationDiff {
+	names := sortedNames(orgs)
+	diffs := make([]OrganizationDiff, len(names))
+
+	errs := forEach(len(names), concurrency, func(i int) {
+		diffs[i] = s.DiffOrganization(ctx, names[i], orgs[names[i]])
+	})
+	for i, err := range errs {
+		if err != nil {
+			diffs[i] = OrganizationDiff{Organization: names[i], Events: []EventDiff{}, Error: err.Error()}
+		}
+	}
+
+	return diffs
+}
+
+// DiffOrganization works out what SyncOrganization would do without
+// writing anything
+func (s *Syncer) DiffOrganization(ctx context.Context, name string, org config.Organization) OrganizationDiff {
+	diff := OrganizationDiff{Organization: name, Events: []EventDiff{}}
+
+	// no validators, so the feed is fetched even if it hasn't changed
+	source := importer.NewSource(name, org)
+	events, err := s.Importers.Import(ctx, s.Client, source)
+	if err != nil {
+		diff.Error = fmt.Sprintf("import failed: %v", err)
+		return diff
+	}
+	diff.Found = len(events)
+
+	if err := diffEvents(&diff, events, s.Events, org.PruneLimit()); err != nil {
+		diff.Error = err.Error()
+	}
+
+	return diff
+}
+
+// diffEvents mirrors syncEvents, recording each write it would make
+func diffEvents(diff *OrganizationDiff, events []*event.Event, repo event.Repository, pruneThreshold int) error {
+	inSource := map[string]bool{}
+
+	for _, newEvent := range events {
+		gi := getEventInput(newEvent)
+		gi.IncludeDeleted = true
+		inSource[eventKey(newEvent)] = true
+
+		var noEventsError event.NoEventsError
+		existingEvent, err := repo.GetEvent(&gi)
+		if errors.As(err, &noEventsError) {
+			diff.Events = append(diff.Events, newEventDiff(DiffNew, newEvent))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check existing event: %v", err)
+		}
+
+		// UpsertEvents leaves rows with the same fingerprint alone
+		if !event.NeedsSync(existingEvent, newEvent.SourceFingerprint()) {
+			diff.Unchanged++
+			continue
+		}
+
+		changes, err := event.Diff(existingEvent, applySync(existingEvent, newEvent))
+		if err != nil {
+			return err
+		}
+
+		action := DiffChanged
+		if existingEvent.DeletedAt != nil {
+			action = DiffRestored
+		} else if len(changes) == 0 {
+			diff.Unchanged++
+			continue
+		}
+
+		d := newEventDiff(action, newEvent)
+		d.Changes = changes
+		d.Significant = event.SignificantChanges(existingEvent, newEvent)
+		diff.Events = append(diff.Events, d)
+	}
+
+	existing, err := repo.GetEvents(&event.GetEventsInput{Organization: &diff.Organization})
+	if err != nil {
+		return fmt.Errorf("failed to get events for organization %s: %v", diff.Organization, err)
+	}
+
+	pruning := []EventDiff{}
+	for _, e := range existing {
+		if !inSource[eventKey(e)] {
+			pruning = append(pruning, newEventDiff(DiffPruned, e))
+		}
+	}
+
+	if event.PruneExceedsThreshold(len(pruning), len(existing), pruneThreshold) {
+		diff.PruneBlocked = event.PruneBlockedError{
+			Organization: diff.Organization,
+			Pruning:      len(pruning),
+			Total:        len(existing),
+			Threshold:    pruneThreshold,
+		}.Error()
+	} else {
+		diff.Events = append(diff.Events, pruning...)
+	}
+
+	return nil
+}
+
+// applySync returns existing as UpsertEvents would leave it after syncing
+// newEvent over it, following event.SyncColumns: the coalesced fields keep
+// their stored value when newEvent leaves them out
+func applySync(existing *event.Event, newEvent *event.Event) *event.Event {
+	synced := *existing
+
+	synced.Summary = newEvent.Summary
+	synced.AllDay = newEvent.AllDay
+	synced.Sequence = newEvent.Sequence
+	synced.DeletedAt = nil
+
+	// keep the stored value for the same instant, so a different time zone
+	// alone doesn't show up as a change
+	if !synced.StartTime.Equal(newEvent.StartTime) {
+		synced.StartTime = newEvent.StartTime
+	}
+	if !synced.EndTime.Equal(newEvent.EndTime) {
+		synced.EndTime = newEvent.EndTime
+	}
+
+	// nil fields are left as they are
+	if newEvent.Description != nil {
+		synced.Description = newEvent.Description
+	}
+	if newEvent.Location != nil {
+		synced.Location = newEvent.Location
+	}
+	if newEvent.Status != nil {
+		synced.Status = newEvent.Status
+	}
+	if newEvent.Transparency != nil {
+		synced.Transparency = newEvent.Transparency
+	}
+	if newEvent.URL != nil {
+		synced.URL = newEvent.URL
+	}
+	if newEvent.RRule != nil {
+		synced.RRule = newEvent.RRule
+	}
+	if newEvent.RDate != nil {
+		synced.RDate = newEvent.RDate
+	}
+	if newEvent.ExDate != nil {
+		synced.ExDate = newEvent.ExDate
+	}
+	if newEvent.TZID != nil {
+		synced.TZID = newEvent.TZID
+	}
+	if newEvent.Modified != nil && (synced.Modified == nil || !synced.Modified.Equal(*newEvent.Modified)) {
+		synced.Modified = newEvent.Modified
+	}
+
+	if len(event.SignificantChanges(existing, newEvent)) > 0 &&
+		(existing.ReviewStatus == event.ReviewApproved || existing.ReviewStatus == event.ReviewRejected) {
+		synced.ReviewStatus = event.ReviewNeedsReview
+		synced.Rejected = false
+	}
+
+	return &synced
+}
+
+func newEventDiff(action string, e *event.Event) EventDiff {
+	d := EventDiff{
+		Action:    action,
+		UID:       e.UID,
+		Summary:   e.Summary,
+		StartTime: e.StartTime,
+	}
+	if e.RecurrenceID != nil {
+		d.RecurrenceID = *e.RecurrenceID
+	}
+
+	return d
+}
+
+func getEventInput(e *event.Event) event.GetEventInput {
+	gi := event.GetEventInput{UID: e.UID}
+	if e.RecurrenceID != nil && *e.RecurrenceID != "" {
+		gi.RecurrenceID = e.RecurrenceID
+	}
+
+	return gi
+}
+
+func eventKey(e *event.Event) string {
+	if e.RecurrenceID != nil && *e.RecurrenceID != "" {
+		return e.UID + ":" + *e.RecurrenceID
+	}
+
+	return e.UID
+}
+
+func sortedNames(orgs map[string]config.Organization) []string {
+	names := make([]string, 0, len(orgs))
+	for name := range orgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,257 @@
+package syncer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/pkg/event"
+)
+
+func TestDiffEvents(t *testing.T) {
+	start := time.Date(2025, 3, 10, 18, 0, 0, 0, time.UTC)
+	deleted := start.Add(-24 * time.Hour)
+
+	source := func(uid, summary string) *event.Event {
+		return &event.Event{
+			UID:          uid,
+			Organization: "Dallas Urbanists",
+			Summary:      summary,
+			StartTime:    start,
+			EndTime:      start.Add(time.Hour),
+		}
+	}
+	stored := func(e *event.Event, fingerprinted bool) *event.Event {
+		s := *e
+		s.ReviewStatus = event.ReviewApproved
+		if fingerprinted {
+			fingerprint := e.SourceFingerprint()
+			s.Fingerprint = &fingerprint
+		}
+		return &s
+	}
+
+	restored := stored(source("restored", "Restored"), true)
+	restored.DeletedAt = &deleted
+
+	repo := newFakeRepository(
+		stored(source("same", "Same"), true),
+		// synced before fingerprints were stored
+		stored(source("legacy", "Legacy"), false),
+		stored(source("changed", "Old title"), true),
+		restored,
+		stored(source("gone", "Gone"), true),
+	)
+
+	events := []*event.Event{
+		source("new", "New"),
+		source("same", "Same"),
+		source("legacy", "Legacy"),
+		source("changed", "New title"),
+		source("restored", "Restored"),
+	}
+
+	diff := OrganizationDiff{Organization: "Dallas Urbanists", Events: []EventDiff{}}
+	if err := diffEvents(&diff, events, repo, 50); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, d := range diff.Events {
+		got[d.UID] = d.Action
+	}
+	want := map[string]string{
+		"new":      DiffNew,
+		"changed":  DiffChanged,
+		"restored": DiffRestored,
+		"gone":     DiffPruned,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("actions = %v, want %v", got, want)
+	}
+	if diff.Unchanged != 2 {
+		t.Errorf("Unchanged = %d, want 2", diff.Unchanged)
+	}
+	if diff.PruneBlocked != "" {
+		t.Errorf("PruneBlocked = %q, want the prune allowed", diff.PruneBlocked)
+	}
+
+	for _, d := range diff.Events {
+		if d.UID != "changed" {
+			continue
+		}
+		if _, ok := d.Changes["summary"]; !ok {
+			t.Errorf("changes = %v, want the summary", d.Changes)
+		}
+		if c, ok := d.Changes["review_status"]; !ok || string(c.To) != `"needs_review"` {
+			t.Errorf("review_status change = %+v, want needs_review", c)
+		}
+		if !reflect.DeepEqual(d.Significant, []string{"summary"}) {
+			t.Errorf("Significant = %v, want [summary]", d.Significant)
+		}
+	}
+
+	t.Run("empty feed", func(t *testing.T) {
+		diff := OrganizationDiff{Organization: "Dallas Urbanists", Events: []EventDiff{}}
+		if err := diffEvents(&diff, nil, repo, 50); err != nil {
+			t.Fatal(err)
+		}
+		if diff.PruneBlocked == "" || len(diff.Events) != 0 {
+			t.Errorf("got %d events and PruneBlocked %q, want the prune blocked", len(diff.Events), diff.PruneBlocked)
+		}
+	})
+}
+
+func TestApplySync(t *testing.T) {
+	str := func(s string) *string { return &s }
+	start := time.Date(2025, 3, 10, 18, 0, 0, 0, time.UTC)
+
+	// the event field behind each of event.SyncColumns: set gives it one
+	// of two values and clear leaves it out, as a source can
+	fields := map[string]struct {
+		set   func(e *event.Event, n int)
+		clear func(e *event.Event)
+		get   func(e *event.Event) interface{}
+	}{
+		"summary": {
+			func(e *event.Event, n int) { e.Summary = fmt.Sprint("summary ", n) },
+			func(e *event.Event) { e.Summary = "" },
+			func(e *event.Event) interface{} { return e.Summary },
+		},
+		"description": {
+			func(e *event.Event, n int) { e.Description = str(fmt.Sprint("description ", n)) },
+			func(e *event.Event) { e.Description = nil },
+			func(e *event.Event) interface{} { return e.Description },
+		},
+		"location": {
+			func(e *event.Event, n int) { e.Location = str(fmt.Sprint("location ", n)) },
+			func(e *event.Event) { e.Location = nil },
+			func(e *event.Event) interface{} { return e.Location },
+		},
+		"start_time": {
+			func(e *event.Event, n int) { e.StartTime = start.Add(time.Duration(n) * time.Hour) },
+			func(e *event.Event) { e.StartTime = time.Time{} },
+			func(e *event.Event) interface{} { return e.StartTime },
+		},
+		"end_time": {
+			func(e *event.Event, n int) { e.EndTime = start.Add(time.Duration(n) * time.Hour) },
+			func(e *event.Event) { e.EndTime = time.Time{} },
+			func(e *event.Event) interface{} { return e.EndTime },
+		},
+		"all_day": {
+			func(e *event.Event, n int) { e.AllDay = n == 2 },
+			func(e *event.Event) { e.AllDay = false },
+			func(e *event.Event) interface{} { return e.AllDay },
+		},
+		"tzid": {
+			func(e *event.Event, n int) { e.TZID = str([]string{"", "America/Chicago", "America/Denver"}[n]) },
+			func(e *event.Event) { e.TZID = nil },
+			func(e *event.Event) interface{} { return e.TZID },
+		},
+		"modified_time": {
+			func(e *event.Event, n int) { t := start.Add(time.Duration(n) * time.Minute); e.Modified = &t },
+			func(e *event.Event) { e.Modified = nil },
+			func(e *event.Event) interface{} { return e.Modified },
+		},
+		"status": {
+			func(e *event.Event, n int) { e.Status = str([]string{"", "CONFIRMED", "CANCELLED"}[n]) },
+			func(e *event.Event) { e.Status = nil },
+			func(e *event.Event) interface{} { return e.Status },
+		},
+		"transparency": {
+			func(e *event.Event, n int) { e.Transparency = str([]string{"", "OPAQUE", "TRANSPARENT"}[n]) },
+			func(e *event.Event) { e.Transparency = nil },
+			func(e *event.Event) interface{} { return e.Transparency },
+		},
+		"url": {
+			func(e *event.Event, n int) { e.URL = str(fmt.Sprint("https://example.com/", n)) },
+			func(e *event.Event) { e.URL = nil },
+			func(e *event.Event) interface{} { return e.URL },
+		},
+		"sequence": {
+			func(e *event.Event, n int) { e.Sequence = n },
+			func(e *event.Event) { e.Sequence = 0 },
+			func(e *event.Event) interface{} { return e.Sequence },
+		},
+		"rrule": {
+			func(e *event.Event, n int) { e.RRule = str(fmt.Sprint("FREQ=WEEKLY;INTERVAL=", n)) },
+			func(e *event.Event) { e.RRule = nil },
+			func(e *event.Event) interface{} { return e.RRule },
+		},
+		"rdate": {
+			func(e *event.Event, n int) { e.RDate = str(fmt.Sprintf("2025031%dT180000Z", n)) },
+			func(e *event.Event) { e.RDate = nil },
+			func(e *event.Event) interface{} { return e.RDate },
+		},
+		"exdate": {
+			func(e *event.Event, n int) { e.ExDate = str(fmt.Sprintf("2025031%dT180000Z", n)) },
+			func(e *event.Event) { e.ExDate = nil },
+			func(e *event.Event) interface{} { return e.ExDate },
+		},
+	}
+
+	for _, c := range event.SyncColumns {
+		// the fingerprint decides whether the row is written at all, see
+		// TestDiffEvents
+		if c.Name == "fingerprint" {
+			continue
+		}
+
+		f, ok := fields[c.Name]
+		if !ok {
+			t.Errorf("no field for the %s column; add it here and to applySync", c.Name)
+			continue
+		}
+
+		t.Run(c.Name, func(t *testing.T) {
+			existing := &event.Event{UID: "1", ReviewStatus: event.ReviewPending}
+			f.set(existing, 1)
+
+			newEvent := &event.Event{UID: "1"}
+			f.set(newEvent, 2)
+			if got, want := f.get(applySync(existing, newEvent)), f.get(newEvent); !reflect.DeepEqual(got, want) {
+				t.Errorf("changed value synced as %v, want %v", got, want)
+			}
+
+			newEvent = &event.Event{UID: "1"}
+			f.clear(newEvent)
+			want := f.get(newEvent)
+			if c.Coalesce {
+				want = f.get(existing)
+			}
+			if got := f.get(applySync(existing, newEvent)); !reflect.DeepEqual(got, want) {
+				t.Errorf("left out value synced as %v, want %v (coalesced %v)", got, want, c.Coalesce)
+			}
+		})
+	}
+
+	t.Run("review status", func(t *testing.T) {
+		tests := []struct {
+			stored  string
+			summary string
+			want    string
+		}{
+			{event.ReviewApproved, "New title", event.ReviewNeedsReview},
+			{event.ReviewRejected, "New title", event.ReviewNeedsReview},
+			{event.ReviewPending, "New title", event.ReviewPending},
+			{event.ReviewApproved, "Title", event.ReviewApproved},
+		}
+
+		for _, tt := range tests {
+			existing := &event.Event{UID: "1", Summary: "Title", ReviewStatus: tt.stored, Rejected: tt.stored == event.ReviewRejected}
+			synced := applySync(existing, &event.Event{UID: "1", Summary: tt.summary})
+			if synced.ReviewStatus != tt.want || synced.Rejected != (tt.want == event.ReviewRejected) {
+				t.Errorf("%s event synced to %q: %s, want %s", tt.stored, tt.summary, synced.ReviewStatus, tt.want)
+			}
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		deleted := start
+		existing := &event.Event{UID: "1", DeletedAt: &deleted, ReviewStatus: event.ReviewApproved}
+		if synced := applySync(existing, &event.Event{UID: "1"}); synced.DeletedAt != nil {
+			t.Errorf("DeletedAt = %v, want the event restored", synced.DeletedAt)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// DefaultRetryBase is the first retry delay after a failed sync; it
+	// doubles with each further failure up to DefaultRetryMax
+	DefaultRetryBase = 1 * time.Minute
+	DefaultRetryMax  = 30 * time.Minute
+
+	// DefaultShutdownTimeout is how long a stopping Scheduler waits for
+	// running syncs before canceling them
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+// Scheduler syncs each organization on its own interval or cron schedule
+// until its context is canceled
+type Scheduler struct {
+	Syncer        *Syncer
+	Organizations map[string]config.Organization
+	Concurrency   int
+
+	// RetryBase and RetryMax bound the jittered backoff after a failed sync.
+	// A retry never comes later than the next scheduled sync.
+	RetryBase time.Duration
+	RetryMax  time.Duration
+
+	ShutdownTimeout time.Duration
+
+	// OnResult, when set, is called after every sync with the time of the
+	// organization's next one
+	OnResult func(result Result, next time.Time)
+}
+
+// Run schedules every organization and blocks until ctx is canceled. Syncs
+// already running are then given ShutdownTimeout to finish before they are
+// canceled too.
+func (s *Scheduler) Run(ctx context.Context) error {
+	schedules := map[string]cron.Schedule{}
+	for name, org := range s.Organizations {
+		schedule, err := org.SyncSchedule()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		schedules[name] = schedule
+	}
+
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	slots := make(chan struct{}, concurrency)
+
+	// syncs get their own context so stopping the scheduler doesn't abort
+	// them halfway
+	syncCtx, cancelSyncs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelSyncs()
+
+	var wg sync.WaitGroup
+	for _, name := range sortedNames(s.Organizations) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runOrganization(ctx, syncCtx, slots, name, schedules[name])
+		}()
+	}
+
+	<-ctx.Done()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		fmt.Printf("Warning: syncs still running after %v, canceling them\n", timeout)
+		cancelSyncs()
+		<-done
+	}
+
+	return nil
+}
+
+// runOrganization syncs one organization whenever it is due, holding one of
+// slots while it runs
+func (s *Scheduler) runOrganization(ctx context.Context, syncCtx context.Context, slots chan struct{}, name string, schedule cron.Schedule) {
+	org := s.Organizations[name]
+	failures := 0
+
+	// an interval starts with a sync; a cron schedule waits for its first time
+	next := time.Now()
+	if _, ok := schedule.(cron.ConstantDelaySchedule); !ok {
+		next = schedule.Next(next)
+	}
+
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		// a panicking sync fails this run like any other error, rather than
+		// holding its slot and stopping the organization's schedule
+		started := time.Now()
+		var result Result
+		if err := recovered(func() { result = s.Syncer.SyncOrganization(syncCtx, name, org) }); err != nil {
+			result = Result{Organization: name, Started: started, Finished: time.Now(), Err: err}
+		}
+		<-slots
+
+		next = schedule.Next(started)
+		if result.Err != nil {
+			failures++
+			if retry := time.Now().Add(s.backoff(failures)); retry.Before(next) {
+				next = retry
+			}
+		} else {
+			failures = 0
+		}
+
+		if s.OnResult != nil {
+			s.OnResult(result, next)
+		}
+	}
+}
+
+// backoff returns the delay before retrying after the given number of
+// consecutive failures: exponential from RetryBase, capped at RetryMax, with
+// "equal jitter" so organizations failing together don't retry together
+func (s *Scheduler) backoff(failures int) time.Duration {
+	base, limit := s.RetryBase, s.RetryMax
+	if base <= 0 {
+		base = DefaultRetryBase
+	}
+	if limit <= 0 {
+		limit = DefaultRetryMax
+	}
+
+	d := base
+	for i := 1; i < failures && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+
+	return d/2 + rand.N(d/2+1)
+}
@@ -0,0 +1,94 @@
+package syncer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+)
+
+func TestSchedulerRecoversPanics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	failed := map[string]bool{}
+	all := make(chan struct{})
+
+	// a nil Syncer panics on every sync. With a single slot, the second
+	// organization only runs if the first one's panic released it.
+	s := &Scheduler{
+		Organizations: map[string]config.Organization{
+			"Alpha": {Interval: config.Duration{Duration: time.Hour}},
+			"Bravo": {Interval: config.Duration{Duration: time.Hour}},
+		},
+		Concurrency: 1,
+		OnResult: func(result Result, next time.Time) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if result.Err == nil {
+				t.Errorf("%s: expected the panic as an error", result.Organization)
+			}
+			failed[result.Organization] = true
+			if len(failed) == 2 {
+				close(all)
+			}
+		},
+	}
+
+	stopped := make(chan error)
+	go func() { stopped <- s.Run(ctx) }()
+
+	select {
+	case <-all:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("only %v reported a result", failed)
+	}
+
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Errorf("Run() = %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     time.Duration
+		max      time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{"first failure", time.Minute, 30 * time.Minute, 1, time.Minute},
+		{"second failure doubles", time.Minute, 30 * time.Minute, 2, 2 * time.Minute},
+		{"fourth failure", time.Minute, 30 * time.Minute, 4, 8 * time.Minute},
+		{"capped", time.Minute, 30 * time.Minute, 6, 30 * time.Minute},
+		{"stays capped", time.Minute, 30 * time.Minute, 100, 30 * time.Minute},
+		{"defaults", 0, 0, 3, 4 * DefaultRetryBase},
+		{"default cap", 0, 0, 50, DefaultRetryMax},
+		{"custom base", 10 * time.Second, time.Minute, 3, 40 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scheduler{RetryBase: tt.base, RetryMax: tt.max}
+
+			// equal jitter keeps at least half the delay
+			lowest, highest := tt.want, time.Duration(0)
+			for i := 0; i < 1000; i++ {
+				d := s.backoff(tt.failures)
+				if d < tt.want/2 || d > tt.want {
+					t.Fatalf("backoff(%d) = %v, want within [%v, %v]", tt.failures, d, tt.want/2, tt.want)
+				}
+				lowest, highest = min(lowest, d), max(highest, d)
+			}
+
+			if highest-lowest < tt.want/4 {
+				t.Errorf("backoff(%d) only ranged over [%v, %v], want jitter across [%v, %v]", tt.failures, lowest, highest, tt.want/2, tt.want)
+			}
+		})
+	}
+}
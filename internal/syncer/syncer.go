@@ -0,0 +1,412 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+	"github.com/dallasurbanists/events-sync/internal/importer"
+	"github.com/dallasurbanists/events-sync/pkg/event"
+	"github.com/dallasurbanists/events-sync/pkg/feed"
+	"github.com/dallasurbanists/events-sync/pkg/syncrun"
+	"github.com/google/uuid"
+)
+
+// DefaultConcurrency is how many organizations sync at once when the
+// config doesn't say otherwise
+const DefaultConcurrency = 4
+
+// errLocked aborts a sync's transaction when another sync of the same
+// organization holds its lock
+var errLocked = errors.New("organization is being synced by another run")
+
+// Syncer imports organizations' events and applies them to the database
+type Syncer struct {
+	Events    event.Repository
+	FeedCache feed.Repository
+	Importers importer.Importers
+	Client    *http.Client
+
+	// Runs, when set, records every organization's sync as it starts and
+	// finishes
+	Runs syncrun.Repository
+}
+
+// Result is the outcome of syncing one organization
+type Result struct {
+	Organization string
+
+	// ID identifies this sync of the organization in sync_runs and event
+	// revisions. RunID is shared by every organization synced together.
+	ID    string
+	RunID string
+
+	Started  time.Time
+	Finished time.Time
+
+	// HTTPStatus is the status of the source's last response, 0 if it was
+	// never reached
+	HTTPStatus int
+
+	Found     int
+	Inserted  int
+	Updated   int
+	Pruned    int
+	Restored  int
+	Unchanged bool
+
+	// Skipped is set when another sync of the organization was already
+	// writing its events, so this one left them alone
+	Skipped bool
+
+	// PruneBlocked is set when the prune was skipped for removing more
+	// events than the organization's threshold allows
+	PruneBlocked error
+
+	Err error
+}
+
+// Status summarizes the result as one of the syncrun statuses
+func (r Result) Status() string {
+	switch {
+	case r.Finished.IsZero():
+		return syncrun.StatusRunning
+	case r.Err != nil:
+		return syncrun.StatusFailed
+	case r.Unchanged:
+		return syncrun.StatusUnchanged
+	case r.Skipped:
+		return syncrun.StatusSkipped
+	case r.PruneBlocked != nil:
+		return syncrun.StatusPruneBlocked
+	}
+
+	return syncrun.StatusSucceeded
+}
+
+// Run converts the result into its sync_runs record
+func (r Result) Run() *syncrun.Run {
+	run := &syncrun.Run{
+		ID:           r.ID,
+		RunID:        r.RunID,
+		Organization: r.Organization,
+		Status:       r.Status(),
+		StartedAt:    r.Started,
+		Found:        r.Found,
+		Inserted:     r.Inserted,
+		Updated:      r.Updated,
+		Pruned:       r.Pruned,
+		Restored:     r.Restored,
+	}
+
+	if !r.Finished.IsZero() {
+		finished := r.Finished
+		run.FinishedAt = &finished
+	}
+
+	if r.HTTPStatus != 0 {
+		status := r.HTTPStatus
+		run.HTTPStatus = &status
+	}
+
+	err := r.Err
+	if err == nil {
+		err = r.PruneBlocked
+	}
+	if err != nil {
+		msg := err.Error()
+		run.Error = &msg
+	}
+
+	return run
+}
+
+// Stats counts the writes made while syncing an organization's events
+type Stats struct {
+	Inserted int
+	Updated  int
+	Pruned   int
+	Restored int
+
+	// UnchangedEvents counts the events already stored as the source has them
+	UnchangedEvents int
+
+	PruneBlocked error
+}
+
+// SyncAll syncs every organization using a pool of concurrency workers and
+// returns the results ordered by organization name. An organization's
+// failure is recorded in its result and never stops the others.
+func (s *Syncer) SyncAll(ctx context.Context, orgs map[string]config.Organization, concurrency int) []Result {
+	names := sortedNames(orgs)
+	runID := uuid.NewString()
+	results := make([]Result, len(names))
+
+	errs := forEach(len(names), concurrency, func(i int) {
+		results[i] = s.syncOrganization(ctx, runID, names[i], orgs[names[i]])
+	})
+	for i, err := range errs {
+		if err != nil {
+			now := time.Now()
+			results[i] = Result{Organization: names[i], RunID: runID, Started: now, Finished: now, Err: err}
+		}
+	}
+
+	return results
+}
+
+// forEach calls fn for 0..n-1 from a pool of concurrency workers and waits
+// for every call to return. A call that panics doesn't stop its worker; the
+// panic is returned as the error at its index.
+func forEach(n int, concurrency int, fn func(i int)) []error {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	errs := make([]error, n)
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = recovered(func() { fn(i) })
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return errs
+}
+
+// recovered calls fn, returning a panic as an error
+func recovered(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	fn()
+	return nil
+}
+
+// SyncOrganization imports a single organization and applies its events
+func (s *Syncer) SyncOrganization(ctx context.Context, name string, org config.Organization) Result {
+	return s.syncOrganization(ctx, uuid.NewString(), name, org)
+}
+
+func (s *Syncer) syncOrganization(ctx context.Context, runID string, name string, org config.Organization) (result Result) {
+	result = Result{Organization: name, ID: uuid.NewString(), RunID: runID, Started: time.Now()}
+	s.saveRun(result)
+	defer func() {
+		// record a panic as this organization's failure rather than leaving
+		// its run marked running
+		if r := recover(); r != nil {
+			result.Err = fmt.Errorf("panic: %v", r)
+		}
+		result.Finished = time.Now()
+		s.saveRun(result)
+	}()
+
+	if err := ctx.Err(); err != nil {
+		result.Err = fmt.Errorf("sync cancelled before start: %v", err)
+		return result
+	}
+
+	fmt.Printf("Processing organization: %s\n", name)
+
+	source := importer.NewSource(name, org)
+	validators, err := s.FeedCache.GetValidators(name)
+	if err != nil {
+		fmt.Printf("Warning: could not load feed cache for %s: %v\n", name, err)
+	}
+	if validators == nil {
+		validators = &feed.Validators{Organization: name}
+	}
+	source.Validators = validators
+	source.HTTPStatus = &result.HTTPStatus
+
+	events, err := s.Importers.Import(ctx, s.Client, source)
+	if errors.Is(err, importer.ErrNotModified) {
+		fmt.Printf("No changes for %s since last sync\n", name)
+		result.Unchanged = true
+		s.saveValidators(source.Validators)
+		return result
+	}
+	if err != nil {
+		result.Err = fmt.Errorf("import failed: %v", err)
+		return result
+	}
+	result.Found = len(events)
+
+	actor := event.Actor{Type: event.ActorSync, ID: result.ID, Name: name}
+
+	// apply the import and its prune atomically, so a failure part way
+	// through leaves the organization exactly as it was. The organization
+	// is locked for the transaction so overlapping runs can't both write it.
+	var stats Stats
+	err = s.Events.Transaction(func(repo event.Repository) error {
+		locked, err := repo.TryLockOrganization(name)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return errLocked
+		}
+
+		stats, err = syncEvents(name, events, repo, org.PruneLimit(), actor)
+		return err
+	})
+	if errors.Is(err, errLocked) {
+		fmt.Printf("Skipping %s: %v\n", name, err)
+		result.Skipped = true
+		return result
+	}
+	if err != nil {
+		result.Err = fmt.Errorf("sync failed, changes rolled back: %v", err)
+		return result
+	}
+	result.Inserted, result.Updated, result.Pruned = stats.Inserted, stats.Updated, stats.Pruned
+	result.Restored = stats.Restored
+	result.PruneBlocked = stats.PruneBlocked
+
+	// only remember validators once the events behind them are stored,
+	// otherwise a failed sync would be skipped as unchanged next run. A
+	// blocked prune is retried against the same content next time.
+	if stats.PruneBlocked == nil {
+		s.saveValidators(source.Validators)
+	}
+
+	fmt.Printf("Found %d events for %s, %d unchanged\n", len(events), name, stats.UnchangedEvents)
+
+	return result
+}
+
+// PruneOrganization imports the organization's feed and prunes the stored
+// events it no longer lists, without inserting or updating anything.
+// threshold overrides the organization's prune threshold unless negative,
+// and 0 prunes with no limit. The result is skipped when another run holds
+// the organization's lock.
+func (s *Syncer) PruneOrganization(ctx context.Context, name string, org config.Organization, threshold int) (result Result) {
+	result = Result{Organization: name, ID: uuid.NewString(), Started: time.Now()}
+	defer func() {
+		result.Finished = time.Now()
+	}()
+
+	source := importer.NewSource(name, org)
+	source.HTTPStatus = &result.HTTPStatus
+	events, err := s.Importers.Import(ctx, s.Client, source)
+	if err != nil {
+		result.Err = fmt.Errorf("import failed: %v", err)
+		return result
+	}
+	result.Found = len(events)
+
+	if threshold < 0 {
+		threshold = org.PruneLimit()
+	}
+
+	pi := event.PruneOrganizationEventsInput{
+		Organization:   name,
+		ExistingEvents: []event.GetEventInput{},
+		Threshold:      threshold,
+		Actor:          event.Actor{Type: event.ActorSync, ID: result.ID, Name: name},
+	}
+	for _, e := range events {
+		pi.ExistingEvents = append(pi.ExistingEvents, getEventInput(e))
+	}
+
+	err = s.Events.Transaction(func(repo event.Repository) error {
+		locked, err := repo.TryLockOrganization(name)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return errLocked
+		}
+
+		result.Pruned, err = repo.PruneOrganizationEvents(&pi)
+		return err
+	})
+	if errors.Is(err, errLocked) {
+		fmt.Printf("Skipping %s: %v\n", name, err)
+		result.Skipped = true
+		return result
+	}
+	if err != nil {
+		result.Err = err
+	}
+
+	return result
+}
+
+// saveRun records the result in sync_runs, if the syncer keeps a history
+func (s *Syncer) saveRun(result Result) {
+	if s.Runs == nil {
+		return
+	}
+
+	if err := s.Runs.SaveRun(result.Run()); err != nil {
+		fmt.Printf("Warning: could not record sync run for %s: %v\n", result.Organization, err)
+	}
+}
+
+// saveValidators persists the validators an importer recorded. Importers
+// that don't use conditional requests leave the URL empty and are skipped.
+func (s *Syncer) saveValidators(v *feed.Validators) {
+	if v == nil || v.URL == "" {
+		return
+	}
+
+	if err := s.FeedCache.SaveValidators(v); err != nil {
+		fmt.Printf("Warning: could not save feed cache for %s: %v\n", v.Organization, err)
+	}
+}
+
+func syncEvents(organization string, events []*event.Event, repo event.Repository, pruneThreshold int, actor event.Actor) (Stats, error) {
+	stats := Stats{}
+
+	upserted, err := repo.UpsertEvents(&event.UpsertEventsInput{Events: events, Actor: actor})
+	if err != nil {
+		return stats, err
+	}
+	stats.Inserted, stats.Updated, stats.Restored = upserted.Inserted, upserted.Updated, upserted.Restored
+	stats.UnchangedEvents = upserted.Unchanged
+
+	pi := event.PruneOrganizationEventsInput{
+		Organization:   organization,
+		ExistingEvents: []event.GetEventInput{},
+		Threshold:      pruneThreshold,
+		Actor:          actor,
+	}
+
+	for _, e := range events {
+		pi.ExistingEvents = append(pi.ExistingEvents, getEventInput(e))
+	}
+
+	pruned, err := repo.PruneOrganizationEvents(&pi)
+	var blocked event.PruneBlockedError
+	if errors.As(err, &blocked) {
+		fmt.Printf("Warning: %v\n", err)
+		stats.PruneBlocked = err
+		return stats, nil
+	}
+	if err != nil {
+		return stats, fmt.Errorf("failed to prune events not in source: %v", err)
+	}
+	stats.Pruned = pruned
+
+	return stats, nil
+}
@@ -0,0 +1,349 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/internal/config"
+	"github.com/dallasurbanists/events-sync/internal/importer"
+	"github.com/dallasurbanists/events-sync/pkg/event"
+	"github.com/dallasurbanists/events-sync/pkg/feed"
+	"github.com/dallasurbanists/events-sync/pkg/syncrun"
+)
+
+// fakeRepository keeps events in memory by eventKey
+type fakeRepository struct {
+	mu     sync.Mutex
+	events map[string]*event.Event
+
+	// locked makes every organization look locked by another run
+	locked bool
+
+	// threshold is the one the last prune was given
+	threshold int
+}
+
+func newFakeRepository(events ...*event.Event) *fakeRepository {
+	r := &fakeRepository{events: map[string]*event.Event{}}
+	for _, e := range events {
+		r.events[eventKey(e)] = e
+	}
+
+	return r
+}
+
+func (r *fakeRepository) InsertEvent(e *event.Event, actor event.Actor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events[eventKey(e)] = e
+	return nil
+}
+
+func (r *fakeRepository) GetEvent(i *event.GetEventInput) (*event.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := i.UID
+	if i.RecurrenceID != nil && *i.RecurrenceID != "" {
+		key += ":" + *i.RecurrenceID
+	}
+
+	e, ok := r.events[key]
+	if !ok || (e.DeletedAt != nil && !i.IncludeDeleted) {
+		return nil, event.NewNoEventsError(errors.New("not found"))
+	}
+
+	return e, nil
+}
+
+func (r *fakeRepository) GetEvents(i *event.GetEventsInput) ([]*event.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := []*event.Event{}
+	for _, e := range r.events {
+		if e.DeletedAt != nil {
+			continue
+		}
+		if i != nil && i.Organization != nil && e.Organization != *i.Organization {
+			continue
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+func (r *fakeRepository) PatchEvent(*event.GetEventInput, *event.PatchEventInput) error {
+	return errors.New("not implemented")
+}
+
+func (r *fakeRepository) UpsertEvents(ui *event.UpsertEventsInput) (*event.UpsertEventsResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &event.UpsertEventsResult{}
+	for _, e := range ui.Events {
+		before, ok := r.events[eventKey(e)]
+		switch {
+		case !ok:
+			result.Inserted++
+		case before.DeletedAt != nil:
+			result.Restored++
+		default:
+			result.Updated++
+		}
+		r.events[eventKey(e)] = e
+	}
+
+	return result, nil
+}
+
+func (r *fakeRepository) PruneOrganizationEvents(pi *event.PruneOrganizationEventsInput) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.threshold = pi.Threshold
+	keep := map[string]bool{}
+	for _, gi := range pi.ExistingEvents {
+		keep[eventKey(&event.Event{UID: gi.UID, RecurrenceID: gi.RecurrenceID})] = true
+	}
+
+	now := time.Now()
+	pruned := 0
+	for key, e := range r.events {
+		if e.Organization == pi.Organization && e.DeletedAt == nil && !keep[key] {
+			e.DeletedAt = &now
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
+
+func (r *fakeRepository) TryLockOrganization(string) (bool, error) { return !r.locked, nil }
+
+func (r *fakeRepository) GetRevisions(*event.GetRevisionsInput) ([]*event.Revision, error) {
+	return nil, nil
+}
+
+func (r *fakeRepository) GetRevision(int) (*event.Revision, error) { return nil, nil }
+
+func (r *fakeRepository) Transaction(fn func(event.Repository) error) error { return fn(r) }
+
+type fakeFeedCache struct{}
+
+func (fakeFeedCache) GetValidators(string) (*feed.Validators, error) { return nil, nil }
+func (fakeFeedCache) SaveValidators(*feed.Validators) error          { return nil }
+
+// fakeRuns keeps the last saved version of each run
+type fakeRuns struct {
+	mu   sync.Mutex
+	runs map[string]*syncrun.Run
+}
+
+func (r *fakeRuns) SaveRun(run *syncrun.Run) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.runs[run.Organization] = run
+	return nil
+}
+
+func (r *fakeRuns) GetRuns(*syncrun.GetRunsInput) ([]*syncrun.Run, error) { return nil, nil }
+
+func (r *fakeRuns) GetLatestRuns(*syncrun.GetLatestRunsInput) ([]*syncrun.Run, error) {
+	return nil, nil
+}
+
+func TestForEach(t *testing.T) {
+	const n, concurrency = 20, 3
+
+	var active, peak int32
+	out := make([]int, n)
+	errs := forEach(n, concurrency, func(i int) {
+		a := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if a <= p || atomic.CompareAndSwapInt32(&peak, p, a) {
+				break
+			}
+		}
+
+		if i%5 == 0 {
+			panic("boom")
+		}
+		time.Sleep(time.Millisecond)
+		out[i] = i * 2
+	})
+
+	if len(errs) != n {
+		t.Fatalf("got %d errors, want %d", len(errs), n)
+	}
+	for i := 0; i < n; i++ {
+		if i%5 == 0 {
+			if errs[i] == nil || !strings.Contains(errs[i].Error(), "boom") {
+				t.Errorf("errs[%d] = %v, want the panic", i, errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Errorf("errs[%d] = %v, want nil", i, errs[i])
+		}
+		if out[i] != i*2 {
+			t.Errorf("out[%d] = %d, want %d", i, out[i], i*2)
+		}
+	}
+	if peak > concurrency {
+		t.Errorf("%d calls ran at once, want at most %d", peak, concurrency)
+	}
+}
+
+func TestSyncAll(t *testing.T) {
+	events := func(source importer.Source) []*event.Event {
+		return []*event.Event{{
+			UID:          source.Organization + "@example.com",
+			Organization: source.Organization,
+			Summary:      "Bike Ride",
+			StartTime:    time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC),
+			EndTime:      time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC),
+		}}
+	}
+
+	runs := &fakeRuns{runs: map[string]*syncrun.Run{}}
+	s := &Syncer{
+		Events:    newFakeRepository(),
+		FeedCache: fakeFeedCache{},
+		Client:    http.DefaultClient,
+		Runs:      runs,
+		Importers: importer.Importers{
+			"ok": importer.ImporterFunc(func(ctx context.Context, client *http.Client, source importer.Source) ([]*event.Event, error) {
+				return events(source), nil
+			}),
+			"fail": importer.ImporterFunc(func(ctx context.Context, client *http.Client, source importer.Source) ([]*event.Event, error) {
+				return nil, errors.New("feed is down")
+			}),
+			"panic": importer.ImporterFunc(func(ctx context.Context, client *http.Client, source importer.Source) ([]*event.Event, error) {
+				panic("importer bug")
+			}),
+		},
+	}
+
+	orgs := map[string]config.Organization{
+		"Delta":   {Importer: "ok"},
+		"Alpha":   {Importer: "ok"},
+		"Charlie": {Importer: "panic"},
+		"Bravo":   {Importer: "fail"},
+		"Echo":    {Importer: "ok"},
+	}
+
+	results := s.SyncAll(context.Background(), orgs, 2)
+
+	want := []struct {
+		organization string
+		err          string
+		inserted     int
+	}{
+		{"Alpha", "", 1},
+		{"Bravo", "feed is down", 0},
+		{"Charlie", "importer bug", 0},
+		{"Delta", "", 1},
+		{"Echo", "", 1},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+
+	for i, w := range want {
+		r := results[i]
+		if r.Organization != w.organization {
+			t.Errorf("results[%d] is %s, want %s", i, r.Organization, w.organization)
+			continue
+		}
+
+		if w.err == "" {
+			if r.Err != nil {
+				t.Errorf("%s: unexpected error: %v", r.Organization, r.Err)
+			}
+		} else if r.Err == nil || !strings.Contains(r.Err.Error(), w.err) {
+			t.Errorf("%s: error = %v, want %q", r.Organization, r.Err, w.err)
+		}
+
+		if r.Inserted != w.inserted {
+			t.Errorf("%s: inserted %d, want %d", r.Organization, r.Inserted, w.inserted)
+		}
+
+		run := runs.runs[r.Organization]
+		if run == nil || run.Status != r.Status() || run.FinishedAt == nil {
+			t.Errorf("%s: run not recorded as finished %s: %+v", r.Organization, r.Status(), run)
+		}
+	}
+}
+
+func TestPruneOrganization(t *testing.T) {
+	stale := &event.Event{UID: "stale@example.com", Organization: "Alpha"}
+	s := &Syncer{
+		FeedCache: fakeFeedCache{},
+		Client:    http.DefaultClient,
+		Importers: importer.Importers{
+			"empty": importer.ImporterFunc(func(ctx context.Context, client *http.Client, source importer.Source) ([]*event.Event, error) {
+				return nil, nil
+			}),
+		},
+	}
+
+	tests := []struct {
+		name          string
+		org           config.Organization
+		threshold     int
+		wantThreshold int
+	}{
+		{"config threshold", config.Organization{Importer: "empty", PruneThreshold: 25}, -1, 25},
+		{"default threshold", config.Organization{Importer: "empty"}, -1, config.DefaultPruneThreshold},
+		{"override", config.Organization{Importer: "empty", PruneThreshold: 25}, 80, 80},
+		{"no limit", config.Organization{Importer: "empty", PruneThreshold: 25}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository(&event.Event{UID: stale.UID, Organization: stale.Organization})
+			s.Events = repo
+
+			r := s.PruneOrganization(context.Background(), "Alpha", tt.org, tt.threshold)
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+			if repo.threshold != tt.wantThreshold {
+				t.Errorf("pruned with threshold %d, want %d", repo.threshold, tt.wantThreshold)
+			}
+			if r.Pruned != 1 || r.Skipped {
+				t.Errorf("pruned %d, skipped %v, want 1 pruned", r.Pruned, r.Skipped)
+			}
+		})
+	}
+
+	t.Run("locked by another run", func(t *testing.T) {
+		repo := newFakeRepository(&event.Event{UID: stale.UID, Organization: stale.Organization})
+		repo.locked = true
+		s.Events = repo
+
+		r := s.PruneOrganization(context.Background(), "Alpha", config.Organization{Importer: "empty"}, -1)
+		if r.Err != nil {
+			t.Errorf("lock contention reported as a failure: %v", r.Err)
+		}
+		if !r.Skipped || r.Status() != syncrun.StatusSkipped {
+			t.Errorf("status = %s, want %s", r.Status(), syncrun.StatusSkipped)
+		}
+		if r.Pruned != 0 {
+			t.Errorf("pruned %d events while locked", r.Pruned)
+		}
+	})
+}
@@ -0,0 +1,2 @@
+-- Drop feed_cache table
+DROP TABLE IF EXISTS feed_cache;
@@ -0,0 +1,17 @@
+-- Create feed_cache table holding conditional GET validators per organization
+CREATE TABLE IF NOT EXISTS feed_cache (
+    organization VARCHAR(255) PRIMARY KEY,
+    url TEXT NOT NULL,
+    etag TEXT,
+    last_modified TEXT,
+    content_hash VARCHAR(64),
+    checked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Trigger to automatically update updated_at
+CREATE TRIGGER update_feed_cache_updated_at
+    BEFORE UPDATE ON feed_cache
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
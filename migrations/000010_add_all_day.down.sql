@@ -0,0 +1,2 @@
+-- Remove all_day flag from events table
+ALTER TABLE events DROP COLUMN all_day;
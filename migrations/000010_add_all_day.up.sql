@@ -0,0 +1,2 @@
+-- Add all_day flag so date-only events aren't exported as timed events at midnight
+ALTER TABLE events ADD COLUMN all_day BOOLEAN NOT NULL DEFAULT FALSE;
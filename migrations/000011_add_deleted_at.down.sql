@@ -0,0 +1,4 @@
+-- Permanently remove tombstoned events, then the deleted_at column
+DELETE FROM events WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_events_deleted_at;
+ALTER TABLE events DROP COLUMN deleted_at;
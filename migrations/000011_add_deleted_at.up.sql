@@ -0,0 +1,4 @@
+-- Soft delete events pruned from their source so their moderation state
+-- survives if they come back
+ALTER TABLE events ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX IF NOT EXISTS idx_events_deleted_at ON events(deleted_at);
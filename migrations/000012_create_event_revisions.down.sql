@@ -0,0 +1,2 @@
+-- Drop event_revisions table
+DROP TABLE IF EXISTS event_revisions;
@@ -0,0 +1,16 @@
+-- Create event_revisions table recording a field-level diff of every change
+-- to an event and who made it
+CREATE TABLE IF NOT EXISTS event_revisions (
+    id SERIAL PRIMARY KEY,
+    uid VARCHAR(255) NOT NULL,
+    recurrence_id VARCHAR(255) NOT NULL DEFAULT '',
+    action VARCHAR(50) NOT NULL,
+    actor_type VARCHAR(50) NOT NULL,
+    actor_id VARCHAR(255) NOT NULL,
+    actor_name VARCHAR(255),
+    changes JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Index for listing an event's history
+CREATE INDEX IF NOT EXISTS idx_event_revisions_uid_recurrence_id ON event_revisions(uid, recurrence_id);
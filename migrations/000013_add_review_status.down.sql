@@ -0,0 +1,15 @@
+-- Revert: Add back the rejected column
+ALTER TABLE events ADD COLUMN rejected BOOLEAN DEFAULT FALSE;
+
+-- Migrate data back: only rejected events stay hidden
+UPDATE events SET rejected = true WHERE review_status = 'rejected';
+
+-- Drop the review columns
+DROP INDEX IF EXISTS idx_events_review_status;
+ALTER TABLE events DROP COLUMN review_status;
+ALTER TABLE events DROP COLUMN reviewed_by;
+ALTER TABLE events DROP COLUMN reviewed_by_id;
+ALTER TABLE events DROP COLUMN reviewed_at;
+
+-- Recreate the rejected index
+CREATE INDEX IF NOT EXISTS idx_events_rejected ON events(rejected);
@@ -0,0 +1,16 @@
+-- Replace the rejected flag with a review state machine and record who
+-- reviewed each event
+ALTER TABLE events ADD COLUMN review_status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (review_status IN ('pending', 'approved', 'rejected', 'needs_review'));
+ALTER TABLE events ADD COLUMN reviewed_by VARCHAR(255);
+ALTER TABLE events ADD COLUMN reviewed_by_id VARCHAR(255);
+ALTER TABLE events ADD COLUMN reviewed_at TIMESTAMP WITH TIME ZONE;
+
+-- Existing events were already published unless rejected
+UPDATE events SET review_status = CASE WHEN rejected THEN 'rejected' ELSE 'approved' END;
+
+-- Drop the rejected column and its index
+DROP INDEX IF EXISTS idx_events_rejected;
+ALTER TABLE events DROP COLUMN rejected;
+
+-- Index for review status queries
+CREATE INDEX IF NOT EXISTS idx_events_review_status ON events(review_status);
@@ -0,0 +1,2 @@
+-- Drop sync_runs table
+DROP TABLE IF EXISTS sync_runs;
@@ -0,0 +1,30 @@
+-- Create sync_runs table recording each organization's part of every sync
+CREATE TABLE IF NOT EXISTS sync_runs (
+    id UUID PRIMARY KEY,
+    run_id UUID NOT NULL,
+    organization VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('running', 'succeeded', 'unchanged', 'prune_blocked', 'failed')),
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    found INTEGER NOT NULL DEFAULT 0,
+    inserted INTEGER NOT NULL DEFAULT 0,
+    updated INTEGER NOT NULL DEFAULT 0,
+    pruned INTEGER NOT NULL DEFAULT 0,
+    restored INTEGER NOT NULL DEFAULT 0,
+    http_status INTEGER,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Index for listing an organization's runs
+CREATE INDEX IF NOT EXISTS idx_sync_runs_organization_started_at ON sync_runs(organization, started_at DESC);
+
+-- Index for grouping the organizations of one run
+CREATE INDEX IF NOT EXISTS idx_sync_runs_run_id ON sync_runs(run_id);
+
+-- Trigger to automatically update updated_at
+CREATE TRIGGER update_sync_runs_updated_at
+    BEFORE UPDATE ON sync_runs
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,5 @@
+-- Remove skipped runs, then restore the original status list
+DELETE FROM sync_runs WHERE status = 'skipped';
+ALTER TABLE sync_runs DROP CONSTRAINT IF EXISTS sync_runs_status_check;
+ALTER TABLE sync_runs ADD CONSTRAINT sync_runs_status_check
+    CHECK (status IN ('running', 'succeeded', 'unchanged', 'prune_blocked', 'failed'));
@@ -0,0 +1,5 @@
+-- Allow the skipped status recorded when another process holds an
+-- organization's sync lock
+ALTER TABLE sync_runs DROP CONSTRAINT IF EXISTS sync_runs_status_check;
+ALTER TABLE sync_runs ADD CONSTRAINT sync_runs_status_check
+    CHECK (status IN ('running', 'succeeded', 'unchanged', 'prune_blocked', 'failed', 'skipped'));
@@ -0,0 +1,3 @@
+-- Hash of the source's version of each event, so a sync can skip events
+-- that haven't changed since they were last written
+ALTER TABLE events ADD COLUMN fingerprint VARCHAR(64);
@@ -0,0 +1,3 @@
+-- Normalized dates stay as they are; UTC values were already accepted
+-- before they were normalized
+ALTER TABLE events DROP COLUMN tzid;
@@ -0,0 +1,41 @@
+-- Zone each event's DTSTART was given in, which its recurrence rules are
+-- expanded in
+ALTER TABLE events ADD COLUMN tzid VARCHAR(64);
+
+-- RECURRENCE-ID, EXDATE and RDATE values are now stored as UTC instants
+-- (20060102T150405Z) or bare dates. Floating values were written in the
+-- deployment's timezone, which SQL can't read from the config, so it's taken
+-- from the events_sync.timezone setting, America/Chicago when unset. A
+-- deployment configured with another timezone must run this migration with
+-- the setting in place, e.g. after
+--   ALTER DATABASE events SET events_sync.timezone = 'America/Denver';
+-- and, if it already ran without it, restore these columns from a backup and
+-- rerun it. The basic format is parsed explicitly, in UTC so the wall clock
+-- comes back as it was written.
+CREATE FUNCTION pg_temp.normalize_ical_dates(list TEXT) RETURNS TEXT AS $$
+  SELECT string_agg(
+    CASE WHEN value ~ '^\d{8}T\d{6}$'
+      THEN to_char(
+        (to_timestamp(value, 'YYYYMMDD"T"HH24MISS')::timestamp
+          AT TIME ZONE coalesce(nullif(current_setting('events_sync.timezone', true), ''), 'America/Chicago'))
+          AT TIME ZONE 'UTC',
+        'YYYYMMDD"T"HH24MISS"Z"')
+      ELSE value
+    END, ',' ORDER BY n)
+  FROM unnest(string_to_array(list, ',')) WITH ORDINALITY AS t(value, n)
+$$ LANGUAGE SQL STABLE SET timezone = 'UTC';
+
+UPDATE events SET recurrence_id = pg_temp.normalize_ical_dates(recurrence_id)
+WHERE recurrence_id ~ '^\d{8}T\d{6}$';
+
+UPDATE events SET exdate = pg_temp.normalize_ical_dates(exdate)
+WHERE exdate ~ '\d{8}T\d{6}(,|$)';
+
+UPDATE events SET exdate_manual = pg_temp.normalize_ical_dates(exdate_manual)
+WHERE exdate_manual ~ '\d{8}T\d{6}(,|$)';
+
+UPDATE events SET rdate = pg_temp.normalize_ical_dates(rdate)
+WHERE rdate ~ '\d{8}T\d{6}(,|$)';
+
+UPDATE event_revisions SET recurrence_id = pg_temp.normalize_ical_dates(recurrence_id)
+WHERE recurrence_id ~ '^\d{8}T\d{6}$';
@@ -0,0 +1,2 @@
+ALTER TABLE events DROP COLUMN occurrence_overlays;
+ALTER TABLE events DROP COLUMN url;
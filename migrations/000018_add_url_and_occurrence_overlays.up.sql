@@ -0,0 +1,6 @@
+-- The event's URL property, which overlays can also set
+ALTER TABLE events ADD COLUMN url TEXT;
+
+-- Overlays a series root holds for single occurrences without rows of
+-- their own, keyed by recurrence ID
+ALTER TABLE events ADD COLUMN occurrence_overlays JSON;
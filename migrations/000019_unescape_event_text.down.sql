@@ -0,0 +1,4 @@
+-- Irreversible: once the TEXT escapes are resolved there's no telling which
+-- backslashes, commas and semicolons were escaped, so rolling back leaves
+-- the text as it is. The feed cache repopulates on the next sync.
+SELECT 1;
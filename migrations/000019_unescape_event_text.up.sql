@@ -0,0 +1,14 @@
+-- Text fields used to be stored as the feed escaped them. Resolve the TEXT
+-- escapes so stored values compare equal to what the importer now reads,
+-- holding \\ aside first so an escaped backslash isn't read as the start of
+-- another escape.
+UPDATE events SET
+    summary = replace(replace(replace(replace(replace(replace(summary,
+        '\\', chr(1)), '\,', ','), '\;', ';'), '\n', E'\n'), '\N', E'\n'), chr(1), '\'),
+    description = replace(replace(replace(replace(replace(replace(description,
+        '\\', chr(1)), '\,', ','), '\;', ';'), '\n', E'\n'), '\N', E'\n'), chr(1), '\'),
+    location = replace(replace(replace(replace(replace(replace(location,
+        '\\', chr(1)), '\,', ','), '\;', ';'), '\n', E'\n'), '\N', E'\n'), chr(1), '\')
+WHERE strpos(summary, '\') > 0
+   OR strpos(description, '\') > 0
+   OR strpos(location, '\') > 0;
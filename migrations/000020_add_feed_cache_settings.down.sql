@@ -0,0 +1,3 @@
+-- Remove importer version and settings hash from feed_cache
+ALTER TABLE feed_cache DROP COLUMN settings_hash;
+ALTER TABLE feed_cache DROP COLUMN importer_version;
@@ -0,0 +1,5 @@
+-- Record the importer version and a hash of the organization's settings with
+-- each feed's validators, so a change to either re-imports an unchanged feed.
+-- Existing rows keep version 0 and no hash, which never matches.
+ALTER TABLE feed_cache ADD COLUMN importer_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feed_cache ADD COLUMN settings_hash VARCHAR(64);
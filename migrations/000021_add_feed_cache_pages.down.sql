@@ -0,0 +1,2 @@
+-- Remove per-page validators from feed_cache
+ALTER TABLE feed_cache DROP COLUMN pages;
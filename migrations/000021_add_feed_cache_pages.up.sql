@@ -0,0 +1,3 @@
+-- Record the validators of each page for feeds fetched a page at a time,
+-- such as the Action Network API, so unchanged pages can be skipped
+ALTER TABLE feed_cache ADD COLUMN pages JSONB;
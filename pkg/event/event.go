@@ -13,6 +13,65 @@ const (
 	EventTypeVolunteerAction = "volunteer_action"
 )
 
+// Review statuses. Imported events start pending; a moderator approves or
+// rejects them, and a significant upstream change to a reviewed event sends
+// it back for review.
+const (
+	ReviewPending     = "pending"
+	ReviewApproved    = "approved"
+	ReviewRejected    = "rejected"
+	ReviewNeedsReview = "needs_review"
+)
+
+// reviewTransitions lists the statuses each review status may move to
+var reviewTransitions = map[string][]string{
+	ReviewPending:     {ReviewApproved, ReviewRejected},
+	ReviewApproved:    {ReviewRejected, ReviewNeedsReview},
+	ReviewRejected:    {ReviewApproved, ReviewNeedsReview},
+	ReviewNeedsReview: {ReviewApproved, ReviewRejected},
+}
+
+// CheckReviewTransition returns an error unless an event may move from one
+// review status to the other. Staying in the same status is allowed.
+func CheckReviewTransition(from, to string) error {
+	if _, ok := reviewTransitions[to]; !ok {
+		return fmt.Errorf("invalid review status %q", to)
+	}
+	if from == to {
+		return nil
+	}
+
+	for _, next := range reviewTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot move event from %s to %s", from, to)
+}
+
+// CheckOccurrenceReview returns an error unless an instance of a series
+// without a row of its own may move from one review status to the other.
+// Such an instance takes its status from the series, so it can only be
+// rejected, through the series' manual exdates, or un-rejected back to the
+// series' status. Other changes are made to the whole series.
+func CheckOccurrenceReview(from, series, to string) error {
+	if _, ok := reviewTransitions[to]; !ok {
+		return fmt.Errorf("invalid review status %q", to)
+	}
+	if from == to || to == ReviewRejected {
+		return nil
+	}
+	if from == ReviewRejected && series != ReviewRejected && to == series {
+		return nil
+	}
+
+	if from == ReviewRejected && series != ReviewRejected {
+		return fmt.Errorf("cannot move occurrence from %s to %s: un-rejecting an occurrence gives it its series' %s status", from, to, series)
+	}
+	return fmt.Errorf("cannot move occurrence from %s to %s: only the whole series can be reviewed", from, to)
+}
+
 // EventTypeDisplayName maps event type keys to their display names
 var EventTypeDisplayName = map[string]string{
 	EventTypeCivicMeeting:   "Civic Meeting",
@@ -28,11 +87,24 @@ type Event struct {
 	Location     *string    `json:"location"`
 	StartTime    time.Time  `json:"start_time"`
 	EndTime      time.Time  `json:"end_time"`
+	AllDay       bool       `json:"all_day"`
+
+	// TZID is the IANA zone the source gave DTSTART in, "UTC" for UTC
+	// times and nil for floating times and dates. Recurrence rules are
+	// expanded in it.
+	TZID *string `json:"tzid,omitempty"`
+
 	Created      *time.Time `json:"created"`
 	Modified     *time.Time `json:"modified"`
-	Rejected     bool       `json:"rejected"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	ReviewStatus string     `json:"review_status"`
+	ReviewedBy   *string    `json:"reviewed_by"`
+	ReviewedByID *string    `json:"reviewed_by_id"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	Rejected     bool       `json:"rejected"` // ReviewStatus is ReviewRejected
 	Status       *string    `json:"status"`
 	Transparency *string    `json:"transparency"`
+	URL          *string    `json:"url"`
 	Sequence     int        `json:"sequence"`
 	RecurrenceID *string    `json:"recurrence_id"`
 	RRule        *string    `json:"rrule"`
@@ -41,6 +113,13 @@ type Event struct {
 	ExDateManual *string    `json:"exdate_manual"`
 	Type         string     `json:"type"`
 	Overlay      map[string]EventOverlay `json:"overlay,omitempty"`
+
+	// OccurrenceOverlays holds a series root's overlays for single
+	// instances without a row of their own, keyed by RECURRENCE-ID
+	OccurrenceOverlays map[string]map[string]EventOverlay `json:"occurrence_overlays,omitempty"`
+
+	// Fingerprint is the SourceFingerprint of the version last synced
+	Fingerprint *string `json:"-"`
 }
 
 type EventOverlay struct {
@@ -49,19 +128,36 @@ type EventOverlay struct {
 	Source     string      `json:"source"`
 	Timestamp  string      `json:"timestamp"`
 	Reason     string      `json:"reason,omitempty"`
+
+	// FromSeries marks an instance's overlay that cascades from its
+	// series rather than being set on the instance
+	FromSeries bool `json:"from_series,omitempty"`
 }
 
 type GetEventInput struct {
 	UID          string
 	RecurrenceID *string
+
+	// IncludeDeleted also finds an event pruned from its source
+	IncludeDeleted bool
+
+	// ForUpdate locks the event's row against other writers until the
+	// surrounding transaction ends. Only meaningful inside Transaction.
+	ForUpdate bool
 }
 
 type GetEventsInput struct {
 	UID          *string
-	Rejected     *bool
+	ReviewStatus *string
 	Organization *string
-	UpcomingOnly bool
 	Type         *string
+
+	// UpcomingOnly returns the events that haven't started yet and every
+	// row of a recurring series, for ExpandOccurrences to narrow down
+	UpcomingOnly bool
+
+	// IncludeDeleted also returns events pruned from their source
+	IncludeDeleted bool
 }
 
 type NoEventsError struct {
@@ -74,40 +170,92 @@ func (e NoEventsError) Error() string {
 
 func NewNoEventsError(o error) NoEventsError { return NoEventsError{o} }
 
+// PruneBlockedError is returned by PruneOrganizationEvents when more of an
+// organization's events would be removed than its threshold allows
+type PruneBlockedError struct {
+	Organization string
+	Pruning      int
+	Total        int
+	Threshold    int
+}
+
+func (e PruneBlockedError) Error() string {
+	return fmt.Sprintf("prune blocked for %s: %d of %d events missing from source, over the %d%% threshold", e.Organization, e.Pruning, e.Total, e.Threshold)
+}
+
+// PruneFloor is how many events a prune may remove whatever the threshold.
+// The threshold only applies to larger prunes, so an organization with a
+// handful of events can still drop one.
+const PruneFloor = 5
+
+// PruneExceedsThreshold reports whether removing pruning of total events is
+// more than threshold percent allows. Removing every event, as an empty
+// fetch would, exceeds any threshold under 100, however few there are.
+func PruneExceedsThreshold(pruning, total, threshold int) bool {
+	if threshold <= 0 {
+		return false
+	}
+	if pruning == total && total > 0 && threshold < 100 {
+		return true
+	}
+
+	return pruning > PruneFloor && pruning*100 > total*threshold
+}
+
 type PatchEventInput struct {
 	Organization *string
-	Rejected     *bool
+
+	// ReviewStatus also records Actor as the reviewer
+	ReviewStatus *string
 	Type         *string
 	ExDateManual *string
 	Overlay      map[string]EventOverlay
-}
 
-type SyncEventInput struct {
-	Summary      *string
-	Description  *string
-	Location     *string
-	StartTime    *time.Time
-	EndTime      *time.Time
-	Rejected     *bool
-	Status       *string
-	Transparency *string
-	Sequence     *int
-	RRule        *string
-	RDate        *string
-	ExDate       *string
+	OccurrenceOverlays map[string]map[string]EventOverlay
+
+	// Actor and Action are recorded on the resulting revision; Action
+	// defaults to ActionPatch
+	Actor  Actor
+	Action string
 }
 
 type PruneOrganizationEventsInput struct {
 	Organization   string
 	ExistingEvents []GetEventInput
+
+	// Threshold is the largest percentage of the organization's events the
+	// prune may remove before it is refused with a PruneBlockedError, once
+	// more than PruneFloor would be removed or all of them would. 0 means no
+	// limit.
+	Threshold int
+
+	Actor Actor
 }
 
 type Repository interface {
-	InsertEvent(*Event) error
+	InsertEvent(*Event, Actor) error
 	GetEvent(*GetEventInput) (*Event, error)
 	GetEvents(*GetEventsInput) ([]*Event, error)
 	PatchEvent(*GetEventInput, *PatchEventInput) error
-	SyncEvent(*GetEventInput, *SyncEventInput) error
 
-	PruneOrganizationEvents(*PruneOrganizationEventsInput) error
+	// UpsertEvents inserts the events that aren't stored yet and syncs the
+	// ones that are, in batches. A significant change sends a reviewed event
+	// back for review and the fields moderators own are never overwritten.
+	UpsertEvents(*UpsertEventsInput) (*UpsertEventsResult, error)
+
+	PruneOrganizationEvents(*PruneOrganizationEventsInput) (int, error)
+
+	// TryLockOrganization locks the organization against other syncs until
+	// the surrounding transaction ends. It reports false, without waiting,
+	// when another transaction holds the lock. Only valid inside Transaction.
+	TryLockOrganization(organization string) (bool, error)
+
+	// GetRevisions returns an event's revisions, newest first
+	GetRevisions(*GetRevisionsInput) ([]*Revision, error)
+	// GetRevision returns nil if there is no revision with the ID
+	GetRevision(id int) (*Revision, error)
+
+	// Transaction runs fn against a Repository bound to a single database
+	// transaction, committing when fn returns nil and rolling back otherwise
+	Transaction(fn func(Repository) error) error
 }
@@ -0,0 +1,63 @@
+package event
+
+import "testing"
+
+func TestPruneExceedsThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		pruning   int
+		total     int
+		threshold int
+		want      bool
+	}{
+		{"one event of a small organization", 1, 3, 50, false},
+		{"at the floor", PruneFloor, PruneFloor + 2, 50, false},
+		{"empty feed, one event", 1, 1, 50, true},
+		{"empty feed, at the floor", PruneFloor, PruneFloor, 50, true},
+		{"nothing stored", 0, 0, 50, false},
+		{"over the floor and the threshold", PruneFloor + 1, PruneFloor + 1, 50, true},
+		{"over the floor, under the threshold", 6, 100, 50, false},
+		{"exactly the threshold", 50, 100, 50, false},
+		{"just over the threshold", 51, 100, 50, true},
+		{"no limit", 100, 100, 0, false},
+		{"everything allowed", 100, 100, 100, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PruneExceedsThreshold(tt.pruning, tt.total, tt.threshold); got != tt.want {
+				t.Errorf("PruneExceedsThreshold(%d, %d, %d) = %v, want %v", tt.pruning, tt.total, tt.threshold, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOccurrenceReview(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		series  string
+		to      string
+		wantErr bool
+	}{
+		{"reject", ReviewApproved, ReviewApproved, ReviewRejected, false},
+		{"reject a pending occurrence", ReviewPending, ReviewPending, ReviewRejected, false},
+		{"un-reject to the series status", ReviewRejected, ReviewApproved, ReviewApproved, false},
+		{"un-reject to a pending series", ReviewRejected, ReviewPending, ReviewPending, false},
+		{"unchanged", ReviewApproved, ReviewApproved, ReviewApproved, false},
+		{"approve one occurrence", ReviewPending, ReviewPending, ReviewApproved, true},
+		{"send one occurrence to review", ReviewApproved, ReviewApproved, ReviewNeedsReview, true},
+		{"un-reject to another status", ReviewRejected, ReviewPending, ReviewApproved, true},
+		{"un-reject in a rejected series", ReviewRejected, ReviewRejected, ReviewApproved, true},
+		{"invalid status", ReviewApproved, ReviewApproved, "bogus", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckOccurrenceReview(tt.from, tt.series, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckOccurrenceReview(%s, %s, %s) = %v, want error %v", tt.from, tt.series, tt.to, err, tt.wantErr)
+			}
+		})
+	}
+}
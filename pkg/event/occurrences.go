@@ -0,0 +1,286 @@
+package event
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/pkg/ical"
+)
+
+// Occurrence is one instance of an event within a time window
+type Occurrence struct {
+	*Event
+
+	// Generated is set for an instance expanded from its series' rules
+	// rather than stored as a row of its own
+	Generated bool `json:"generated,omitempty"`
+}
+
+// IsRecurring reports whether e is the root of a series
+func (e *Event) IsRecurring() bool {
+	return (e.RecurrenceID == nil || *e.RecurrenceID == "") &&
+		((e.RRule != nil && *e.RRule != "") || (e.RDate != nil && *e.RDate != ""))
+}
+
+// FormatRecurrenceID formats an instance's start as its stored
+// RECURRENCE-ID: a UTC instant, or for all-day events the date in loc
+func FormatRecurrenceID(start time.Time, allDay bool, loc *time.Location) string {
+	if allDay {
+		return ical.FormatDate(start, loc)
+	}
+
+	return ical.FormatDateTime(start)
+}
+
+// seriesLocation returns the zone a series' rules are expanded in, its
+// DTSTART's zone when known and loc otherwise
+func seriesLocation(root *Event, loc *time.Location) *time.Location {
+	if root.TZID != nil && *root.TZID != "" {
+		if l, err := time.LoadLocation(*root.TZID); err == nil {
+			return l
+		}
+	}
+
+	return loc
+}
+
+// ContainsDate reports whether a comma separated EXDATE style list names
+// the same instant as value. Floating values are read in loc.
+func ContainsDate(list *string, value string, loc *time.Location) bool {
+	if list == nil || *list == "" {
+		return false
+	}
+
+	t, err := ical.ParseDateTime(value, loc)
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range strings.Split(*list, ",") {
+		if entry == value {
+			return true
+		}
+		if d, err := ical.ParseDateTime(entry, loc); err == nil && d.Equal(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ExpandOccurrences turns stored rows into the occurrences starting in
+// [from, to). A series root is expanded through its RRULE and RDATE, less
+// its synced EXDATEs, and rows with a RECURRENCE-ID replace the instance
+// they override. Instances in the root's manual exdates are kept but
+// marked rejected, so moderators can bring them back. Rules are expanded in
+// each series' own zone; floating dates and all-day events are read in loc.
+func ExpandOccurrences(events []*Event, from, to time.Time, loc *time.Location) []*Occurrence {
+	byUID := map[string][]*Event{}
+	uids := []string{}
+	for _, e := range events {
+		if _, ok := byUID[e.UID]; !ok {
+			uids = append(uids, e.UID)
+		}
+		byUID[e.UID] = append(byUID[e.UID], e)
+	}
+
+	out := []*Occurrence{}
+	for _, uid := range uids {
+		out = append(out, expandSeries(byUID[uid], from, to, loc)...)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].StartTime.Before(out[j].StartTime) })
+
+	return out
+}
+
+// expandSeries expands the rows sharing a UID
+func expandSeries(rows []*Event, from, to time.Time, loc *time.Location) []*Occurrence {
+	var root *Event
+	overrides := []*Event{}
+	for _, e := range rows {
+		if e.RecurrenceID == nil || *e.RecurrenceID == "" {
+			root = e
+		} else {
+			overrides = append(overrides, e)
+		}
+	}
+
+	inWindow := func(e *Event) bool {
+		return !e.StartTime.Before(from) && e.StartTime.Before(to)
+	}
+
+	out := []*Occurrence{}
+	starts, ok := instanceStarts(root, from, to, loc)
+	if !ok {
+		// not a series, or one whose rules can't be read: the rows stand
+		// on their own, a root as long as it starts before to
+		for _, e := range rows {
+			if inWindow(e) || (e.IsRecurring() && e.StartTime.Before(to)) {
+				out = append(out, &Occurrence{Event: e})
+			}
+		}
+
+		return out
+	}
+
+	overridden := map[int64]bool{}
+	for _, e := range overrides {
+		if t, err := ical.ParseDateTime(*e.RecurrenceID, loc); err == nil {
+			overridden[t.Unix()] = true
+		}
+		if inWindow(e) {
+			override := *e
+			override.Overlay = root.InstanceOverlay(*e.RecurrenceID, e.Overlay, loc)
+			out = append(out, &Occurrence{Event: &override})
+		}
+	}
+
+	for _, start := range starts {
+		if overridden[start.Unix()] || start.Before(from) {
+			continue
+		}
+
+		out = append(out, &Occurrence{Event: newInstance(root, start, loc), Generated: true})
+	}
+
+	return out
+}
+
+// Instance builds the instance of a series with the given RECURRENCE-ID
+// from its root, for an instance without a row of its own. It carries the
+// overlays in force on the instance and is rejected if it's among the
+// root's manual exdates. Whether the series has such an instance isn't
+// checked; see IsInstance.
+func Instance(root *Event, recurrenceID string, loc *time.Location) (*Event, error) {
+	start, err := ical.ParseDateTime(recurrenceID, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recurrence ID %q: %v", recurrenceID, err)
+	}
+
+	if ical.IsDate(recurrenceID) && !root.AllDay {
+		// a DATE instance of a timed series keeps the series' start time
+		dtstart := root.StartTime.In(seriesLocation(root, loc))
+		start = time.Date(start.Year(), start.Month(), start.Day(), dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, dtstart.Location())
+	}
+
+	return newInstance(root, start, loc), nil
+}
+
+// newInstance builds the instance of a series starting at start
+func newInstance(root *Event, start time.Time, loc *time.Location) *Event {
+	instance := *root
+	recurrenceID := FormatRecurrenceID(start, root.AllDay, loc)
+	instance.RecurrenceID = &recurrenceID
+	instance.StartTime = start
+
+	length := root.EndTime.Sub(root.StartTime)
+	switch {
+	case root.EndTime.IsZero():
+	case root.AllDay:
+		// whole days, whatever DST does in between
+		instance.EndTime = start.AddDate(0, 0, int(length.Round(24*time.Hour)/(24*time.Hour)))
+	default:
+		instance.EndTime = start.Add(length)
+	}
+	instance.RRule = nil
+	instance.RDate = nil
+	instance.ExDate = nil
+	instance.ExDateManual = nil
+	instance.Overlay = root.InstanceOverlay(recurrenceID, nil, loc)
+	instance.OccurrenceOverlays = nil
+
+	if ContainsDate(root.ExDateManual, recurrenceID, loc) {
+		instance.ReviewStatus = ReviewRejected
+		instance.Rejected = true
+	}
+
+	return &instance
+}
+
+// IsInstance reports whether a series' rules still produce an instance
+// with the given RECURRENCE-ID
+func IsInstance(root *Event, recurrenceID *string, loc *time.Location) bool {
+	start, err := ical.ParseDateTime(*recurrenceID, loc)
+	if err != nil {
+		return false
+	}
+
+	starts, ok := instanceStarts(root, start.Add(-time.Second), start.Add(time.Second), loc)
+	if !ok {
+		return false
+	}
+	for _, t := range starts {
+		if t.Equal(start) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// instanceStarts lists the starts of a series root's instances in the
+// window, less its synced EXDATEs. It reports false when root isn't a
+// series or its rules can't be parsed.
+func instanceStarts(root *Event, from, to time.Time, loc *time.Location) ([]time.Time, bool) {
+	if root == nil || !root.IsRecurring() {
+		return nil, false
+	}
+
+	dtstart := root.StartTime.In(seriesLocation(root, loc))
+	starts := []time.Time{}
+
+	if root.RRule != nil && *root.RRule != "" {
+		rule, err := ical.ParseRRule(*root.RRule)
+		if err != nil {
+			return nil, false
+		}
+		starts = append(starts, rule.Expand(dtstart, from, to)...)
+	} else if !dtstart.Before(from) && dtstart.Before(to) {
+		starts = append(starts, dtstart)
+	}
+
+	if root.RDate != nil && *root.RDate != "" {
+		for _, value := range strings.Split(*root.RDate, ",") {
+			value, _, _ = strings.Cut(value, "/")
+			t, err := ical.ParseDateTime(value, loc)
+			if err != nil {
+				return nil, false
+			}
+			if len(value) == len("20060102") {
+				// an RDATE given as a DATE keeps the series' start time
+				t = time.Date(t.Year(), t.Month(), t.Day(), dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, dtstart.Location())
+			}
+			if !t.Before(from) && t.Before(to) {
+				starts = append(starts, t)
+			}
+		}
+	}
+
+	excluded := map[int64]bool{}
+	if root.ExDate != nil && *root.ExDate != "" {
+		exdates, err := ical.ParseDateList(*root.ExDate, loc)
+		if err != nil {
+			return nil, false
+		}
+		for _, t := range exdates {
+			excluded[t.Unix()] = true
+		}
+	}
+
+	seen := map[int64]bool{}
+	out := []time.Time{}
+	for _, t := range starts {
+		if excluded[t.Unix()] || seen[t.Unix()] {
+			continue
+		}
+		seen[t.Unix()] = true
+		out = append(out, t)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+
+	return out, true
+}
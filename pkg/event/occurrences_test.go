@@ -0,0 +1,116 @@
+package event
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dallasurbanists/events-sync/pkg/ical"
+)
+
+func TestExpandOccurrencesWindow(t *testing.T) {
+	loc, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := func(s string) *string { return &s }
+	at := func(month time.Month, day int) time.Time {
+		return time.Date(2025, month, day, 18, 0, 0, 0, loc)
+	}
+	oneOff := func(uid string, start time.Time) *Event {
+		return &Event{UID: uid, StartTime: start, EndTime: start.Add(time.Hour), RecurrenceID: str("")}
+	}
+
+	events := []*Event{
+		oneOff("past", at(3, 1)),
+		oneOff("inside", at(3, 5)),
+		oneOff("after", at(3, 20)),
+		{UID: "weekly", StartTime: at(2, 3), EndTime: at(2, 3).Add(time.Hour), RecurrenceID: str(""), RRule: str("FREQ=WEEKLY")},
+		{UID: "unreadable", StartTime: at(2, 3), EndTime: at(2, 3).Add(time.Hour), RecurrenceID: str(""), RRule: str("FREQ=SOMETIMES")},
+		{UID: "unreadable-later", StartTime: at(4, 1), EndTime: at(4, 1).Add(time.Hour), RecurrenceID: str(""), RRule: str("FREQ=SOMETIMES")},
+	}
+
+	from, to := at(3, 2), at(3, 15)
+	got := []string{}
+	for _, o := range ExpandOccurrences(events, from, to, loc) {
+		got = append(got, o.UID+" "+o.StartTime.In(loc).Format("01-02"))
+	}
+
+	want := []string{"unreadable 02-03", "weekly 03-03", "inside 03-05", "weekly 03-10"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExpandOccurrencesStartingAtFrom(t *testing.T) {
+	str := func(s string) *string { return &s }
+	from := time.Date(2025, 3, 3, 18, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	events := []*Event{
+		{UID: "one-off", StartTime: from, EndTime: from.Add(time.Hour), RecurrenceID: str("")},
+		{UID: "daily", StartTime: from.AddDate(0, 0, -10), EndTime: from.AddDate(0, 0, -10).Add(time.Hour), RecurrenceID: str(""), RRule: str("FREQ=DAILY")},
+		{UID: "ancient", StartTime: from.AddDate(-30, 0, 0), EndTime: from.AddDate(-30, 0, 0).Add(time.Hour), RecurrenceID: str(""), RRule: str("FREQ=DAILY")},
+	}
+
+	got := []string{}
+	for _, o := range ExpandOccurrences(events, from, to, time.UTC) {
+		if !o.StartTime.Equal(from) {
+			t.Errorf("%s starts at %v, want %v", o.UID, o.StartTime, from)
+		}
+		got = append(got, o.UID)
+	}
+
+	want := []string{"one-off", "daily", "ancient"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestContainsDate(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same instant as an importer stores a TZID value
+	fromTZID, err := ical.NormalizeDate("20250310T190000", newYork)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := func(s string) *string { return &s }
+	utc := "20250310T230000Z"
+	floating := "20250310T180000"
+
+	tests := []struct {
+		name  string
+		list  *string
+		value string
+		want  bool
+	}{
+		{"UTC in a UTC list", str(utc), utc, true},
+		{"floating in a UTC list", str("20250101T000000Z," + utc), floating, true},
+		{"TZID in a UTC list", str(utc), fromTZID, true},
+		{"UTC in a floating list", str(floating), utc, true},
+		{"TZID in a floating list", str(floating), fromTZID, true},
+		{"another instant", str(utc), "20250310T180000Z", false},
+		{"DATE", str("20250310"), "20250310", true},
+		{"DATE against a DATE-TIME", str(utc), "20250310", false},
+		{"empty list", str(""), utc, false},
+		{"no list", nil, utc, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContainsDate(tt.list, tt.value, chicago); got != tt.want {
+				t.Errorf("ContainsDate(%v, %q) = %v, want %v", tt.list, tt.value, got, tt.want)
+			}
+		})
+	}
+}
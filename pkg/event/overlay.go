@@ -0,0 +1,285 @@
+package event
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Overlay fields, named after the event fields they set
+const (
+	OverlayLocation    = "location"
+	OverlayDescription = "description"
+	OverlaySummary     = "summary"
+	OverlayStartTime   = "start_time"
+	OverlayEndTime     = "end_time"
+	OverlayStatus      = "status"
+	OverlayURL         = "url"
+	OverlayType        = "type"
+)
+
+// Overlay merge logic: whether an overlay only fills in an empty field or
+// always replaces it
+const (
+	MergeOverwriteEmpty = "overwrite_empty"
+	MergeOverwriteAll   = "overwrite_all"
+)
+
+// OverlayScope is what an overlay is set on
+type OverlayScope int
+
+const (
+	// OverlayOnEvent is an event outside any series
+	OverlayOnEvent OverlayScope = iota
+	// OverlayOnSeries is a whole recurring series, set on its root
+	OverlayOnSeries
+	// OverlayOnOccurrence is a single instance of a series
+	OverlayOnOccurrence
+)
+
+// timeOverlayFields can't be overlaid on a whole series: moving a series'
+// start would move every instance's RECURRENCE-ID with it
+var timeOverlayFields = map[string]bool{OverlayStartTime: true, OverlayEndTime: true}
+
+// overlayStatuses are the STATUS values a status overlay may set
+var overlayStatuses = map[string]bool{"TENTATIVE": true, "CONFIRMED": true, "CANCELLED": true}
+
+// ValidateOverlay checks an overlay's field, merge logic and value against
+// what the overlay is set on. Times can't be overlaid on a whole series,
+// and every instance of a series shares its type, so a type can't be
+// overlaid on a single occurrence.
+func ValidateOverlay(field string, o EventOverlay, scope OverlayScope) error {
+	switch field {
+	case OverlayLocation, OverlayDescription, OverlaySummary, OverlayStartTime, OverlayEndTime, OverlayStatus, OverlayURL, OverlayType:
+	default:
+		return fmt.Errorf("field '%s' is not allowed to be overridden", field)
+	}
+
+	switch o.MergeLogic {
+	case MergeOverwriteEmpty, MergeOverwriteAll:
+	default:
+		return fmt.Errorf("merge logic '%s' is not supported", o.MergeLogic)
+	}
+
+	value, ok := o.Value.(string)
+	if !ok {
+		return fmt.Errorf("the %s overlay must be a string", field)
+	}
+
+	switch field {
+	case OverlayStartTime, OverlayEndTime:
+		if scope == OverlayOnSeries {
+			return fmt.Errorf("%s can only be overlaid on single occurrences of a series", field)
+		}
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Errorf("the %s overlay must be an RFC 3339 time: %v", field, err)
+		}
+	case OverlayStatus:
+		if !overlayStatuses[value] {
+			return fmt.Errorf("the status overlay must be TENTATIVE, CONFIRMED or CANCELLED, got %q", value)
+		}
+	case OverlayURL:
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid url %q", value)
+		}
+	case OverlayType:
+		if scope == OverlayOnOccurrence {
+			return fmt.Errorf("type can only be overlaid on a whole series, which every occurrence shares")
+		}
+		if _, ok := EventTypeDisplayName[value]; !ok {
+			return fmt.Errorf("invalid event type %q", value)
+		}
+	}
+
+	return nil
+}
+
+// ApplyOverlays returns a copy of e with its overlays applied. Moving the
+// start keeps the event's length unless the end is overlaid too.
+func ApplyOverlays(e *Event) *Event {
+	eventCopy := *e
+	if eventCopy.Overlay == nil {
+		eventCopy.Overlay = make(map[string]EventOverlay)
+	}
+
+	for field, overlay := range e.Overlay {
+		value, ok := overlay.Value.(string)
+		if !ok {
+			continue
+		}
+
+		switch field {
+		case OverlayLocation:
+			applyString(&eventCopy.Location, value, overlay)
+		case OverlayDescription:
+			applyString(&eventCopy.Description, value, overlay)
+		case OverlayStatus:
+			applyString(&eventCopy.Status, value, overlay)
+		case OverlayURL:
+			applyString(&eventCopy.URL, value, overlay)
+		case OverlaySummary:
+			if overlay.MergeLogic == MergeOverwriteAll || (overlay.MergeLogic == MergeOverwriteEmpty && eventCopy.Summary == "") {
+				eventCopy.Summary = value
+			}
+		case OverlayType:
+			if overlay.MergeLogic == MergeOverwriteAll || (overlay.MergeLogic == MergeOverwriteEmpty && eventCopy.Type == "") {
+				eventCopy.Type = value
+			}
+		}
+	}
+
+	length := e.EndTime.Sub(e.StartTime)
+	startMoved := applyTime(&eventCopy.StartTime, e.Overlay[OverlayStartTime])
+	endMoved := applyTime(&eventCopy.EndTime, e.Overlay[OverlayEndTime])
+	if startMoved && !endMoved && !e.EndTime.IsZero() {
+		eventCopy.EndTime = eventCopy.StartTime.Add(length)
+	}
+
+	return &eventCopy
+}
+
+// applyString sets a string field as the overlay's merge logic allows
+func applyString(field **string, value string, overlay EventOverlay) {
+	switch overlay.MergeLogic {
+	case MergeOverwriteEmpty:
+		if *field != nil && **field != "" {
+			return
+		}
+	case MergeOverwriteAll:
+	default:
+		return
+	}
+
+	*field = &value
+}
+
+// applyTime sets a time field from an overlay as its merge logic allows,
+// reporting whether it did
+func applyTime(field *time.Time, overlay EventOverlay) bool {
+	value, ok := overlay.Value.(string)
+	if !ok {
+		return false
+	}
+	if overlay.MergeLogic != MergeOverwriteAll && !(overlay.MergeLogic == MergeOverwriteEmpty && field.IsZero()) {
+		return false
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return false
+	}
+	*field = t
+
+	return true
+}
+
+// InstanceOverlay returns the overlays in force on one instance of a
+// series: the root's own, less start and end times, under the root's
+// overlays for that instance, under own, the instance row's overlays when
+// it has a row. Overlays cascade field by field, except the type, which
+// only comes from the root's own overlays so every instance shares it. nil
+// is returned when no overlay applies.
+func (root *Event) InstanceOverlay(recurrenceID string, own map[string]EventOverlay, loc *time.Location) map[string]EventOverlay {
+	merged := map[string]EventOverlay{}
+	for field, overlay := range root.Overlay {
+		if !timeOverlayFields[field] {
+			overlay.FromSeries = true
+			merged[field] = overlay
+		}
+	}
+	for _, overlays := range []map[string]EventOverlay{root.occurrenceOverlay(recurrenceID, loc), own} {
+		for field, overlay := range overlays {
+			if field != OverlayType {
+				merged[field] = overlay
+			}
+		}
+	}
+
+	if len(merged) == 0 {
+		return nil
+	}
+
+	return merged
+}
+
+// occurrenceOverlay finds the root's overlays for an instance, whichever
+// form its RECURRENCE-ID was keyed in
+func (root *Event) occurrenceOverlay(recurrenceID string, loc *time.Location) map[string]EventOverlay {
+	if overlay, ok := root.OccurrenceOverlays[recurrenceID]; ok {
+		return overlay
+	}
+
+	for key, overlay := range root.OccurrenceOverlays {
+		if ContainsDate(&key, recurrenceID, loc) {
+			return overlay
+		}
+	}
+
+	return nil
+}
+
+// CascadeOverlays prepares stored rows for a feed whose readers expand
+// series themselves. Override rows take on their series' overlays, and
+// each instance overlaid without a row of its own gets an override built
+// from its root, unless the instance is excluded or no longer part of the
+// series.
+func CascadeOverlays(events []*Event, loc *time.Location) []*Event {
+	roots := map[string]*Event{}
+	for _, e := range events {
+		if e.IsRecurring() {
+			roots[e.UID] = e
+		}
+	}
+
+	out := []*Event{}
+	overridden := map[string][]string{}
+	for _, e := range events {
+		root, ok := roots[e.UID]
+		if !ok || e == root {
+			out = append(out, e)
+			continue
+		}
+
+		override := *e
+		override.Overlay = root.InstanceOverlay(*e.RecurrenceID, e.Overlay, loc)
+		out = append(out, &override)
+		overridden[e.UID] = append(overridden[e.UID], *e.RecurrenceID)
+	}
+
+	uids := make([]string, 0, len(roots))
+	for uid := range roots {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+
+	for _, uid := range uids {
+		root := roots[uid]
+
+		keys := make([]string, 0, len(root.OccurrenceOverlays))
+		for key := range root.OccurrenceOverlays {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+	instances:
+		for _, recurrenceID := range keys {
+			for _, existing := range overridden[uid] {
+				if ContainsDate(&existing, recurrenceID, loc) {
+					continue instances
+				}
+			}
+			if ContainsDate(root.ExDateManual, recurrenceID, loc) {
+				continue
+			}
+
+			instance, err := Instance(root, recurrenceID, loc)
+			if err != nil || !IsInstance(root, instance.RecurrenceID, loc) {
+				continue
+			}
+			out = append(out, instance)
+		}
+	}
+
+	return out
+}
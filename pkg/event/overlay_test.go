@@ -0,0 +1,238 @@
+package event
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestValidateOverlay(t *testing.T) {
+	overlay := func(value interface{}) EventOverlay {
+		return EventOverlay{Value: value, MergeLogic: MergeOverwriteAll}
+	}
+
+	tests := []struct {
+		name    string
+		field   string
+		overlay EventOverlay
+		scope   OverlayScope
+		wantErr bool
+	}{
+		{"location", OverlayLocation, overlay("City Hall"), OverlayOnSeries, false},
+		{"unknown field", "organization", overlay("Someone"), OverlayOnEvent, true},
+		{"unknown merge logic", OverlaySummary, EventOverlay{Value: "Ride", MergeLogic: "append"}, OverlayOnEvent, true},
+		{"not a string", OverlaySummary, overlay(42), OverlayOnEvent, true},
+		{"start time on an occurrence", OverlayStartTime, overlay("2025-03-10T19:00:00-05:00"), OverlayOnOccurrence, false},
+		{"start time on a series", OverlayStartTime, overlay("2025-03-10T19:00:00-05:00"), OverlayOnSeries, true},
+		{"invalid end time", OverlayEndTime, overlay("7pm"), OverlayOnEvent, true},
+		{"status", OverlayStatus, overlay("CANCELLED"), OverlayOnOccurrence, false},
+		{"invalid status", OverlayStatus, overlay("POSTPONED"), OverlayOnEvent, true},
+		{"url", OverlayURL, overlay("https://example.com/ride"), OverlayOnEvent, false},
+		{"relative url", OverlayURL, overlay("/ride"), OverlayOnEvent, true},
+		{"type on an event", OverlayType, overlay(EventTypeCivicMeeting), OverlayOnEvent, false},
+		{"type on a series", OverlayType, overlay(EventTypeCivicMeeting), OverlayOnSeries, false},
+		{"type on an occurrence", OverlayType, overlay(EventTypeCivicMeeting), OverlayOnOccurrence, true},
+		{"invalid type", OverlayType, overlay("party"), OverlayOnEvent, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOverlay(tt.field, tt.overlay, tt.scope)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateOverlay() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyOverlays(t *testing.T) {
+	str := func(s string) *string { return &s }
+	start := time.Date(2025, 3, 10, 23, 0, 0, 0, time.UTC)
+
+	e := &Event{
+		UID:       "1",
+		Summary:   "Ride",
+		Location:  str("Main St"),
+		StartTime: start,
+		EndTime:   start.Add(2 * time.Hour),
+		Type:      EventTypeSocialGathering,
+		Overlay: map[string]EventOverlay{
+			OverlayLocation:    {Value: "City Hall", MergeLogic: MergeOverwriteEmpty},
+			OverlayDescription: {Value: "Bring water", MergeLogic: MergeOverwriteEmpty},
+			OverlaySummary:     {Value: "Bike Ride", MergeLogic: MergeOverwriteAll},
+			OverlayStatus:      {Value: "CANCELLED", MergeLogic: MergeOverwriteAll},
+			OverlayType:        {Value: EventTypeVolunteerAction, MergeLogic: MergeOverwriteAll},
+			OverlayStartTime:   {Value: "2025-03-11T00:00:00Z", MergeLogic: MergeOverwriteAll},
+		},
+	}
+
+	got := ApplyOverlays(e)
+	if *got.Location != "Main St" {
+		t.Errorf("Location = %q, want the source's kept", *got.Location)
+	}
+	if got.Description == nil || *got.Description != "Bring water" {
+		t.Errorf("Description = %v, want the empty field filled", got.Description)
+	}
+	if got.Summary != "Bike Ride" || *got.Status != "CANCELLED" || got.Type != EventTypeVolunteerAction {
+		t.Errorf("got %q %v %q, want every overwrite_all overlay applied", got.Summary, *got.Status, got.Type)
+	}
+	if want := start.Add(time.Hour); !got.StartTime.Equal(want) || !got.EndTime.Equal(want.Add(2*time.Hour)) {
+		t.Errorf("moved to %v - %v, want the length kept", got.StartTime, got.EndTime)
+	}
+
+	// the event itself is left alone
+	if e.Summary != "Ride" || e.Description != nil || !e.StartTime.Equal(start) {
+		t.Errorf("ApplyOverlays() changed its argument: %+v", e)
+	}
+
+	e.Overlay[OverlayEndTime] = EventOverlay{Value: "2025-03-11T04:00:00Z", MergeLogic: MergeOverwriteAll}
+	got = ApplyOverlays(e)
+	if want := time.Date(2025, 3, 11, 4, 0, 0, 0, time.UTC); !got.EndTime.Equal(want) {
+		t.Errorf("EndTime = %v, want the overlaid end %v", got.EndTime, want)
+	}
+}
+
+func TestInstanceOverlay(t *testing.T) {
+	str := func(s string) *string { return &s }
+	overlay := func(value string) EventOverlay {
+		return EventOverlay{Value: value, MergeLogic: MergeOverwriteAll}
+	}
+
+	root := &Event{
+		UID:          "weekly",
+		RecurrenceID: str(""),
+		RRule:        str("FREQ=WEEKLY"),
+		Overlay: map[string]EventOverlay{
+			OverlayLocation:  overlay("City Hall"),
+			OverlaySummary:   overlay("Series Ride"),
+			OverlayType:      overlay(EventTypeCivicMeeting),
+			OverlayStartTime: overlay("2025-03-03T19:00:00Z"),
+		},
+		OccurrenceOverlays: map[string]map[string]EventOverlay{
+			"20250310T230000Z": {
+				OverlaySummary: overlay("Occurrence Ride"),
+				OverlayStatus:  overlay("CANCELLED"),
+				OverlayType:    overlay(EventTypeVolunteerAction),
+			},
+		},
+	}
+	own := map[string]EventOverlay{
+		OverlayStatus: overlay("CONFIRMED"),
+		OverlayType:   overlay(EventTypeSocialGathering),
+	}
+
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keyed in UTC, looked up by the same instant in its floating form
+	got := root.InstanceOverlay("20250310T180000", own, chicago)
+
+	want := map[string]string{
+		OverlayLocation: "City Hall",
+		OverlaySummary:  "Occurrence Ride",
+		OverlayStatus:   "CONFIRMED",
+		OverlayType:     EventTypeCivicMeeting,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got overlays on %v, want %v", keys(got), want)
+	}
+	for field, value := range want {
+		if got[field].Value != value {
+			t.Errorf("%s = %v, want %v", field, got[field].Value, value)
+		}
+	}
+	if !got[OverlayLocation].FromSeries || got[OverlaySummary].FromSeries {
+		t.Error("only the overlays from the series should be marked FromSeries")
+	}
+
+	if got := (&Event{UID: "plain"}).InstanceOverlay("20250310T230000Z", nil, chicago); got != nil {
+		t.Errorf("InstanceOverlay() without overlays = %v, want nil", got)
+	}
+}
+
+func keys(m map[string]EventOverlay) []string {
+	out := []string{}
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestCascadeOverlays(t *testing.T) {
+	str := func(s string) *string { return &s }
+	overlay := func(value string) EventOverlay {
+		return EventOverlay{Value: value, MergeLogic: MergeOverwriteAll}
+	}
+	start := time.Date(2025, 3, 3, 23, 0, 0, 0, time.UTC)
+
+	root := &Event{
+		UID:          "weekly",
+		StartTime:    start,
+		EndTime:      start.Add(time.Hour),
+		RecurrenceID: str(""),
+		RRule:        str("FREQ=WEEKLY"),
+		ExDateManual: str("20250324T230000Z"),
+		Type:         EventTypeSocialGathering,
+		Overlay:      map[string]EventOverlay{OverlayType: overlay(EventTypeCivicMeeting)},
+		OccurrenceOverlays: map[string]map[string]EventOverlay{
+			// has a row of its own
+			"20250310T230000Z": {OverlaySummary: overlay("Row Ride")},
+			// expanded
+			"20250317T230000Z": {OverlaySummary: overlay("Moved Ride"), OverlayType: overlay(EventTypeVolunteerAction)},
+			// rejected
+			"20250324T230000Z": {OverlaySummary: overlay("Rejected Ride")},
+			// not part of the series
+			"20250320T230000Z": {OverlaySummary: overlay("Stray Ride")},
+		},
+	}
+	override := &Event{
+		UID:          "weekly",
+		StartTime:    start.AddDate(0, 0, 7),
+		EndTime:      start.AddDate(0, 0, 7).Add(time.Hour),
+		RecurrenceID: str("20250310T230000Z"),
+		Type:         EventTypeSocialGathering,
+		Overlay:      map[string]EventOverlay{OverlayLocation: overlay("City Hall")},
+	}
+	oneOff := &Event{UID: "one-off", StartTime: start, RecurrenceID: str("")}
+
+	out := CascadeOverlays([]*Event{root, override, oneOff}, time.UTC)
+
+	got := map[string]*Event{}
+	for _, e := range out {
+		got[e.UID+" "+*e.RecurrenceID] = ApplyOverlays(e)
+	}
+
+	wantKeys := []string{"one-off ", "weekly ", "weekly 20250310T230000Z", "weekly 20250317T230000Z"}
+	gotKeys := []string{}
+	for k := range got {
+		gotKeys = append(gotKeys, k)
+	}
+	sort.Strings(gotKeys)
+	if !reflect.DeepEqual(gotKeys, wantKeys) {
+		t.Fatalf("got %v, want %v", gotKeys, wantKeys)
+	}
+
+	row := got["weekly 20250310T230000Z"]
+	if *row.Location != "City Hall" {
+		t.Errorf("override row lost its own overlay: %v", row.Location)
+	}
+	if override.Overlay[OverlayType].Value != nil {
+		t.Error("CascadeOverlays() changed a stored row's overlays")
+	}
+
+	expanded := got["weekly 20250317T230000Z"]
+	if expanded.Summary != "Moved Ride" || !expanded.StartTime.Equal(start.AddDate(0, 0, 14)) {
+		t.Errorf("expanded override = %q at %v", expanded.Summary, expanded.StartTime)
+	}
+
+	// every event sharing the UID keeps the series' type
+	for key, e := range got {
+		if e.UID == "weekly" && e.Type != EventTypeCivicMeeting {
+			t.Errorf("%s has type %s, want the series' %s", key, e.Type, EventTypeCivicMeeting)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Actor types recorded on revisions
+const (
+	ActorSync        = "sync"
+	ActorDiscordUser = "discord_user"
+	ActorSystem      = "system"
+)
+
+// Revision actions
+const (
+	ActionInsert = "insert"
+	ActionSync   = "sync"
+	ActionPatch  = "patch"
+	ActionPrune  = "prune"
+	ActionRevert = "revert"
+)
+
+// ModerationFields are the JSON names of the fields moderators own, which
+// a revert restores
+var ModerationFields = []string{"review_status", "organization", "type", "exdate_manual", "overlay", "occurrence_overlays"}
+
+// Actor is who made a change: a sync run, identified by its run ID, or a
+// Discord user
+type Actor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// Change is a single field's value before and after a revision
+type Change struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// Revision is one recorded change to an event
+type Revision struct {
+	ID           int               `json:"id"`
+	UID          string            `json:"uid"`
+	RecurrenceID string            `json:"recurrence_id"`
+	Action       string            `json:"action"`
+	Actor        Actor             `json:"actor"`
+	Changes      map[string]Change `json:"changes"`
+	Created      time.Time         `json:"created"`
+}
+
+type GetRevisionsInput struct {
+	UID string
+
+	// RecurrenceID limits the revisions to one instance; nil returns the
+	// revisions of every instance with the UID
+	RecurrenceID *string
+}
+
+// Diff returns the fields that differ between two versions of an event,
+// keyed by JSON name. before is nil for a newly inserted event.
+func Diff(before, after *Event) (map[string]Change, error) {
+	from, err := fieldValues(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := fieldValues(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]Change{}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			from[name] = json.RawMessage("null")
+		}
+	}
+	for name, f := range from {
+		t, ok := to[name]
+		if !ok {
+			t = json.RawMessage("null")
+		}
+
+		same, err := sameJSON(f, t)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			changes[name] = Change{From: f, To: t}
+		}
+	}
+
+	return changes, nil
+}
+
+// RevertModeration returns the patch that puts e's moderation fields back
+// to how they were right after revision id, undoing every later moderator
+// revision. Changes made by sync, such as a significant upstream change
+// sending the event back for review, are left alone. revisions must be e's
+// own revisions. nil is returned if nothing would change.
+func RevertModeration(e *Event, revisions []*Revision, id int) (*PatchEventInput, error) {
+	current, err := fieldValues(e)
+	if err != nil {
+		return nil, err
+	}
+
+	target := map[string]json.RawMessage{}
+	for _, name := range ModerationFields {
+		target[name] = current[name]
+	}
+
+	// undo the later revisions newest first, so the value from before the
+	// earliest of them is the one left
+	later := []*Revision{}
+	for _, r := range revisions {
+		if r.ID > id && r.Actor.Type != ActorSync {
+			later = append(later, r)
+		}
+	}
+	sort.Slice(later, func(i, j int) bool { return later[i].ID > later[j].ID })
+
+	for _, r := range later {
+		for _, name := range ModerationFields {
+			if c, ok := r.Changes[name]; ok {
+				target[name] = c.From
+			}
+		}
+	}
+
+	pi := &PatchEventInput{Action: ActionRevert}
+	changed := false
+	for _, name := range ModerationFields {
+		same, err := sameJSON(current[name], target[name])
+		if err != nil {
+			return nil, err
+		}
+		if same {
+			continue
+		}
+		changed = true
+
+		value := []byte(target[name])
+		switch name {
+		case "review_status":
+			var status string
+			err = json.Unmarshal(value, &status)
+			pi.ReviewStatus = &status
+		case "organization":
+			var organization string
+			err = json.Unmarshal(value, &organization)
+			pi.Organization = &organization
+		case "type":
+			var eventType string
+			err = json.Unmarshal(value, &eventType)
+			pi.Type = &eventType
+		case "exdate_manual":
+			var exdates *string
+			err = json.Unmarshal(value, &exdates)
+			if exdates == nil {
+				empty := ""
+				exdates = &empty
+			}
+			pi.ExDateManual = exdates
+		case "overlay":
+			overlay := map[string]EventOverlay{}
+			err = json.Unmarshal(value, &overlay)
+			if overlay == nil {
+				overlay = map[string]EventOverlay{}
+			}
+			pi.Overlay = overlay
+		case "occurrence_overlays":
+			overlays := map[string]map[string]EventOverlay{}
+			err = json.Unmarshal(value, &overlays)
+			if overlays == nil {
+				overlays = map[string]map[string]EventOverlay{}
+			}
+			pi.OccurrenceOverlays = overlays
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %v", name, err)
+		}
+	}
+
+	if !changed {
+		return nil, nil
+	}
+
+	return pi, nil
+}
+
+// fieldValues returns the JSON encoding of each of e's fields, with every
+// field present even when omitted from e's JSON form
+func fieldValues(e *Event) (map[string]json.RawMessage, error) {
+	values := map[string]json.RawMessage{}
+	if e == nil {
+		return values, nil
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %v", err)
+	}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, fmt.Errorf("failed to decode event fields: %v", err)
+	}
+
+	for _, name := range ModerationFields {
+		if _, ok := values[name]; !ok {
+			values[name] = json.RawMessage("null")
+		}
+	}
+
+	// clearing the manual exdates stores "", which means the same as none
+	if string(values["exdate_manual"]) == `""` {
+		values["exdate_manual"] = json.RawMessage("null")
+	}
+
+	return values, nil
+}
+
+// sameJSON compares two JSON values semantically, so values that went
+// through a JSONB column still match their original encoding
+func sameJSON(a, b json.RawMessage) (bool, error) {
+	if len(a) == 0 {
+		a = json.RawMessage("null")
+	}
+	if len(b) == 0 {
+		b = json.RawMessage("null")
+	}
+
+	var av, bv interface{}
+	if err := json.Unmarshal(a, &av); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(b, &bv); err != nil {
+		return false, err
+	}
+
+	// an overlay cleared to {} is the same as having none
+	if m, ok := av.(map[string]interface{}); ok && len(m) == 0 {
+		av = nil
+	}
+	if m, ok := bv.(map[string]interface{}); ok && len(m) == 0 {
+		bv = nil
+	}
+
+	return reflect.DeepEqual(av, bv), nil
+}
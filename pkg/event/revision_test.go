@@ -0,0 +1,187 @@
+package event
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name   string
+		before *Event
+		after  *Event
+		want   []string
+	}{
+		{"insert", nil, &Event{UID: "1", Type: EventTypeCivicMeeting}, []string{"uid", "type"}},
+		{"no change", &Event{UID: "1"}, &Event{UID: "1"}, nil},
+		{"exdate_manual null and empty", &Event{UID: "1"}, &Event{UID: "1", ExDateManual: str("")}, nil},
+		{"exdate_manual set", &Event{UID: "1", ExDateManual: str("")}, &Event{UID: "1", ExDateManual: str("20250301T180000Z")}, []string{"exdate_manual"}},
+		{"overlay cleared to {}", &Event{UID: "1", Overlay: map[string]EventOverlay{}}, &Event{UID: "1"}, nil},
+		{"overlay added", &Event{UID: "1"}, &Event{UID: "1", Overlay: map[string]EventOverlay{"summary": {Value: "Ride"}}}, []string{"overlay"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := Diff(tt.before, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.want {
+				if _, ok := changes[name]; !ok {
+					t.Errorf("Diff() missing a change to %s", name)
+				}
+			}
+			if tt.want == nil && len(changes) != 0 {
+				t.Errorf("Diff() = %v, want no changes", changes)
+			}
+		})
+	}
+}
+
+// revision builds a revision whose changes are given as from/to JSON pairs
+func revision(id int, actor string, changes map[string][2]string) *Revision {
+	r := &Revision{ID: id, Actor: Actor{Type: actor}, Changes: map[string]Change{}}
+	for name, c := range changes {
+		r.Changes[name] = Change{From: json.RawMessage(c[0]), To: json.RawMessage(c[1])}
+	}
+	return r
+}
+
+func TestRevertModeration(t *testing.T) {
+	t.Run("past several later revisions", func(t *testing.T) {
+		e := &Event{UID: "1", ReviewStatus: ReviewRejected, Type: EventTypeVolunteerAction}
+		revisions := []*Revision{
+			revision(4, ActorDiscordUser, map[string][2]string{"type": {`"social_gathering"`, `"volunteer_action"`}}),
+			revision(3, ActorDiscordUser, map[string][2]string{"review_status": {`"approved"`, `"rejected"`}}),
+			revision(2, ActorDiscordUser, map[string][2]string{
+				"review_status": {`"pending"`, `"approved"`},
+				"type":          {`"civic_meeting"`, `"social_gathering"`},
+			}),
+			revision(1, ActorSync, map[string][2]string{"review_status": {`null`, `"pending"`}}),
+		}
+
+		pi, err := RevertModeration(e, revisions, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pi == nil || pi.ReviewStatus == nil || *pi.ReviewStatus != ReviewApproved {
+			t.Fatalf("ReviewStatus = %v, want approved", pi)
+		}
+		if pi.Type == nil || *pi.Type != EventTypeSocialGathering {
+			t.Errorf("Type = %v, want %s", pi.Type, EventTypeSocialGathering)
+		}
+
+		pi, err = RevertModeration(e, revisions, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *pi.ReviewStatus != ReviewPending || *pi.Type != EventTypeCivicMeeting {
+			t.Errorf("revert to 1 = %s/%s, want pending/civic_meeting", *pi.ReviewStatus, *pi.Type)
+		}
+		if pi.Action != ActionRevert {
+			t.Errorf("Action = %q, want %q", pi.Action, ActionRevert)
+		}
+	})
+
+	t.Run("overlay cleared to {}", func(t *testing.T) {
+		e := &Event{UID: "1", Overlay: map[string]EventOverlay{}}
+		revisions := []*Revision{
+			revision(2, ActorDiscordUser, map[string][2]string{"overlay": {`{"summary":{"value":"Ride","mergeLogic":"replace","source":"","timestamp":""}}`, `{}`}}),
+		}
+
+		pi, err := RevertModeration(e, revisions, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pi == nil || pi.Overlay["summary"].Value != "Ride" {
+			t.Fatalf("Overlay = %v, want the summary overlay back", pi)
+		}
+
+		// undoing the overlay's addition clears it with an empty map
+		e = &Event{UID: "1", Overlay: map[string]EventOverlay{"summary": {Value: "Ride"}}}
+		revisions = []*Revision{
+			revision(2, ActorDiscordUser, map[string][2]string{"overlay": {`null`, `{"summary":{"value":"Ride"}}`}}),
+		}
+		pi, err = RevertModeration(e, revisions, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pi == nil || pi.Overlay == nil || len(pi.Overlay) != 0 {
+			t.Errorf("Overlay = %v, want an empty, non-nil overlay", pi)
+		}
+	})
+
+	t.Run("exdate_manual null and empty", func(t *testing.T) {
+		empty := ""
+		e := &Event{UID: "1", ExDateManual: &empty}
+		revisions := []*Revision{
+			revision(2, ActorDiscordUser, map[string][2]string{"exdate_manual": {`"20250301T180000Z"`, `""`}}),
+		}
+
+		pi, err := RevertModeration(e, revisions, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pi == nil || pi.ExDateManual == nil || *pi.ExDateManual != "20250301T180000Z" {
+			t.Fatalf("ExDateManual = %v, want 20250301T180000Z", pi)
+		}
+
+		// null before and "" now are both no manual exdates
+		revisions = []*Revision{
+			revision(2, ActorDiscordUser, map[string][2]string{"exdate_manual": {`null`, `""`}}),
+		}
+		pi, err = RevertModeration(e, revisions, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pi != nil {
+			t.Errorf("RevertModeration() = %+v, want nil", pi)
+		}
+
+		// restoring null clears the column with ""
+		set := "20250301T180000Z"
+		e = &Event{UID: "1", ExDateManual: &set}
+		revisions = []*Revision{
+			revision(2, ActorDiscordUser, map[string][2]string{"exdate_manual": {`null`, `"20250301T180000Z"`}}),
+		}
+		pi, err = RevertModeration(e, revisions, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pi == nil || pi.ExDateManual == nil || *pi.ExDateManual != "" {
+			t.Errorf("ExDateManual = %v, want \"\"", pi)
+		}
+	})
+
+	t.Run("changes by sync are left alone", func(t *testing.T) {
+		e := &Event{UID: "1", Summary: "New title", ReviewStatus: ReviewRejected}
+		revisions := []*Revision{
+			revision(4, ActorDiscordUser, map[string][2]string{"review_status": {`"needs_review"`, `"rejected"`}}),
+			revision(3, ActorSync, map[string][2]string{
+				"summary":       {`"Old title"`, `"New title"`},
+				"review_status": {`"approved"`, `"needs_review"`},
+			}),
+			revision(2, ActorDiscordUser, map[string][2]string{"review_status": {`"pending"`, `"approved"`}}),
+		}
+
+		pi, err := RevertModeration(e, revisions, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pi == nil || pi.ReviewStatus == nil || *pi.ReviewStatus != ReviewNeedsReview {
+			t.Fatalf("ReviewStatus = %v, want needs_review", pi)
+		}
+
+		// only a sync revision is newer, so there is nothing to undo
+		e.ReviewStatus = ReviewNeedsReview
+		pi, err = RevertModeration(e, revisions[1:], 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pi != nil {
+			t.Errorf("RevertModeration() = %+v, want nil", pi)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotRecurring is returned by ReviewSeries for a UID without a
+// recurring root event
+var ErrNotRecurring = errors.New("event is not a recurring series")
+
+// SeriesHorizonYears bounds a series review with an open end, since a
+// rule without COUNT or UNTIL never ends
+const SeriesHorizonYears = 2
+
+// SeriesPatch is one row's share of a series review
+type SeriesPatch struct {
+	Event GetEventInput
+	Patch PatchEventInput
+}
+
+// SeriesReview is the plan for a series review
+type SeriesReview struct {
+	Patches []SeriesPatch
+
+	// From and To are the range of occurrences a ranged review covered,
+	// with an open end filled in; both are zero for a whole series review
+	From time.Time
+	To   time.Time
+}
+
+// ReviewSeries plans the patches that give every occurrence of a series
+// in [from, to) the review status. rows are all the rows sharing the
+// series' UID.
+//
+// With no range the root and every override row take the status, and
+// approving also clears the root's manual exdates. With a range, rejecting
+// adds each occurrence to the root's manual exdates and any other status
+// takes them back out; override rows in the range take the status too.
+// Expanded occurrences otherwise follow the root's own status, which a
+// range leaves alone. A range's open end is SeriesHorizonYears after its
+// start, so occurrences after that are left alone; the range used is
+// returned. A range needs rules that can be read.
+func ReviewSeries(rows []*Event, status string, from, to *time.Time, loc *time.Location) (*SeriesReview, error) {
+	var root *Event
+	for _, e := range rows {
+		if e.IsRecurring() {
+			root = e
+		}
+	}
+	if root == nil {
+		return nil, ErrNotRecurring
+	}
+
+	review := &SeriesReview{}
+	patches := []SeriesPatch{}
+	setStatus := func(e *Event) error {
+		if e.ReviewStatus == status {
+			return nil
+		}
+		if err := CheckReviewTransition(e.ReviewStatus, status); err != nil {
+			return fmt.Errorf("%s: %v", describeInstance(e), err)
+		}
+
+		s := status
+		patches = append(patches, SeriesPatch{
+			Event: GetEventInput{UID: e.UID, RecurrenceID: e.RecurrenceID},
+			Patch: PatchEventInput{ReviewStatus: &s},
+		})
+
+		return nil
+	}
+
+	exdates := []string{}
+	if root.ExDateManual != nil && *root.ExDateManual != "" {
+		exdates = strings.Split(*root.ExDateManual, ",")
+	}
+	exdatesChanged := false
+
+	if from == nil && to == nil {
+		for _, e := range rows {
+			if err := setStatus(e); err != nil {
+				return nil, err
+			}
+		}
+
+		if status != ReviewRejected && len(exdates) > 0 {
+			exdates = nil
+			exdatesChanged = true
+		}
+	} else {
+		start, end := root.StartTime, root.StartTime.AddDate(SeriesHorizonYears, 0, 0)
+		if from != nil {
+			start = *from
+			end = start.AddDate(SeriesHorizonYears, 0, 0)
+		}
+		if to != nil {
+			end = *to
+		}
+		review.From, review.To = start, end
+
+		// an unreadable series can't be expanded into the occurrences the
+		// manual exdates name
+		if _, ok := instanceStarts(root, start, end, loc); !ok {
+			return nil, fmt.Errorf("%s: the series' rules can't be read, so only the whole series can be reviewed", root.UID)
+		}
+
+		// from is inclusive
+		for _, o := range ExpandOccurrences(rows, start, end, loc) {
+			if !o.Generated {
+				if err := setStatus(o.Event); err != nil {
+					return nil, err
+				}
+			}
+
+			if o.RecurrenceID == nil || *o.RecurrenceID == "" {
+				continue
+			}
+			recurrenceID := *o.RecurrenceID
+			listed := ContainsDate(root.ExDateManual, recurrenceID, loc)
+			switch {
+			case status == ReviewRejected && !listed:
+				exdates = append(exdates, recurrenceID)
+				exdatesChanged = true
+			case status != ReviewRejected && listed:
+				kept := []string{}
+				for _, exdate := range exdates {
+					if !ContainsDate(&exdate, recurrenceID, loc) {
+						kept = append(kept, exdate)
+					}
+				}
+				exdates = kept
+				exdatesChanged = true
+			}
+		}
+	}
+
+	if exdatesChanged {
+		joined := strings.Join(exdates, ",")
+		for i := range patches {
+			if rid := patches[i].Event.RecurrenceID; rid == nil || *rid == "" {
+				patches[i].Patch.ExDateManual = &joined
+				review.Patches = patches
+				return review, nil
+			}
+		}
+
+		patches = append(patches, SeriesPatch{
+			Event: GetEventInput{UID: root.UID},
+			Patch: PatchEventInput{ExDateManual: &joined},
+		})
+	}
+
+	review.Patches = patches
+	return review, nil
+}
+
+func describeInstance(e *Event) string {
+	if e.RecurrenceID == nil || *e.RecurrenceID == "" {
+		return e.UID
+	}
+
+	return fmt.Sprintf("%s %s", e.UID, *e.RecurrenceID)
+}
@@ -0,0 +1,167 @@
+package event
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReviewSeries(t *testing.T) {
+	str := func(s string) *string { return &s }
+	at := func(month time.Month, day int) time.Time {
+		return time.Date(2025, month, day, 18, 0, 0, 0, time.UTC)
+	}
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	// a weekly series from March 3rd with an override row on March 10th
+	series := func(status string, exdates string) []*Event {
+		return []*Event{
+			{UID: "weekly", StartTime: at(3, 3), EndTime: at(3, 3).Add(time.Hour), RecurrenceID: str(""), RRule: str("FREQ=WEEKLY"), ReviewStatus: status, ExDateManual: str(exdates)},
+			{UID: "weekly", StartTime: at(3, 10).Add(time.Hour), EndTime: at(3, 10).Add(2 * time.Hour), RecurrenceID: str("20250310T180000Z"), ReviewStatus: status},
+		}
+	}
+
+	// patchFor returns the patch of the row with the recurrence ID, "" for
+	// the root
+	patchFor := func(review *SeriesReview, recurrenceID string) *PatchEventInput {
+		for i, p := range review.Patches {
+			rid := ""
+			if p.Event.RecurrenceID != nil {
+				rid = *p.Event.RecurrenceID
+			}
+			if rid == recurrenceID {
+				return &review.Patches[i].Patch
+			}
+		}
+		return nil
+	}
+
+	t.Run("approve the whole series", func(t *testing.T) {
+		review, err := ReviewSeries(series(ReviewPending, "20250317T180000Z"), ReviewApproved, nil, nil, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		root, override := patchFor(review, ""), patchFor(review, "20250310T180000Z")
+		if root == nil || root.ReviewStatus == nil || *root.ReviewStatus != ReviewApproved {
+			t.Fatalf("root patch = %+v, want approved", root)
+		}
+		if root.ExDateManual == nil || *root.ExDateManual != "" {
+			t.Errorf("root exdates = %v, want them cleared", root.ExDateManual)
+		}
+		if override == nil || *override.ReviewStatus != ReviewApproved {
+			t.Errorf("override patch = %+v, want approved", override)
+		}
+		if !review.From.IsZero() || !review.To.IsZero() {
+			t.Errorf("range = %v to %v, want none", review.From, review.To)
+		}
+	})
+
+	t.Run("reject the whole series", func(t *testing.T) {
+		review, err := ReviewSeries(series(ReviewApproved, "20250317T180000Z"), ReviewRejected, nil, nil, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		root := patchFor(review, "")
+		if root == nil || *root.ReviewStatus != ReviewRejected {
+			t.Fatalf("root patch = %+v, want rejected", root)
+		}
+		if root.ExDateManual != nil {
+			t.Errorf("root exdates changed to %q", *root.ExDateManual)
+		}
+		if len(review.Patches) != 2 {
+			t.Errorf("got %d patches, want the root and the override", len(review.Patches))
+		}
+	})
+
+	t.Run("reject a range", func(t *testing.T) {
+		review, err := ReviewSeries(series(ReviewApproved, ""), ReviewRejected, ptr(at(3, 10)), ptr(at(3, 24)), time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		root := patchFor(review, "")
+		if root == nil || root.ReviewStatus != nil {
+			t.Fatalf("root patch = %+v, want only its exdates changed", root)
+		}
+		if got := *root.ExDateManual; got != "20250310T180000Z,20250317T180000Z" {
+			t.Errorf("root exdates = %q, want March 10th and 17th", got)
+		}
+		if override := patchFor(review, "20250310T180000Z"); override == nil || *override.ReviewStatus != ReviewRejected {
+			t.Errorf("override patch = %+v, want rejected", override)
+		}
+		if !review.From.Equal(at(3, 10)) || !review.To.Equal(at(3, 24)) {
+			t.Errorf("range = %v to %v", review.From, review.To)
+		}
+	})
+
+	t.Run("approve a range", func(t *testing.T) {
+		rows := series(ReviewApproved, "20250303T180000Z,20250317T180000Z,20250324T180000Z")
+		rows[1].ReviewStatus = ReviewRejected
+
+		review, err := ReviewSeries(rows, ReviewApproved, ptr(at(3, 10)), ptr(at(3, 24)), time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		root := patchFor(review, "")
+		if root == nil || root.ReviewStatus != nil {
+			t.Fatalf("root patch = %+v, want only its exdates changed", root)
+		}
+		if got := *root.ExDateManual; got != "20250303T180000Z,20250324T180000Z" {
+			t.Errorf("root exdates = %q, want those outside the range kept", got)
+		}
+		if override := patchFor(review, "20250310T180000Z"); override == nil || *override.ReviewStatus != ReviewApproved {
+			t.Errorf("override patch = %+v, want approved", override)
+		}
+	})
+
+	t.Run("open end stops at the horizon", func(t *testing.T) {
+		review, err := ReviewSeries(series(ReviewApproved, ""), ReviewRejected, ptr(at(3, 24)), nil, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := at(3, 24).AddDate(SeriesHorizonYears, 0, 0)
+		if !review.To.Equal(want) {
+			t.Errorf("range ends %v, want %v", review.To, want)
+		}
+
+		exdates := strings.Split(*patchFor(review, "").ExDateManual, ",")
+		if exdates[0] != "20250324T180000Z" {
+			t.Errorf("first exdate = %s, want the start of the range", exdates[0])
+		}
+		last, err := time.Parse("20060102T150405Z", exdates[len(exdates)-1])
+		if err != nil || !last.Before(want) || last.AddDate(0, 0, 7).Before(want) {
+			t.Errorf("last exdate = %s, want the last occurrence before %v", exdates[len(exdates)-1], want)
+		}
+	})
+
+	t.Run("range over unreadable rules", func(t *testing.T) {
+		rows := series(ReviewApproved, "")
+		rows[0].RRule = str("FREQ=SOMETIMES")
+
+		_, err := ReviewSeries(rows, ReviewRejected, ptr(at(3, 1)), ptr(at(4, 1)), time.UTC)
+		if err == nil {
+			t.Error("ReviewSeries() over unreadable rules succeeded")
+		}
+
+		// the whole series can still be reviewed
+		review, err := ReviewSeries(rows, ReviewRejected, nil, nil, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root := patchFor(review, ""); root == nil || root.ExDateManual != nil {
+			t.Errorf("root patch = %+v, want only its status changed", root)
+		}
+	})
+
+	t.Run("not a series", func(t *testing.T) {
+		rows := []*Event{{UID: "one-off", StartTime: at(3, 3), RecurrenceID: str("")}}
+		if _, err := ReviewSeries(rows, ReviewApproved, nil, nil, time.UTC); !errors.Is(err, ErrNotRecurring) {
+			t.Errorf("ReviewSeries() = %v, want ErrNotRecurring", err)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package event
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// UpsertEventsInput is a batch of events from an organization's source
+type UpsertEventsInput struct {
+	Events []*Event
+	Actor  Actor
+}
+
+// UpsertEventsResult counts what UpsertEvents did with each event
+type UpsertEventsResult struct {
+	Inserted int
+	Updated  int
+	Restored int
+
+	// Unchanged counts the stored events left alone because the source
+	// hadn't changed them
+	Unchanged int
+}
+
+// SyncColumn is a column UpsertEvents writes. A coalesced one keeps its
+// stored value when the source leaves it out.
+type SyncColumn struct {
+	Name     string
+	Coalesce bool
+}
+
+// SyncColumns are the columns UpsertEvents writes, each only when its value
+// changed. The sync dry run applies the same rules.
+var SyncColumns = []SyncColumn{
+	{"summary", false},
+	{"description", true},
+	{"location", true},
+	{"start_time", false},
+	{"end_time", false},
+	{"all_day", false},
+	{"tzid", true},
+	{"modified_time", true},
+	{"status", true},
+	{"transparency", true},
+	{"url", true},
+	{"sequence", false},
+	{"rrule", true},
+	{"rdate", true},
+	{"exdate", true},
+	{"fingerprint", false},
+}
+
+// NeedsSync reports whether an event with the given SourceFingerprint has
+// to be written over stored, the version already in the database or nil if
+// there is none
+func NeedsSync(stored *Event, fingerprint string) bool {
+	return stored == nil || stored.DeletedAt != nil ||
+		stored.Fingerprint == nil || *stored.Fingerprint != fingerprint
+}
+
+// Count records what an upsert did with one event. stored is the version
+// from before the upsert, nil for a new event. written reports whether the
+// row was written at all and changed whether that changed more than its
+// fingerprint.
+func (r *UpsertEventsResult) Count(stored *Event, written, changed bool) {
+	switch {
+	case !written:
+		r.Unchanged++
+	case stored == nil:
+		r.Inserted++
+	case stored.DeletedAt != nil:
+		r.Restored++
+	case !changed:
+		r.Unchanged++
+	default:
+		r.Updated++
+	}
+}
+
+// SourceFingerprint hashes the fields of e that come from its source, so
+// two imports of the same event can be compared without loading every field
+func (e *Event) SourceFingerprint() string {
+	source := struct {
+		Summary      string     `json:"summary"`
+		Description  *string    `json:"description"`
+		Location     *string    `json:"location"`
+		StartTime    time.Time  `json:"start_time"`
+		EndTime      time.Time  `json:"end_time"`
+		AllDay       bool       `json:"all_day"`
+		TZID         *string    `json:"tzid"`
+		Modified     *time.Time `json:"modified"`
+		Status       *string    `json:"status"`
+		Transparency *string    `json:"transparency"`
+		URL          *string    `json:"url"`
+		Sequence     int        `json:"sequence"`
+		RRule        *string    `json:"rrule"`
+		RDate        *string    `json:"rdate"`
+		ExDate       *string    `json:"exdate"`
+	}{
+		e.Summary, e.Description, e.Location,
+		e.StartTime.UTC(), e.EndTime.UTC(), e.AllDay, e.TZID, utc(e.Modified),
+		e.Status, e.Transparency, e.URL, e.Sequence,
+		e.RRule, e.RDate, e.ExDate,
+	}
+
+	// a struct of strings, times and numbers always encodes
+	b, _ := json.Marshal(source)
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	u := t.UTC()
+	return &u
+}
+
+// SignificantChanges returns the JSON names of the fields whose change
+// sends a reviewed event back for review
+func SignificantChanges(existing *Event, new *Event) []string {
+	fields := []string{}
+
+	if existing.Summary != new.Summary {
+		fields = append(fields, "summary")
+	}
+	if existing.Sequence < new.Sequence {
+		fields = append(fields, "sequence")
+	}
+	if existing.AllDay != new.AllDay {
+		fields = append(fields, "all_day")
+	}
+
+	// Check if time changed (within 1 minute tolerance)
+	if !existing.StartTime.Equal(new.StartTime) {
+		diff := existing.StartTime.Sub(new.StartTime)
+		if diff < -time.Minute || diff > time.Minute {
+			fields = append(fields, "start_time")
+		}
+	}
+
+	// Check if location changed
+	existingLocation := ""
+	if existing.Location != nil {
+		existingLocation = *existing.Location
+	}
+
+	newLocation := ""
+	if new.Location != nil {
+		newLocation = *new.Location
+	}
+
+	if existingLocation != newLocation {
+		fields = append(fields, "location")
+	}
+
+	return fields
+}
@@ -0,0 +1,99 @@
+package event
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNeedsSync(t *testing.T) {
+	e := &Event{
+		UID:       "1@example.com",
+		Summary:   "Bike Ride",
+		StartTime: time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC),
+	}
+	fingerprint := e.SourceFingerprint()
+	stale := "stale"
+	deleted := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		stored *Event
+		want   bool
+	}{
+		{"new event", nil, true},
+		{"same fingerprint", &Event{Fingerprint: &fingerprint}, false},
+		{"different fingerprint", &Event{Fingerprint: &stale}, true},
+		{"stored before fingerprints", &Event{}, true},
+		{"pruned with the same fingerprint", &Event{Fingerprint: &fingerprint, DeletedAt: &deleted}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsSync(tt.stored, fingerprint); got != tt.want {
+				t.Errorf("NeedsSync() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceFingerprint(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := func(s string) *string { return &s }
+	base := Event{
+		UID:       "1@example.com",
+		Summary:   "Bike Ride",
+		StartTime: time.Date(2025, 3, 1, 18, 0, 0, 0, chicago),
+		EndTime:   time.Date(2025, 3, 1, 20, 0, 0, 0, chicago),
+	}
+
+	// moderator fields and the zone a time is read in aren't from the source
+	same := base
+	same.StartTime = base.StartTime.UTC()
+	same.ReviewStatus = ReviewApproved
+	same.Overlay = map[string]EventOverlay{"summary": {}}
+	if base.SourceFingerprint() != same.SourceFingerprint() {
+		t.Error("fields not from the source changed the fingerprint")
+	}
+
+	changed := base
+	changed.Location = str("Main St")
+	if base.SourceFingerprint() == changed.SourceFingerprint() {
+		t.Error("a new location kept the same fingerprint")
+	}
+}
+
+func TestUpsertEventsResultCount(t *testing.T) {
+	deleted := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	stored := &Event{UID: "1@example.com"}
+	pruned := &Event{UID: "1@example.com", DeletedAt: &deleted}
+
+	tests := []struct {
+		name    string
+		stored  *Event
+		written bool
+		changed bool
+		want    UpsertEventsResult
+	}{
+		{"new event", nil, true, true, UpsertEventsResult{Inserted: 1}},
+		{"changed event", stored, true, true, UpsertEventsResult{Updated: 1}},
+		{"pruned event", pruned, true, true, UpsertEventsResult{Restored: 1}},
+		{"pruned event, unchanged otherwise", pruned, true, false, UpsertEventsResult{Restored: 1}},
+		{"only the fingerprint written", stored, true, false, UpsertEventsResult{Unchanged: 1}},
+		{"skipped by fingerprint", stored, false, false, UpsertEventsResult{Unchanged: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got UpsertEventsResult
+			got.Count(tt.stored, tt.written, tt.changed)
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
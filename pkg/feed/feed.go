@@ -0,0 +1,30 @@
+package feed
+
+import "time"
+
+// Validators are the HTTP cache validators and content hash recorded for an
+// organization's feed after its last successful sync
+type Validators struct {
+	Organization string
+	URL          string
+	ETag         *string
+	LastModified *string
+	ContentHash  *string
+	CheckedAt    *time.Time
+
+	// ImporterVersion and SettingsHash record what the feed was imported
+	// with. The other validators only count while both still match.
+	ImporterVersion int
+	SettingsHash    *string
+
+	// Pages holds the validators of each page, in order, for sources that
+	// are fetched a page at a time. Each is keyed on its page's URL.
+	Pages []Validators `json:",omitempty"`
+}
+
+type Repository interface {
+	// GetValidators returns nil without an error when the organization has
+	// never been synced
+	GetValidators(organization string) (*Validators, error)
+	SaveValidators(*Validators) error
+}
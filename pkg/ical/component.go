@@ -0,0 +1,144 @@
+package ical
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Component is a BEGIN/END block such as VCALENDAR, VEVENT or VALARM, with
+// its own properties and any nested components
+type Component struct {
+	Name       string
+	Properties []*Property
+	Components []*Component
+}
+
+// Prop returns the first property with the given name, or nil
+func (c *Component) Prop(name string) *Property {
+	name = strings.ToUpper(name)
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// Props returns every property with the given name, in document order
+func (c *Component) Props(name string) []*Property {
+	name = strings.ToUpper(name)
+
+	var props []*Property
+	for _, p := range c.Properties {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+
+	return props
+}
+
+// Children returns the direct sub-components with the given name
+func (c *Component) Children(name string) []*Component {
+	name = strings.ToUpper(name)
+
+	var children []*Component
+	for _, child := range c.Components {
+		if child.Name == name {
+			children = append(children, child)
+		}
+	}
+
+	return children
+}
+
+// Parse reads an iCalendar stream into its component tree and returns the
+// top level components, normally a single VCALENDAR.
+//
+// Parsing is lenient so one bad entry doesn't lose a whole feed: lines that
+// can't be lexed are collected as warnings and skipped, an END that doesn't
+// match the open component closes up to the matching BEGIN, a BEGIN for a
+// component that is still open closes the earlier one, and components left
+// open at the end of input are closed.
+func Parse(content string) ([]*Component, []error) {
+	var roots []*Component
+	var stack []*Component
+	var warnings []error
+
+	for n, line := range unfoldLines(content) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		p, err := parseContentLine(line)
+		if err != nil {
+			warnings = append(warnings, fmt.Errorf("line %d: %v", n+1, err))
+			continue
+		}
+
+		switch p.Name {
+		case "BEGIN":
+			c := &Component{Name: strings.ToUpper(strings.TrimSpace(p.Value))}
+
+			// components never nest inside one of their own kind, so a
+			// second BEGIN:VEVENT means the previous one lost its END
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].Name == c.Name {
+					warnings = append(warnings, fmt.Errorf("line %d: BEGIN:%v before END of previous %v", n+1, c.Name, c.Name))
+					stack = stack[:i]
+					break
+				}
+			}
+
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, c)
+			} else {
+				roots = append(roots, c)
+			}
+			stack = append(stack, c)
+
+		case "END":
+			name := strings.ToUpper(strings.TrimSpace(p.Value))
+
+			match := -1
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].Name == name {
+					match = i
+					break
+				}
+			}
+			if match < 0 {
+				warnings = append(warnings, fmt.Errorf("line %d: END:%v without matching BEGIN", n+1, name))
+				continue
+			}
+			if match != len(stack)-1 {
+				warnings = append(warnings, fmt.Errorf("line %d: END:%v closes unterminated %v", n+1, name, stack[len(stack)-1].Name))
+			}
+			stack = stack[:match]
+
+		default:
+			if len(stack) == 0 {
+				warnings = append(warnings, fmt.Errorf("line %d: property %v outside of any component", n+1, p.Name))
+				continue
+			}
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, p)
+		}
+	}
+
+	for _, c := range stack {
+		warnings = append(warnings, fmt.Errorf("%v was never closed", c.Name))
+	}
+
+	return roots, warnings
+}
+
+// Walk calls fn for every component in the tree rooted at c, depth first
+func (c *Component) Walk(fn func(*Component)) {
+	fn(c)
+	for _, child := range c.Components {
+		child.Walk(fn)
+	}
+}
@@ -0,0 +1,147 @@
+package ical
+
+import (
+	"os"
+	"testing"
+)
+
+func readTestdata(t *testing.T, name string) string {
+	t.Helper()
+
+	b, err := os.ReadFile("../../testdata/" + name)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+
+	return string(b)
+}
+
+func parseCalendar(t *testing.T, content string) (*Component, []error) {
+	t.Helper()
+
+	roots, warnings := Parse(content)
+	if len(roots) != 1 || roots[0].Name != "VCALENDAR" {
+		t.Fatalf("expected a single VCALENDAR, got %d roots", len(roots))
+	}
+
+	return roots[0], warnings
+}
+
+func TestParseLFOnly(t *testing.T) {
+	cal, warnings := parseCalendar(t, readTestdata(t, "malformed_lf_only.ics"))
+	if len(warnings) > 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+
+	events := cal.Children("VEVENT")
+	if len(events) != 1 {
+		t.Fatalf("expected 1 VEVENT, got %d", len(events))
+	}
+
+	want := "This feed uses bare LF line endings and a folded description line"
+	if got := events[0].Prop("DESCRIPTION").Text(); got != want {
+		t.Errorf("DESCRIPTION = %q, want %q", got, want)
+	}
+	if got := events[0].Prop("DTSTART").Param("TZID"); got != "America/Chicago" {
+		t.Errorf("DTSTART TZID = %q, want America/Chicago", got)
+	}
+}
+
+func TestParseUnterminated(t *testing.T) {
+	cal, warnings := parseCalendar(t, readTestdata(t, "malformed_unterminated.ics"))
+
+	// the line without a colon and the VEVENT missing its END
+	if len(warnings) != 2 {
+		t.Errorf("expected 2 warnings, got %v", warnings)
+	}
+
+	events := cal.Children("VEVENT")
+	if len(events) != 2 {
+		t.Fatalf("expected 2 VEVENTs, got %d", len(events))
+	}
+
+	for i, uid := range []string{"unterminated-1234@example.com", "after-unterminated-5678@example.com"} {
+		if got := events[i].Prop("UID").Value; got != uid {
+			t.Errorf("VEVENT %d UID = %q, want %q", i, got, uid)
+		}
+	}
+	if got := len(events[0].Properties); got != 4 {
+		t.Errorf("first VEVENT has %d properties, want 4", got)
+	}
+}
+
+func TestParseUnclosedAtEOF(t *testing.T) {
+	roots, warnings := Parse("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\n")
+	if len(warnings) != 2 {
+		t.Errorf("expected a warning for each unclosed component, got %v", warnings)
+	}
+	if len(roots) != 1 || len(roots[0].Children("VEVENT")) != 1 {
+		t.Fatalf("expected the VEVENT to be kept, got %+v", roots)
+	}
+}
+
+func TestParseMismatchedEnd(t *testing.T) {
+	content := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nBEGIN:VALARM\r\nACTION:DISPLAY\r\nEND:VEVENT\r\nBEGIN:VEVENT\r\nUID:2\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	cal, warnings := parseCalendar(t, content)
+	if len(warnings) != 1 {
+		t.Errorf("expected 1 warning, got %v", warnings)
+	}
+
+	events := cal.Children("VEVENT")
+	if len(events) != 2 {
+		t.Fatalf("expected 2 VEVENTs, got %d", len(events))
+	}
+	if got := len(events[0].Children("VALARM")); got != 1 {
+		t.Errorf("expected the VALARM to stay nested, got %d", got)
+	}
+}
+
+func TestParseNestedValarm(t *testing.T) {
+	cal, warnings := parseCalendar(t, readTestdata(t, "nested_valarm_escaped.ics"))
+	if len(warnings) > 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+
+	events := cal.Children("VEVENT")
+	if len(events) != 1 {
+		t.Fatalf("expected 1 VEVENT, got %d", len(events))
+	}
+	vevent := events[0]
+
+	if got := len(vevent.Props("DESCRIPTION")); got != 1 {
+		t.Errorf("VEVENT has %d DESCRIPTION properties, want 1", got)
+	}
+
+	tests := []struct {
+		prop string
+		want string
+	}{
+		{"SUMMARY", "Coffee, Bikes; and Transit"},
+		{"DESCRIPTION", "First line\nSecond line with a backslash \\ and, commas"},
+		{"LOCATION", "Main St, Dallas, TX"},
+	}
+	for _, tt := range tests {
+		if got := vevent.Prop(tt.prop).Text(); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.prop, got, tt.want)
+		}
+	}
+
+	if got := vevent.Prop("LOCATION").Param("altrep"); got != "http://example.com/venue;a=b,c" {
+		t.Errorf("LOCATION ALTREP = %q", got)
+	}
+
+	alarms := vevent.Children("VALARM")
+	if len(alarms) != 1 {
+		t.Fatalf("expected 1 VALARM, got %d", len(alarms))
+	}
+	if got := alarms[0].Prop("DESCRIPTION").Text(); got != "Reminder that must not replace the event description" {
+		t.Errorf("VALARM DESCRIPTION = %q", got)
+	}
+
+	walked := []string{}
+	cal.Walk(func(c *Component) { walked = append(walked, c.Name) })
+	if len(walked) != 3 || walked[0] != "VCALENDAR" || walked[1] != "VEVENT" || walked[2] != "VALARM" {
+		t.Errorf("Walk visited %v", walked)
+	}
+}
@@ -0,0 +1,87 @@
+package ical
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Formats of the normalized DATE and DATE-TIME values stored for
+// RECURRENCE-ID, EXDATE and RDATE: an instant always in UTC, or a bare date
+const (
+	DateTimeFormat = "20060102T150405Z"
+	DateFormat     = "20060102"
+)
+
+// FormatDateTime formats an instant as a UTC DATE-TIME
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format(DateTimeFormat)
+}
+
+// FormatDate formats the day t falls on in loc as a DATE
+func FormatDate(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(DateFormat)
+}
+
+// IsDate reports whether a value is a DATE rather than a DATE-TIME
+func IsDate(value string) bool {
+	return len(value) == len(DateFormat) && !strings.ContainsAny(value, "Tt")
+}
+
+// ParseDateTime parses a single DATE or DATE-TIME value, reading floating
+// values in loc
+func ParseDateTime(value string, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(strings.ToUpper(value), "Z") {
+		return time.Parse(DateTimeFormat, strings.ToUpper(value))
+	}
+
+	for _, format := range []string{"20060102T150405", DateFormat} {
+		if t, err := time.ParseInLocation(format, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// ParseDateList parses a comma separated EXDATE, RDATE or RECURRENCE-ID
+// value. UTC values keep their instant; floating DATE-TIMEs and DATEs are
+// read in loc. An RDATE PERIOD contributes its start.
+func ParseDateList(value string, loc *time.Location) ([]time.Time, error) {
+	var out []time.Time
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if start, _, ok := strings.Cut(v, "/"); ok {
+			v = start
+		}
+
+		t, err := ParseDateTime(v, loc)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+
+	return out, nil
+}
+
+// NormalizeDate rewrites a DATE or DATE-TIME value in its stored form,
+// reading floating values in loc. DATEs stay DATEs.
+func NormalizeDate(value string, loc *time.Location) (string, error) {
+	if IsDate(value) {
+		if _, err := time.Parse(DateFormat, value); err != nil {
+			return "", fmt.Errorf("invalid date %q", value)
+		}
+		return value, nil
+	}
+
+	t, err := ParseDateTime(value, loc)
+	if err != nil {
+		return "", err
+	}
+
+	return FormatDateTime(t), nil
+}
@@ -0,0 +1,71 @@
+package ical
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeDate(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"UTC is kept", "20250310T230000Z", "20250310T230000Z", false},
+		{"lower case UTC", "20250310t230000z", "20250310T230000Z", false},
+		{"floating is read in loc", "20250310T180000", "20250310T230000Z", false},
+		{"floating across DST", "20250307T180000", "20250308T000000Z", false},
+		{"DATE stays a DATE", "20250310", "20250310", false},
+		{"invalid DATE", "20251340", "", true},
+		{"garbage", "next tuesday", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeDate(tt.value, chicago)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeDate(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeDate(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDateList(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ParseDateList("20250310T230000Z, 20250310T180000,20250311,20250312T230000Z/PT1H,", chicago)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []time.Time{
+		time.Date(2025, 3, 10, 23, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 10, 18, 0, 0, 0, chicago),
+		time.Date(2025, 3, 11, 0, 0, 0, 0, chicago),
+		time.Date(2025, 3, 12, 23, 0, 0, 0, time.UTC),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("value %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if _, err := ParseDateList("20250310T230000Z,bogus", chicago); err == nil {
+		t.Error("ParseDateList() with an invalid value succeeded")
+	}
+}
@@ -0,0 +1,90 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a DURATION value. Weeks and days are nominal, so adding one
+// day across a DST change keeps the wall clock time rather than adding 24
+// hours.
+type Duration struct {
+	Negative bool
+	Weeks    int
+	Days     int
+	Clock    time.Duration
+}
+
+// Add returns t shifted by the duration
+func (d Duration) Add(t time.Time) time.Time {
+	sign := 1
+	if d.Negative {
+		sign = -1
+	}
+
+	return t.AddDate(0, 0, sign*(d.Weeks*7+d.Days)).Add(time.Duration(sign) * d.Clock)
+}
+
+// ParseDuration parses a DURATION value such as PT1H30M, P1D or -P2W
+func ParseDuration(value string) (Duration, error) {
+	var d Duration
+
+	v := strings.ToUpper(strings.TrimSpace(value))
+	switch {
+	case strings.HasPrefix(v, "-"):
+		d.Negative = true
+		v = v[1:]
+	case strings.HasPrefix(v, "+"):
+		v = v[1:]
+	}
+
+	if !strings.HasPrefix(v, "P") || len(v) < 3 {
+		return Duration{}, fmt.Errorf("invalid duration %q", value)
+	}
+	v = v[1:]
+
+	inTime := false
+	num := ""
+	for _, c := range v {
+		switch {
+		case c >= '0' && c <= '9':
+			num += string(c)
+			continue
+		case c == 'T':
+			if inTime || num != "" {
+				return Duration{}, fmt.Errorf("invalid duration %q", value)
+			}
+			inTime = true
+			continue
+		}
+
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return Duration{}, fmt.Errorf("invalid duration %q", value)
+		}
+		num = ""
+
+		switch {
+		case c == 'W' && !inTime:
+			d.Weeks = n
+		case c == 'D' && !inTime:
+			d.Days = n
+		case c == 'H' && inTime:
+			d.Clock += time.Duration(n) * time.Hour
+		case c == 'M' && inTime:
+			d.Clock += time.Duration(n) * time.Minute
+		case c == 'S' && inTime:
+			d.Clock += time.Duration(n) * time.Second
+		default:
+			return Duration{}, fmt.Errorf("invalid duration %q", value)
+		}
+	}
+
+	if num != "" {
+		return Duration{}, fmt.Errorf("invalid duration %q", value)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,77 @@
+package ical
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   Duration
+		hasErr bool
+	}{
+		{value: "PT2H30M", want: Duration{Clock: 2*time.Hour + 30*time.Minute}},
+		{value: "P1DT1H", want: Duration{Days: 1, Clock: time.Hour}},
+		{value: "P2W", want: Duration{Weeks: 2}},
+		{value: "-PT15M", want: Duration{Negative: true, Clock: 15 * time.Minute}},
+		{value: "+P1D", want: Duration{Days: 1}},
+		{value: "pt45s", want: Duration{Clock: 45 * time.Second}},
+		{value: "P", hasErr: true},
+		{value: "PT", hasErr: true},
+		{value: "1H", hasErr: true},
+		{value: "P1H", hasErr: true},
+		{value: "PT1D", hasErr: true},
+		{value: "PT1H30", hasErr: true},
+		{value: "PTH", hasErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.value)
+		if tt.hasErr {
+			if err == nil {
+				t.Errorf("ParseDuration(%q) = %+v, expected an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDuration(%q): unexpected error: %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDurationAdd(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the day before the spring DST change
+	start := time.Date(2025, 3, 8, 9, 0, 0, 0, chicago)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		// days are nominal and keep the wall clock time
+		{"P1DT1H", time.Date(2025, 3, 9, 10, 0, 0, 0, chicago)},
+		// hours are exact
+		{"PT24H", time.Date(2025, 3, 9, 10, 0, 0, 0, chicago)},
+		{"P1W", time.Date(2025, 3, 15, 9, 0, 0, 0, chicago)},
+		{"-P1D", time.Date(2025, 3, 7, 9, 0, 0, 0, chicago)},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseDuration(tt.value)
+		if err != nil {
+			t.Fatalf("ParseDuration(%q): %v", tt.value, err)
+		}
+		if got := d.Add(start); !got.Equal(tt.want) {
+			t.Errorf("%s after %v = %v, want %v", tt.value, start, got, tt.want)
+		}
+	}
+}
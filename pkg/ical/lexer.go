@@ -0,0 +1,170 @@
+package ical
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Property is a single content line, e.g.
+// DTSTART;TZID="America/Chicago":20250101T120000
+type Property struct {
+	Name   string
+	Params map[string][]string
+	Value  string
+}
+
+// Param returns the first value of the named parameter, or "" if it is not
+// present
+func (p *Property) Param(name string) string {
+	values := p.Params[strings.ToUpper(name)]
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// Text returns the value with TEXT escapes (\, \; \\ \n) resolved
+func (p *Property) Text() string {
+	return UnescapeText(p.Value)
+}
+
+// unfoldLines splits content into logical content lines. CRLF, bare LF and
+// bare CR line endings are all accepted, and lines beginning with a space or
+// tab are joined onto the line before them.
+func unfoldLines(content string) []string {
+	content = strings.TrimPrefix(content, "\ufeff")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			if len(lines) > 0 {
+				lines[len(lines)-1] += line[1:]
+			}
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// parseContentLine lexes a single unfolded content line into a Property.
+// Parameter values may be quoted, in which case ':' ';' and ',' inside the
+// quotes are literal.
+func parseContentLine(line string) (*Property, error) {
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return nil, fmt.Errorf("malformed content line %q", line)
+	}
+
+	p := &Property{
+		Name:   strings.ToUpper(strings.TrimSpace(line[:i])),
+		Params: map[string][]string{},
+	}
+
+	for line[i] == ';' {
+		i++
+
+		eq := strings.IndexByte(line[i:], '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("malformed parameter in %q", line)
+		}
+		name := strings.ToUpper(strings.TrimSpace(line[i : i+eq]))
+		i += eq + 1
+
+		for {
+			var value string
+			if i < len(line) && line[i] == '"' {
+				end := strings.IndexByte(line[i+1:], '"')
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated quoted parameter in %q", line)
+				}
+				value = line[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				end := strings.IndexAny(line[i:], ",;:")
+				if end < 0 {
+					return nil, fmt.Errorf("missing value separator in %q", line)
+				}
+				value = line[i : i+end]
+				i += end
+			}
+
+			p.Params[name] = append(p.Params[name], unescapeParam(value))
+
+			if i >= len(line) {
+				return nil, fmt.Errorf("missing value separator in %q", line)
+			}
+			if line[i] != ',' {
+				break
+			}
+			i++
+		}
+
+		if line[i] != ';' && line[i] != ':' {
+			return nil, fmt.Errorf("unexpected %q after parameter in %q", line[i], line)
+		}
+	}
+
+	p.Value = line[i+1:]
+
+	return p, nil
+}
+
+// unescapeParam resolves the RFC 6868 caret encoding used in parameter values
+func unescapeParam(value string) string {
+	if !strings.Contains(value, "^") {
+		return value
+	}
+
+	return strings.NewReplacer("^n", "\n", "^N", "\n", "^'", "\"", "^^", "^").Replace(value)
+}
+
+// UnescapeText resolves the backslash escapes allowed in TEXT values
+func UnescapeText(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' || i+1 == len(value) {
+			b.WriteByte(c)
+			continue
+		}
+
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		case ',', ';', '\\', ':', '"':
+			b.WriteByte(value[i])
+		default:
+			// not a valid escape, keep it as written
+			b.WriteByte('\\')
+			b.WriteByte(value[i])
+		}
+	}
+
+	return b.String()
+}
+
+// EscapeText is the inverse of UnescapeText, producing a value safe to write
+// as a TEXT property
+func EscapeText(value string) string {
+	return textEscaper.Replace(value)
+}
+
+var textEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	";", "\\;",
+	",", "\\,",
+	"\r\n", "\\n",
+	"\n", "\\n",
+	"\r", "\\n",
+)
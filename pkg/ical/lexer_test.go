@@ -0,0 +1,146 @@
+package ical
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseContentLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   *Property
+		hasErr bool
+	}{
+		{
+			name: "plain value",
+			line: "SUMMARY:Bike Ride",
+			want: &Property{Name: "SUMMARY", Params: map[string][]string{}, Value: "Bike Ride"},
+		},
+		{
+			name: "lowercase name and parameter",
+			line: "dtstart;tzid=America/Chicago:20250301T180000",
+			want: &Property{
+				Name:   "DTSTART",
+				Params: map[string][]string{"TZID": {"America/Chicago"}},
+				Value:  "20250301T180000",
+			},
+		},
+		{
+			name: "quoted parameter with separators",
+			line: `LOCATION;ALTREP="http://example.com/venue;a=b,c":Main St\, Dallas`,
+			want: &Property{
+				Name:   "LOCATION",
+				Params: map[string][]string{"ALTREP": {"http://example.com/venue;a=b,c"}},
+				Value:  `Main St\, Dallas`,
+			},
+		},
+		{
+			name: "multiple parameters and values",
+			line: `ATTENDEE;ROLE=REQ-PARTICIPANT;DELEGATED-FROM="mailto:a@example.com","mailto:b@example.com":mailto:c@example.com`,
+			want: &Property{
+				Name: "ATTENDEE",
+				Params: map[string][]string{
+					"ROLE":           {"REQ-PARTICIPANT"},
+					"DELEGATED-FROM": {"mailto:a@example.com", "mailto:b@example.com"},
+				},
+				Value: "mailto:c@example.com",
+			},
+		},
+		{
+			name: "colon in value",
+			line: "URL:https://example.com/events?id=1",
+			want: &Property{Name: "URL", Params: map[string][]string{}, Value: "https://example.com/events?id=1"},
+		},
+		{
+			name: "caret encoded parameter",
+			line: `LOCATION;X-NOTE="Say ^'hi^'^nthere":Park`,
+			want: &Property{
+				Name:   "LOCATION",
+				Params: map[string][]string{"X-NOTE": {"Say \"hi\"\nthere"}},
+				Value:  "Park",
+			},
+		},
+		{name: "no colon", line: "this line has no colon", hasErr: true},
+		{name: "unterminated quote", line: `LOCATION;ALTREP="http://example.com:Main St`, hasErr: true},
+		{name: "parameter without value", line: "DTSTART;VALUE:20250101", hasErr: true},
+		{name: "empty name", line: ":value", hasErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseContentLine(tt.line)
+			if tt.hasErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnfoldLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "CRLF with folding",
+			content: "DESCRIPTION:This is\r\n  folded\r\n\tand folded again\r\nEND:VEVENT\r\n",
+			want:    []string{"DESCRIPTION:This is foldedand folded again", "END:VEVENT", ""},
+		},
+		{
+			name:    "LF only",
+			content: "SUMMARY:One\n two\nUID:1",
+			want:    []string{"SUMMARY:Onetwo", "UID:1"},
+		},
+		{
+			name:    "CR only",
+			content: "SUMMARY:One\rUID:1",
+			want:    []string{"SUMMARY:One", "UID:1"},
+		},
+		{
+			name:    "byte order mark",
+			content: "\ufeffBEGIN:VCALENDAR\r\nEND:VCALENDAR",
+			want:    []string{"BEGIN:VCALENDAR", "END:VCALENDAR"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unfoldLines(tt.content)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnescapeText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{`Coffee\, Bikes\; and Transit`, "Coffee, Bikes; and Transit"},
+		{`First line\nSecond line\NThird`, "First line\nSecond line\nThird"},
+		{`a backslash \\ and\, commas`, `a backslash \ and, commas`},
+		{`\\n is not a newline`, `\n is not a newline`},
+		{`unknown \x escape`, `unknown \x escape`},
+		{`trailing \`, `trailing \`},
+		{"nothing to do", "nothing to do"},
+	}
+
+	for _, tt := range tests {
+		if got := UnescapeText(tt.value); got != tt.want {
+			t.Errorf("UnescapeText(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequencies supported by RRule
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// maxPeriods stops expansion of a rule whose filters never match, such as
+// BYMONTHDAY=30 with BYMONTH=2, when nothing else ends it
+const maxPeriods = 10000
+
+// RRule is a parsed recurrence rule (RFC 5545 3.3.10). Sub-daily
+// frequencies and the BYHOUR, BYMINUTE, BYSECOND, BYYEARDAY and BYWEEKNO
+// parts are not supported.
+type RRule struct {
+	Freq     string
+	Interval int
+	Count    int
+
+	// Until is the last instant an occurrence may start at. A DATE or
+	// floating UNTIL is resolved against DTSTART's location by Expand.
+	Until      *time.Time
+	untilValue string
+
+	ByMonth    []time.Month
+	ByMonthDay []int
+	ByDay      []WeekdayNum
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+// WeekdayNum is a BYDAY entry such as MO, 2TU or -1FR. N is 0 for every
+// such weekday in the period.
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// ParseRRule parses an RRULE value such as FREQ=WEEKLY;BYDAY=TU,TH
+func ParseRRule(value string) (*RRule, error) {
+	r := &RRule{Interval: 1, WeekStart: time.Monday}
+
+	for _, part := range strings.Split(strings.TrimSpace(value), ";") {
+		if part == "" {
+			continue
+		}
+
+		name, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid RRULE part %q", part)
+		}
+		name = strings.ToUpper(name)
+		v = strings.ToUpper(v)
+
+		var err error
+		switch name {
+		case "FREQ":
+			switch v {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				r.Freq = v
+			default:
+				return nil, fmt.Errorf("unsupported RRULE frequency %q", v)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(v)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(v)
+		case "UNTIL":
+			r.untilValue = v
+			if strings.HasSuffix(v, "Z") {
+				var t time.Time
+				if t, err = time.Parse("20060102T150405Z", v); err == nil {
+					r.Until = &t
+				}
+			}
+		case "BYMONTH":
+			var months []int
+			if months, err = parseInts(v, 1, 12, false); err == nil {
+				for _, m := range months {
+					r.ByMonth = append(r.ByMonth, time.Month(m))
+				}
+			}
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(v, 1, 31, true)
+		case "BYSETPOS":
+			r.BySetPos, err = parseInts(v, 1, 366, true)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(v)
+		case "WKST":
+			wd, ok := weekdays[v]
+			if !ok {
+				err = fmt.Errorf("unknown weekday")
+			}
+			r.WeekStart = wd
+		case "BYHOUR", "BYMINUTE", "BYSECOND", "BYYEARDAY", "BYWEEKNO":
+			return nil, fmt.Errorf("unsupported RRULE part %v", name)
+		default:
+			// unknown extensions are ignored
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRULE %v %q: %v", name, v, err)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("RRULE %q has no FREQ", value)
+	}
+
+	return r, nil
+}
+
+func parseInts(value string, min, max int, allowNegative bool) ([]int, error) {
+	var out []int
+	for _, s := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(s, "+"))
+		if err != nil {
+			return nil, err
+		}
+
+		abs := n
+		if abs < 0 && allowNegative {
+			abs = -abs
+		}
+		if abs < min || abs > max {
+			return nil, fmt.Errorf("%d out of range", n)
+		}
+		out = append(out, n)
+	}
+
+	return out, nil
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var out []WeekdayNum
+	for _, s := range strings.Split(value, ",") {
+		if len(s) < 2 {
+			return nil, fmt.Errorf("invalid weekday %q", s)
+		}
+
+		wd, ok := weekdays[s[len(s)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", s)
+		}
+
+		n := 0
+		if prefix := s[:len(s)-2]; prefix != "" {
+			var err error
+			if n, err = strconv.Atoi(strings.TrimPrefix(prefix, "+")); err != nil || n == 0 || n > 53 || n < -53 {
+				return nil, fmt.Errorf("invalid weekday %q", s)
+			}
+		}
+
+		out = append(out, WeekdayNum{N: n, Weekday: wd})
+	}
+
+	return out, nil
+}
+
+// Expand returns the start of every occurrence of the rule beginning at
+// dtstart, in order, that starts before to and not before from. DTSTART is
+// always the first occurrence and counts toward COUNT. Occurrences keep
+// dtstart's wall clock time in its location across DST changes.
+func (r *RRule) Expand(dtstart, from, to time.Time) []time.Time {
+	until := r.until(dtstart)
+	out := []time.Time{}
+	count := 0
+
+	emit := func(t time.Time) bool {
+		if until != nil && t.After(*until) {
+			return false
+		}
+		if !t.Before(to) {
+			return false
+		}
+
+		count++
+		if !t.Before(from) {
+			out = append(out, t)
+		}
+
+		return r.Count == 0 || count < r.Count
+	}
+
+	if !emit(dtstart) {
+		return out
+	}
+
+	// without COUNT the periods before the window can't matter, so start
+	// at the first one that may overlap it. With COUNT every earlier
+	// occurrence has to be counted.
+	start := 0
+	if r.Count == 0 {
+		start = r.periodsBefore(dtstart, from)
+	}
+
+	// no period starting after the window, or after UNTIL, has anything
+	// to emit
+	last := calendarDay(to.In(dtstart.Location()))
+	if until != nil {
+		if u := calendarDay(until.In(dtstart.Location())); u.Before(last) {
+			last = u
+		}
+	}
+
+	for period := start; period < start+maxPeriods; period++ {
+		first, _ := r.periodBounds(dtstart, period)
+		if first.After(last) {
+			return out
+		}
+
+		for _, t := range r.periodOccurrences(dtstart, period) {
+			if !t.After(dtstart) {
+				continue
+			}
+			if !emit(t) {
+				return out
+			}
+		}
+	}
+
+	return out
+}
+
+// periodsBefore returns how many whole periods after the one containing
+// dtstart end before from's day, less one to allow for the time of day
+func (r *RRule) periodsBefore(dtstart, from time.Time) int {
+	if !from.After(dtstart) {
+		return 0
+	}
+
+	y, m, _ := dtstart.Date()
+	day := calendarDay(dtstart)
+	target := calendarDay(from.In(dtstart.Location()))
+
+	var n int
+	switch r.Freq {
+	case FreqDaily:
+		n = int(target.Sub(day).Hours()/24) / r.Interval
+	case FreqWeekly:
+		first, _ := r.periodBounds(dtstart, 0)
+		n = int(target.Sub(first).Hours()/24) / 7 / r.Interval
+	case FreqMonthly:
+		n = ((target.Year()-y)*12 + int(target.Month()-m)) / r.Interval
+	case FreqYearly:
+		n = (target.Year() - y) / r.Interval
+	}
+
+	if n <= 1 {
+		return 0
+	}
+
+	return n - 1
+}
+
+// calendarDay returns t's date as a UTC midnight
+func calendarDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// until resolves UNTIL, which is a DATE or floating time in dtstart's
+// location unless written in UTC
+func (r *RRule) until(dtstart time.Time) *time.Time {
+	if r.Until != nil || r.untilValue == "" {
+		return r.Until
+	}
+
+	for _, format := range []string{"20060102T150405", "20060102"} {
+		if t, err := time.ParseInLocation(format, r.untilValue, dtstart.Location()); err == nil {
+			if format == "20060102" {
+				// a DATE includes every occurrence on that day
+				t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+			return &t
+		}
+	}
+
+	return nil
+}
+
+// periodBounds returns the first and last days, as UTC midnights, of the
+// nth period of the rule's frequency after the one containing dtstart
+func (r *RRule) periodBounds(dtstart time.Time, n int) (time.Time, time.Time) {
+	y, m, d := dtstart.Date()
+
+	var first, last time.Time
+	switch r.Freq {
+	case FreqDaily:
+		first = time.Date(y, m, d+n*r.Interval, 0, 0, 0, 0, time.UTC)
+		last = first
+	case FreqWeekly:
+		offset := (int(dtstart.Weekday()) - int(r.WeekStart) + 7) % 7
+		first = time.Date(y, m, d-offset+7*n*r.Interval, 0, 0, 0, 0, time.UTC)
+		last = first.AddDate(0, 0, 6)
+	case FreqMonthly:
+		first = time.Date(y, m+time.Month(n*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+		last = first.AddDate(0, 1, -1)
+	case FreqYearly:
+		first = time.Date(y+n*r.Interval, time.January, 1, 0, 0, 0, 0, time.UTC)
+		last = time.Date(first.Year(), time.December, 31, 0, 0, 0, 0, time.UTC)
+	}
+
+	return first, last
+}
+
+// periodOccurrences returns the sorted occurrences in the nth period of the
+// rule's frequency after the one containing dtstart
+func (r *RRule) periodOccurrences(dtstart time.Time, n int) []time.Time {
+	loc := dtstart.Location()
+	first, last := r.periodBounds(dtstart, n)
+
+	days := []time.Time{}
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if r.matches(day, dtstart) {
+			days = append(days, day)
+		}
+	}
+
+	if len(r.BySetPos) > 0 {
+		days = setPositions(days, r.BySetPos)
+	}
+
+	hour, min, sec := dtstart.Clock()
+	out := make([]time.Time, 0, len(days))
+	for _, day := range days {
+		out = append(out, time.Date(day.Year(), day.Month(), day.Day(), hour, min, sec, dtstart.Nanosecond(), loc))
+	}
+
+	return out
+}
+
+// matches reports whether a day (a UTC midnight standing for a calendar
+// date) satisfies the rule's BYxxx parts, or the parts implied by dtstart
+// when the rule leaves them out
+func (r *RRule) matches(day time.Time, dtstart time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, day.Month()) {
+		return false
+	}
+
+	if len(r.ByMonthDay) > 0 && !matchesMonthDay(r.ByMonthDay, day) {
+		return false
+	}
+
+	if len(r.ByDay) > 0 {
+		// ordinals count within the month, or the year for a YEARLY rule
+		// without BYMONTH
+		inYear := r.Freq == FreqYearly && len(r.ByMonth) == 0
+		if !matchesByDay(r.ByDay, day, inYear) {
+			return false
+		}
+	}
+
+	// expand from DTSTART when the rule doesn't say which days
+	switch r.Freq {
+	case FreqWeekly:
+		if len(r.ByDay) == 0 && day.Weekday() != dtstart.Weekday() {
+			return false
+		}
+	case FreqMonthly:
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && day.Day() != dtstart.Day() {
+			return false
+		}
+	case FreqYearly:
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+			if day.Day() != dtstart.Day() {
+				return false
+			}
+			if len(r.ByMonth) == 0 && day.Month() != dtstart.Month() {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func containsMonth(months []time.Month, m time.Month) bool {
+	for _, month := range months {
+		if month == m {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesMonthDay(monthDays []int, day time.Time) bool {
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, md := range monthDays {
+		if md == day.Day() || (md < 0 && daysInMonth+md+1 == day.Day()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesByDay(byDay []WeekdayNum, day time.Time, inYear bool) bool {
+	// position of this weekday counted from the start and from the end of
+	// its month or year
+	index, length := day.Day(), time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if inYear {
+		index = day.YearDay()
+		length = time.Date(day.Year(), time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	}
+	fromStart := (index-1)/7 + 1
+	fromEnd := -((length-index)/7 + 1)
+
+	for _, wd := range byDay {
+		if wd.Weekday != day.Weekday() {
+			continue
+		}
+		if wd.N == 0 || wd.N == fromStart || wd.N == fromEnd {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setPositions picks the BYSETPOS entries, 1-based and negative from the
+// end, out of one period's days
+func setPositions(days []time.Time, positions []int) []time.Time {
+	picked := map[int]bool{}
+	for _, pos := range positions {
+		i := pos - 1
+		if pos < 0 {
+			i = len(days) + pos
+		}
+		if i >= 0 && i < len(days) {
+			picked[i] = true
+		}
+	}
+
+	indexes := make([]int, 0, len(picked))
+	for i := range picked {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	out := make([]time.Time, 0, len(indexes))
+	for _, i := range indexes {
+		out = append(out, days[i])
+	}
+
+	return out
+}
@@ -0,0 +1,287 @@
+package ical
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRRuleExpand(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	far := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		from    time.Time
+		to      time.Time
+		want    []string
+	}{
+		{
+			name:    "COUNT includes DTSTART",
+			rule:    "FREQ=WEEKLY;COUNT=3",
+			dtstart: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			to:      far,
+			want:    []string{"2025-01-01 12:00", "2025-01-08 12:00", "2025-01-15 12:00"},
+		},
+		{
+			name:    "COUNT includes a DTSTART the rule doesn't match",
+			rule:    "FREQ=WEEKLY;BYDAY=TU;COUNT=3",
+			dtstart: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), // a Wednesday
+			to:      far,
+			want:    []string{"2025-01-01 12:00", "2025-01-07 12:00", "2025-01-14 12:00"},
+		},
+		{
+			name:    "COUNT counts occurrences before from",
+			rule:    "FREQ=DAILY;COUNT=5",
+			dtstart: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			from:    time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC),
+			to:      far,
+			want:    []string{"2025-01-04 12:00", "2025-01-05 12:00"},
+		},
+		{
+			name:    "INTERVAL with BYDAY",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;COUNT=5",
+			dtstart: time.Date(2025, 1, 7, 18, 0, 0, 0, chicago),
+			to:      far,
+			want:    []string{"2025-01-07 18:00", "2025-01-09 18:00", "2025-01-21 18:00", "2025-01-23 18:00", "2025-02-04 18:00"},
+		},
+		{
+			name:    "BYSETPOS last weekday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=4",
+			dtstart: time.Date(2025, 1, 31, 17, 0, 0, 0, chicago),
+			to:      far,
+			want:    []string{"2025-01-31 17:00", "2025-02-28 17:00", "2025-03-31 17:00", "2025-04-30 17:00"},
+		},
+		{
+			name:    "BYSETPOS first and second to last",
+			rule:    "FREQ=MONTHLY;BYDAY=SA,SU;BYSETPOS=1,-2;COUNT=4",
+			dtstart: time.Date(2025, 3, 1, 10, 0, 0, 0, chicago),
+			to:      far,
+			want:    []string{"2025-03-01 10:00", "2025-03-29 10:00", "2025-04-05 10:00", "2025-04-26 10:00"},
+		},
+		{
+			name:    "negative BYDAY",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			dtstart: time.Date(2025, 1, 31, 19, 0, 0, 0, chicago),
+			to:      far,
+			want:    []string{"2025-01-31 19:00", "2025-02-28 19:00", "2025-03-28 19:00"},
+		},
+		{
+			name:    "second to last BYDAY in a yearly rule",
+			rule:    "FREQ=YEARLY;BYMONTH=5;BYDAY=-2MO;COUNT=3",
+			dtstart: time.Date(2025, 5, 19, 9, 0, 0, 0, chicago),
+			to:      far,
+			want:    []string{"2025-05-19 09:00", "2026-05-18 09:00", "2027-05-24 09:00"},
+		},
+		{
+			name:    "nth BYDAY",
+			rule:    "FREQ=MONTHLY;BYDAY=2TU",
+			dtstart: time.Date(2025, 1, 14, 18, 30, 0, 0, chicago),
+			to:      time.Date(2025, 4, 1, 0, 0, 0, 0, chicago),
+			want:    []string{"2025-01-14 18:30", "2025-02-11 18:30", "2025-03-11 18:30"},
+		},
+		{
+			name:    "DATE UNTIL includes the whole day",
+			rule:    "FREQ=DAILY;UNTIL=20250103",
+			dtstart: time.Date(2025, 1, 1, 23, 0, 0, 0, chicago),
+			to:      far,
+			want:    []string{"2025-01-01 23:00", "2025-01-02 23:00", "2025-01-03 23:00"},
+		},
+		{
+			name:    "UTC UNTIL",
+			rule:    "FREQ=DAILY;UNTIL=20250103T050000Z",
+			dtstart: time.Date(2025, 1, 1, 23, 0, 0, 0, chicago),
+			to:      far,
+			want:    []string{"2025-01-01 23:00", "2025-01-02 23:00"},
+		},
+		{
+			name:    "floating UNTIL in DTSTART's zone",
+			rule:    "FREQ=WEEKLY;UNTIL=20250115T180000",
+			dtstart: time.Date(2025, 1, 1, 18, 0, 0, 0, chicago),
+			to:      far,
+			want:    []string{"2025-01-01 18:00", "2025-01-08 18:00", "2025-01-15 18:00"},
+		},
+		{
+			name:    "wall clock kept across spring DST",
+			rule:    "FREQ=WEEKLY;COUNT=3",
+			dtstart: time.Date(2025, 3, 1, 18, 0, 0, 0, chicago),
+			to:      far,
+			want:    []string{"2025-03-01 18:00", "2025-03-08 18:00", "2025-03-15 18:00"},
+		},
+		{
+			name:    "wall clock kept across fall DST",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: time.Date(2025, 11, 1, 9, 0, 0, 0, chicago),
+			to:      far,
+			want:    []string{"2025-11-01 09:00", "2025-11-02 09:00", "2025-11-03 09:00"},
+		},
+		{
+			name:    "BYMONTHDAY skips months without the day",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3",
+			dtstart: time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC),
+			to:      far,
+			want:    []string{"2025-01-31 12:00", "2025-03-31 12:00", "2025-05-31 12:00"},
+		},
+		{
+			name:    "to bounds an unending rule",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			from:    time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+			to:      time.Date(2025, 6, 3, 12, 0, 0, 0, time.UTC),
+			want:    []string{"2025-06-01 12:00", "2025-06-02 12:00"},
+		},
+		{
+			name:    "DAILY from decades before the window",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(1990, 1, 1, 18, 0, 0, 0, chicago),
+			from:    time.Date(2025, 7, 1, 0, 0, 0, 0, chicago),
+			to:      time.Date(2025, 7, 3, 0, 0, 0, 0, chicago),
+			want:    []string{"2025-07-01 18:00", "2025-07-02 18:00"},
+		},
+		{
+			name:    "INTERVAL keeps its phase when periods are skipped",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU",
+			dtstart: time.Date(1990, 1, 2, 18, 0, 0, 0, chicago), // a Tuesday
+			from:    time.Date(2025, 7, 1, 0, 0, 0, 0, chicago),
+			to:      time.Date(2025, 7, 29, 0, 0, 0, 0, chicago),
+			want:    []string{"2025-07-01 18:00", "2025-07-15 18:00"},
+		},
+		{
+			name:    "MONTHLY from decades before the window",
+			rule:    "FREQ=MONTHLY;BYDAY=2TU",
+			dtstart: time.Date(1990, 1, 9, 18, 30, 0, 0, chicago),
+			from:    time.Date(2025, 1, 1, 0, 0, 0, 0, chicago),
+			to:      time.Date(2025, 3, 1, 0, 0, 0, 0, chicago),
+			want:    []string{"2025-01-14 18:30", "2025-02-11 18:30"},
+		},
+		{
+			name:    "COUNT spent decades before the window",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: time.Date(1990, 1, 1, 18, 0, 0, 0, chicago),
+			from:    time.Date(2025, 7, 1, 0, 0, 0, 0, chicago),
+			to:      time.Date(2025, 7, 3, 0, 0, 0, 0, chicago),
+			want:    []string{},
+		},
+		{
+			name:    "occurrence at from is included",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			from:    time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+			to:      time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC),
+			want:    []string{"2025-06-01 12:00"},
+		},
+		{
+			name:    "impossible rule in a window decades after DTSTART",
+			rule:    "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+			dtstart: time.Date(1990, 1, 1, 12, 0, 0, 0, time.UTC),
+			from:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:      time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:    []string{},
+		},
+		{
+			name:    "impossible rule ends",
+			rule:    "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+			dtstart: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			to:      time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:    []string{"2025-01-01 12:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+			}
+
+			got := []string{}
+			for _, occurrence := range r.Expand(tt.dtstart, tt.from, tt.to) {
+				if occurrence.Location() != tt.dtstart.Location() {
+					t.Errorf("%v is not in DTSTART's location", occurrence)
+				}
+				got = append(got, occurrence.Format("2006-01-02 15:04"))
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRRulePeriodsBefore(t *testing.T) {
+	dtstart := time.Date(1990, 1, 3, 18, 0, 0, 0, time.UTC) // a Wednesday
+	from := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		rule string
+		want int
+	}{
+		{"FREQ=DAILY", 12962},
+		{"FREQ=DAILY;INTERVAL=3", 4320},
+		{"FREQ=WEEKLY", 1851},
+		{"FREQ=MONTHLY", 425},
+		{"FREQ=YEARLY", 34},
+		{"FREQ=YEARLY;INTERVAL=2", 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n := r.periodsBefore(dtstart, from)
+			if n != tt.want {
+				t.Errorf("periodsBefore() = %d, want %d", n, tt.want)
+			}
+
+			// the skipped periods all end before from
+			if _, last := r.periodBounds(dtstart, n-1); !last.Before(from) {
+				t.Errorf("skipped period %d ends %v, not before %v", n-1, last, from)
+			}
+		})
+	}
+
+	r, _ := ParseRRule("FREQ=DAILY")
+	if n := r.periodsBefore(dtstart, dtstart.AddDate(0, 0, -1)); n != 0 {
+		t.Errorf("periodsBefore() before DTSTART = %d, want 0", n)
+	}
+}
+
+func TestParseRRule(t *testing.T) {
+	r, err := ParseRRule("FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR,2MO,TU;BYSETPOS=1,-1;WKST=SU")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []WeekdayNum{{-1, time.Friday}, {2, time.Monday}, {0, time.Tuesday}}
+	if !reflect.DeepEqual(r.ByDay, want) {
+		t.Errorf("ByDay = %+v, want %+v", r.ByDay, want)
+	}
+	if r.Freq != FreqMonthly || r.Interval != 2 || r.WeekStart != time.Sunday {
+		t.Errorf("got %+v", r)
+	}
+	if !reflect.DeepEqual(r.BySetPos, []int{1, -1}) {
+		t.Errorf("BySetPos = %v", r.BySetPos)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+	} {
+		if _, err := ParseRRule(invalid); err == nil {
+			t.Errorf("ParseRRule(%q): expected an error", invalid)
+		}
+	}
+}
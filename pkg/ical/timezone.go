@@ -0,0 +1,433 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// Timezone turns wall clock times written against a TZID into instants.
+// It is backed either by an IANA location or, when the TZID can't be mapped
+// to one, by the STANDARD/DAYLIGHT rules of the feed's own VTIMEZONE.
+type Timezone struct {
+	TZID string
+
+	location    *time.Location
+	observances []observance
+}
+
+// Location returns the IANA location backing the timezone, or nil when the
+// timezone is evaluated from VTIMEZONE rules
+func (tz *Timezone) Location() *time.Location {
+	return tz.location
+}
+
+// FromWallClock interprets the date and clock fields of t, ignoring its
+// location, as a local time in tz
+func (tz *Timezone) FromWallClock(t time.Time) time.Time {
+	if tz.location != nil {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), tz.location)
+	}
+
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	name, offset := tz.offsetAt(wall)
+
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.FixedZone(name, offset))
+}
+
+// Timezones resolves the TZIDs used in one calendar
+type Timezones struct {
+	defined map[string]*Component
+	cache   map[string]*Timezone
+}
+
+// NewTimezones collects the VTIMEZONE components of a calendar
+func NewTimezones(calendar *Component) *Timezones {
+	z := &Timezones{
+		defined: map[string]*Component{},
+		cache:   map[string]*Timezone{},
+	}
+
+	if calendar == nil {
+		return z
+	}
+
+	for _, vtimezone := range calendar.Children("VTIMEZONE") {
+		if tzid := vtimezone.Prop("TZID"); tzid != nil {
+			z.defined[tzid.Value] = vtimezone
+		}
+	}
+
+	return z
+}
+
+// Load resolves a TZID. IANA names are used as-is, Windows names are mapped
+// through the CLDR table, and anything else falls back to the rules in the
+// calendar's VTIMEZONE with that TZID.
+func (z *Timezones) Load(tzid string) (*Timezone, error) {
+	if tz, ok := z.cache[tzid]; ok {
+		return tz, nil
+	}
+
+	tz, err := z.load(tzid)
+	if err != nil {
+		return nil, err
+	}
+
+	z.cache[tzid] = tz
+	return tz, nil
+}
+
+func (z *Timezones) load(tzid string) (*Timezone, error) {
+	name := strings.TrimSpace(tzid)
+
+	if loc := loadIANA(name); loc != nil {
+		return &Timezone{TZID: tzid, location: loc}, nil
+	}
+
+	if iana, ok := WindowsZoneToIANA(name); ok {
+		if loc := loadIANA(iana); loc != nil {
+			return &Timezone{TZID: tzid, location: loc}, nil
+		}
+	}
+
+	vtimezone, ok := z.defined[tzid]
+	if !ok {
+		return nil, fmt.Errorf("unknown TZID %q and no VTIMEZONE defines it", tzid)
+	}
+
+	if lic := vtimezone.Prop("X-LIC-LOCATION"); lic != nil {
+		if loc := loadIANA(lic.Value); loc != nil {
+			return &Timezone{TZID: tzid, location: loc}, nil
+		}
+	}
+
+	observances, err := parseObservances(vtimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VTIMEZONE %q: %v", tzid, err)
+	}
+
+	return &Timezone{TZID: tzid, observances: observances}, nil
+}
+
+// loadIANA loads an IANA location, also accepting the vendor prefixed forms
+// some producers write, e.g. /mozilla.org/20050126_1/America/Chicago
+func loadIANA(name string) *time.Location {
+	if name == "" || strings.EqualFold(name, "local") {
+		return nil
+	}
+
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc
+	}
+
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	for i := 1; i < len(parts); i++ {
+		if loc, err := time.LoadLocation(strings.Join(parts[i:], "/")); err == nil {
+			return loc
+		}
+	}
+
+	return nil
+}
+
+// observance is a STANDARD or DAYLIGHT sub-component of a VTIMEZONE
+type observance struct {
+	name       string
+	offsetFrom int
+	offsetTo   int
+
+	// start is the first onset as a wall clock time in offsetFrom
+	start  time.Time
+	rule   *yearlyRule
+	rdates []time.Time
+}
+
+// yearlyRule is the subset of RRULE used by VTIMEZONE observances:
+// FREQ=YEARLY with BYMONTH and a BYDAY such as 2SU, -1SU, or SU combined
+// with BYMONTHDAY
+type yearlyRule struct {
+	month      time.Month
+	weekday    time.Weekday
+	hasWeekday bool
+	nth        int
+	monthDays  []int
+	until      *time.Time
+	untilUTC   bool
+}
+
+func parseObservances(vtimezone *Component) ([]observance, error) {
+	var observances []observance
+
+	for _, c := range vtimezone.Components {
+		if c.Name != "STANDARD" && c.Name != "DAYLIGHT" {
+			continue
+		}
+
+		o := observance{name: c.Name}
+		if tzname := c.Prop("TZNAME"); tzname != nil {
+			o.name = tzname.Value
+		}
+
+		from, to := c.Prop("TZOFFSETFROM"), c.Prop("TZOFFSETTO")
+		if from == nil || to == nil {
+			return nil, fmt.Errorf("%v is missing TZOFFSETFROM or TZOFFSETTO", c.Name)
+		}
+
+		var err error
+		if o.offsetFrom, err = parseUTCOffset(from.Value); err != nil {
+			return nil, err
+		}
+		if o.offsetTo, err = parseUTCOffset(to.Value); err != nil {
+			return nil, err
+		}
+
+		dtstart := c.Prop("DTSTART")
+		if dtstart == nil {
+			return nil, fmt.Errorf("%v is missing DTSTART", c.Name)
+		}
+		if o.start, err = time.Parse("20060102T150405", dtstart.Value); err != nil {
+			return nil, fmt.Errorf("invalid %v DTSTART %q", c.Name, dtstart.Value)
+		}
+
+		if rrule := c.Prop("RRULE"); rrule != nil {
+			if o.rule, err = parseYearlyRule(rrule.Value); err != nil {
+				return nil, err
+			}
+			if u := o.rule.until; u != nil && o.rule.untilUTC {
+				// onsets are wall clock times in the prior offset
+				wall := u.Add(time.Duration(o.offsetFrom) * time.Second)
+				o.rule.until = &wall
+			}
+		}
+
+		for _, rdate := range c.Props("RDATE") {
+			for _, v := range strings.Split(rdate.Value, ",") {
+				if t, err := time.Parse("20060102T150405", v); err == nil {
+					o.rdates = append(o.rdates, t)
+				}
+			}
+		}
+
+		observances = append(observances, o)
+	}
+
+	if len(observances) == 0 {
+		return nil, fmt.Errorf("no STANDARD or DAYLIGHT observances")
+	}
+
+	return observances, nil
+}
+
+// parseUTCOffset parses offsets like -0500 or +053000 into seconds
+func parseUTCOffset(value string) (int, error) {
+	if len(value) != 5 && len(value) != 7 {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+
+	sign := 1
+	switch value[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+
+	hours, err := strconv.Atoi(value[1:3])
+	if err != nil {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+	minutes, err := strconv.Atoi(value[3:5])
+	if err != nil {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+	seconds := 0
+	if len(value) == 7 {
+		if seconds, err = strconv.Atoi(value[5:7]); err != nil {
+			return 0, fmt.Errorf("invalid UTC offset %q", value)
+		}
+	}
+
+	return sign * (hours*3600 + minutes*60 + seconds), nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func parseYearlyRule(value string) (*yearlyRule, error) {
+	r := &yearlyRule{}
+
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key, v := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		switch key {
+		case "FREQ":
+			if v != "YEARLY" {
+				return nil, fmt.Errorf("unsupported VTIMEZONE RRULE frequency %q", v)
+			}
+		case "BYMONTH":
+			month, err := strconv.Atoi(v)
+			if err != nil || month < 1 || month > 12 {
+				return nil, fmt.Errorf("invalid BYMONTH %q", v)
+			}
+			r.month = time.Month(month)
+		case "BYDAY":
+			if len(v) < 2 {
+				return nil, fmt.Errorf("invalid BYDAY %q", v)
+			}
+			wd, ok := weekdays[v[len(v)-2:]]
+			if !ok {
+				return nil, fmt.Errorf("invalid BYDAY %q", v)
+			}
+			r.weekday = wd
+			r.hasWeekday = true
+			if n := v[:len(v)-2]; n != "" {
+				nth, err := strconv.Atoi(strings.TrimPrefix(n, "+"))
+				if err != nil {
+					return nil, fmt.Errorf("invalid BYDAY %q", v)
+				}
+				r.nth = nth
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(v, ",") {
+				day, err := strconv.Atoi(d)
+				if err != nil {
+					return nil, fmt.Errorf("invalid BYMONTHDAY %q", v)
+				}
+				r.monthDays = append(r.monthDays, day)
+			}
+		case "UNTIL":
+			for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+				if t, err := time.Parse(layout, v); err == nil {
+					r.until = &t
+					r.untilUTC = strings.HasSuffix(v, "Z")
+					break
+				}
+			}
+		}
+	}
+
+	if r.month == 0 {
+		return nil, fmt.Errorf("VTIMEZONE RRULE %q has no BYMONTH", value)
+	}
+
+	return r, nil
+}
+
+// onset returns the rule's onset date in the given year, at the time of day
+// of start
+func (r *yearlyRule) onset(year int, start time.Time) (time.Time, bool) {
+	h, m, s := start.Clock()
+
+	var day time.Time
+	switch {
+	case len(r.monthDays) > 0:
+		days := append([]int(nil), r.monthDays...)
+		sort.Ints(days)
+		found := false
+		for _, d := range days {
+			candidate := time.Date(year, r.month, d, h, m, s, 0, time.UTC)
+			// without BYDAY any weekday matches, not only Sunday
+			if candidate.Month() == r.month && (!r.hasWeekday || candidate.Weekday() == r.weekday) {
+				day, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			return time.Time{}, false
+		}
+	case r.nth > 0:
+		first := time.Date(year, r.month, 1, h, m, s, 0, time.UTC)
+		shift := (int(r.weekday) - int(first.Weekday()) + 7) % 7
+		day = first.AddDate(0, 0, shift+7*(r.nth-1))
+		if day.Month() != r.month {
+			return time.Time{}, false
+		}
+	case r.nth < 0:
+		last := time.Date(year, r.month+1, 0, h, m, s, 0, time.UTC)
+		shift := (int(last.Weekday()) - int(r.weekday) + 7) % 7
+		day = last.AddDate(0, 0, -shift+7*(r.nth+1))
+		if day.Month() != r.month {
+			return time.Time{}, false
+		}
+	default:
+		day = time.Date(year, r.month, start.Day(), h, m, s, 0, time.UTC)
+	}
+
+	if day.Before(start) {
+		return time.Time{}, false
+	}
+	if r.until != nil && day.After(*r.until) {
+		return time.Time{}, false
+	}
+
+	return day, true
+}
+
+// offsetAt finds the observance in effect at a wall clock time, which is
+// the one whose latest onset at or before it is most recent
+func (tz *Timezone) offsetAt(wall time.Time) (string, int) {
+	var best *observance
+	var bestOnset time.Time
+
+	consider := func(o *observance, onset time.Time) {
+		if onset.After(wall) {
+			return
+		}
+		if best == nil || onset.After(bestOnset) {
+			best, bestOnset = o, onset
+		}
+	}
+
+	for i := range tz.observances {
+		o := &tz.observances[i]
+
+		consider(o, o.start)
+		for _, rdate := range o.rdates {
+			consider(o, rdate)
+		}
+		if o.rule != nil {
+			for year := wall.Year() - 1; year <= wall.Year(); year++ {
+				if onset, ok := o.rule.onset(year, o.start); ok {
+					consider(o, onset)
+				}
+			}
+			// a rule that ended years ago still set the offset that followed
+			if u := o.rule.until; u != nil && u.Year() < wall.Year()-1 {
+				if onset, ok := o.rule.onset(u.Year(), o.start); ok {
+					consider(o, onset)
+				}
+			}
+		}
+	}
+
+	if best != nil {
+		return best.name, best.offsetTo
+	}
+
+	// before the first onset the zone observes the earliest observance's
+	// prior offset
+	earliest := &tz.observances[0]
+	for i := range tz.observances {
+		if tz.observances[i].start.Before(earliest.start) {
+			earliest = &tz.observances[i]
+		}
+	}
+
+	return earliest.name, earliest.offsetFrom
+}
@@ -0,0 +1,167 @@
+package ical
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimezonesLoad(t *testing.T) {
+	cal, warnings := parseCalendar(t, readTestdata(t, "outlook_timezones.ics"))
+	if len(warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	z := NewTimezones(cal)
+
+	tests := []struct {
+		tzid     string
+		location string
+	}{
+		{"America/Chicago", "America/Chicago"},
+		{"/mozilla.org/20050126_1/America/New_York", "America/New_York"},
+		// defined by a VTIMEZONE too, but the CLDR table wins
+		{"Central Standard Time", "America/Chicago"},
+		{"W. Europe Standard Time", "Europe/Berlin"},
+		{"Tokyo Standard Time", "Asia/Tokyo"},
+		{"Custom Community Time", ""},
+	}
+
+	for _, tt := range tests {
+		tz, err := z.Load(tt.tzid)
+		if err != nil {
+			t.Errorf("Load(%q): unexpected error: %v", tt.tzid, err)
+			continue
+		}
+
+		got := ""
+		if tz.Location() != nil {
+			got = tz.Location().String()
+		}
+		if got != tt.location {
+			t.Errorf("Load(%q) location = %q, want %q", tt.tzid, got, tt.location)
+		}
+	}
+
+	if _, err := z.Load("Nowhere Standard Time"); err == nil {
+		t.Error("expected an error for a TZID that is neither known nor defined")
+	}
+}
+
+func TestTimezoneFromWallClock(t *testing.T) {
+	cal, _ := parseCalendar(t, readTestdata(t, "outlook_timezones.ics"))
+	z := NewTimezones(cal)
+
+	tests := []struct {
+		tzid string
+		wall time.Time
+		want time.Time
+	}{
+		{"Central Standard Time", wallClock(2025, 1, 15, 18, 30), time.Date(2025, 1, 16, 0, 30, 0, 0, time.UTC)},
+		{"Central Standard Time", wallClock(2025, 7, 4, 12, 0), time.Date(2025, 7, 4, 17, 0, 0, 0, time.UTC)},
+		// evaluated from the VTIMEZONE's observances, -0400 in summer and
+		// -0500 in winter
+		{"Custom Community Time", wallClock(2025, 7, 4, 12, 0), time.Date(2025, 7, 4, 16, 0, 0, 0, time.UTC)},
+		{"Custom Community Time", wallClock(2025, 12, 15, 12, 0), time.Date(2025, 12, 15, 17, 0, 0, 0, time.UTC)},
+		// either side of the second Sunday of March and first of November
+		{"Custom Community Time", wallClock(2025, 3, 9, 1, 59), time.Date(2025, 3, 9, 6, 59, 0, 0, time.UTC)},
+		{"Custom Community Time", wallClock(2025, 3, 9, 3, 0), time.Date(2025, 3, 9, 7, 0, 0, 0, time.UTC)},
+		{"Custom Community Time", wallClock(2025, 11, 2, 1, 0), time.Date(2025, 11, 2, 5, 0, 0, 0, time.UTC)},
+		{"Custom Community Time", wallClock(2025, 11, 2, 3, 0), time.Date(2025, 11, 2, 8, 0, 0, 0, time.UTC)},
+		// before the first onset the earliest observance's prior offset
+		{"Custom Community Time", wallClock(1960, 6, 1, 12, 0), time.Date(1960, 6, 1, 17, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		tz, err := z.Load(tt.tzid)
+		if err != nil {
+			t.Fatalf("Load(%q): %v", tt.tzid, err)
+		}
+		if got := tz.FromWallClock(tt.wall); !got.Equal(tt.want) {
+			t.Errorf("%s %v = %v, want %v", tt.tzid, tt.wall.Format("2006-01-02 15:04"), got.UTC(), tt.want)
+		}
+	}
+}
+
+func TestTimezoneObservanceRules(t *testing.T) {
+	content := "BEGIN:VCALENDAR\r\n" +
+		// US rules written with BYMONTHDAY ranges and a weekday, as some
+		// producers do
+		"BEGIN:VTIMEZONE\r\nTZID:Month Day Time\r\n" +
+		"BEGIN:STANDARD\r\nDTSTART:19701101T020000\r\nRRULE:FREQ=YEARLY;BYMONTH=11;BYMONTHDAY=1,2,3,4,5,6,7;BYDAY=SU\r\n" +
+		"TZOFFSETFROM:-0500\r\nTZOFFSETTO:-0600\r\nEND:STANDARD\r\n" +
+		"BEGIN:DAYLIGHT\r\nDTSTART:19700308T020000\r\nRRULE:FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=8,9,10,11,12,13,14;BYDAY=SU\r\n" +
+		"TZOFFSETFROM:-0600\r\nTZOFFSETTO:-0500\r\nEND:DAYLIGHT\r\nEND:VTIMEZONE\r\n" +
+		// fixed dates given by BYMONTHDAY alone, whatever the weekday
+		"BEGIN:VTIMEZONE\r\nTZID:Fixed Date Time\r\n" +
+		"BEGIN:STANDARD\r\nDTSTART:19700915T000000\r\nRRULE:FREQ=YEARLY;BYMONTH=9;BYMONTHDAY=15\r\n" +
+		"TZOFFSETFROM:+0300\r\nTZOFFSETTO:+0200\r\nEND:STANDARD\r\n" +
+		"BEGIN:DAYLIGHT\r\nDTSTART:19700401T000000\r\nRRULE:FREQ=YEARLY;BYMONTH=4;BYMONTHDAY=1\r\n" +
+		"TZOFFSETFROM:+0200\r\nTZOFFSETTO:+0300\r\nEND:DAYLIGHT\r\nEND:VTIMEZONE\r\n" +
+		// daylight saving time that was abolished, with a UTC UNTIL
+		"BEGIN:VTIMEZONE\r\nTZID:Abolished Time\r\n" +
+		"BEGIN:STANDARD\r\nDTSTART:19701025T030000\r\nRRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU;UNTIL=20101031T010000Z\r\n" +
+		"TZOFFSETFROM:+0400\r\nTZOFFSETTO:+0300\r\nEND:STANDARD\r\n" +
+		"BEGIN:DAYLIGHT\r\nDTSTART:19700329T020000\r\nRRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU;UNTIL=20100328T000000Z\r\n" +
+		"TZOFFSETFROM:+0300\r\nTZOFFSETTO:+0400\r\nEND:DAYLIGHT\r\n" +
+		"BEGIN:STANDARD\r\nDTSTART:20110101T000000\r\nTZOFFSETFROM:+0300\r\nTZOFFSETTO:+0500\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n" +
+		"END:VCALENDAR\r\n"
+
+	cal, warnings := parseCalendar(t, content)
+	if len(warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	z := NewTimezones(cal)
+
+	tests := []struct {
+		tzid   string
+		wall   time.Time
+		offset int
+	}{
+		// 2025-03-09 and 2025-11-02 are the second and first Sundays
+		{"Month Day Time", wallClock(2025, 3, 8, 12, 0), -6},
+		{"Month Day Time", wallClock(2025, 3, 9, 3, 0), -5},
+		{"Month Day Time", wallClock(2025, 11, 1, 12, 0), -5},
+		{"Month Day Time", wallClock(2025, 11, 2, 3, 0), -6},
+		// 2025-04-01 is a Tuesday and 2025-09-15 a Monday
+		{"Fixed Date Time", wallClock(2025, 3, 31, 12, 0), 2},
+		{"Fixed Date Time", wallClock(2025, 4, 1, 12, 0), 3},
+		{"Fixed Date Time", wallClock(2025, 9, 14, 12, 0), 3},
+		{"Fixed Date Time", wallClock(2025, 9, 15, 12, 0), 2},
+		{"Abolished Time", wallClock(2009, 7, 1, 12, 0), 4},
+		{"Abolished Time", wallClock(2010, 12, 1, 12, 0), 3},
+		{"Abolished Time", wallClock(2025, 7, 1, 12, 0), 5},
+	}
+
+	for _, tt := range tests {
+		tz, err := z.Load(tt.tzid)
+		if err != nil {
+			t.Fatalf("Load(%q): %v", tt.tzid, err)
+		}
+		if tz.Location() != nil {
+			t.Fatalf("Load(%q) unexpectedly resolved to %v", tt.tzid, tz.Location())
+		}
+
+		_, offset := tz.FromWallClock(tt.wall).Zone()
+		if offset != tt.offset*3600 {
+			t.Errorf("%s %v offset = %d, want %d hours", tt.tzid, tt.wall.Format("2006-01-02 15:04"), offset/3600, tt.offset)
+		}
+	}
+}
+
+func TestTimezoneXLicLocation(t *testing.T) {
+	content := "BEGIN:VCALENDAR\r\nBEGIN:VTIMEZONE\r\nTZID:Chicago Office\r\nX-LIC-LOCATION:America/Chicago\r\n" +
+		"BEGIN:STANDARD\r\nDTSTART:19701101T020000\r\nTZOFFSETFROM:-0500\r\nTZOFFSETTO:-0600\r\nEND:STANDARD\r\n" +
+		"END:VTIMEZONE\r\nEND:VCALENDAR\r\n"
+
+	cal, _ := parseCalendar(t, content)
+	tz, err := NewTimezones(cal).Load("Chicago Office")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tz.Location() == nil || tz.Location().String() != "America/Chicago" {
+		t.Errorf("location = %v, want America/Chicago", tz.Location())
+	}
+}
+
+func wallClock(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
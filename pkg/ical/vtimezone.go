@@ -0,0 +1,234 @@
+package ical
+
+import (
+	"fmt"
+	"time"
+)
+
+// transition is one change of a location's UTC offset
+type transition struct {
+	at         time.Time
+	offsetFrom int
+	offsetTo   int
+	name       string
+	dst        bool
+}
+
+// local returns the wall clock time the transition happens at, read in
+// the offset in force before it, as an observance's DTSTART is written
+func (t transition) local() time.Time {
+	return t.at.In(time.FixedZone("", t.offsetFrom))
+}
+
+// NewVTimezone generates a VTIMEZONE for loc from Go's tzdata, with the
+// offset changes of the years fromYear through toYear. A run of yearly
+// changes falling on the same weekday of the same month is written as one
+// observance with an RRULE; runs lasting through toYear are left open so
+// later years follow the current rules.
+func NewVTimezone(loc *time.Location, fromYear, toYear int) *Component {
+	c := &Component{Name: "VTIMEZONE"}
+	c.Properties = append(c.Properties,
+		&Property{Name: "TZID", Value: loc.String()},
+		&Property{Name: "X-LIC-LOCATION", Value: loc.String()},
+	)
+
+	transitions := findTransitions(loc, fromYear, toYear)
+	if len(transitions) == 0 {
+		// a fixed offset over the whole range
+		start := time.Date(fromYear, time.January, 1, 0, 0, 0, 0, loc)
+		name, offset := start.Zone()
+		c.Components = append(c.Components, observanceComponent("STANDARD",
+			time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC), offset, offset, name, ""))
+		return c
+	}
+
+	for _, run := range yearlyRuns(transitions, toYear) {
+		first, last := run.transitions[0], run.transitions[len(run.transitions)-1]
+
+		kind := "STANDARD"
+		if first.dst {
+			kind = "DAYLIGHT"
+		}
+
+		rule := ""
+		if len(run.transitions) > 1 {
+			rule = fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", int(first.local().Month()), run.ordinal, weekdayCodes[first.local().Weekday()])
+			if !run.open {
+				rule += ";UNTIL=" + FormatDateTime(last.at)
+			}
+		}
+
+		c.Components = append(c.Components, observanceComponent(kind, first.local(), first.offsetFrom, first.offsetTo, first.name, rule))
+	}
+
+	return c
+}
+
+func observanceComponent(kind string, start time.Time, offsetFrom, offsetTo int, name, rule string) *Component {
+	o := &Component{Name: kind}
+	o.Properties = append(o.Properties,
+		&Property{Name: "TZOFFSETFROM", Value: formatUTCOffset(offsetFrom)},
+		&Property{Name: "TZOFFSETTO", Value: formatUTCOffset(offsetTo)},
+		&Property{Name: "TZNAME", Value: EscapeText(name)},
+		&Property{Name: "DTSTART", Value: start.Format("20060102T150405")},
+	)
+	if rule != "" {
+		o.Properties = append(o.Properties, &Property{Name: "RRULE", Value: rule})
+	}
+
+	return o
+}
+
+// findTransitions lists the offset changes of loc from the start of
+// fromYear to the end of toYear
+func findTransitions(loc *time.Location, fromYear, toYear int) []transition {
+	start := time.Date(fromYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(toYear+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	out := []transition{}
+	_, prevOffset := start.In(loc).Zone()
+	for day := start; day.Before(end); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		_, offset := next.In(loc).Zone()
+		if offset == prevOffset {
+			continue
+		}
+
+		// narrow the change down to the second
+		lo, hi := day, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, o := mid.In(loc).Zone(); o == prevOffset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+
+		// offsets change on a whole second, the first one at or before hi
+		hi = hi.Truncate(time.Second)
+
+		at := hi.In(loc)
+		name, _ := at.Zone()
+		out = append(out, transition{at: hi, offsetFrom: prevOffset, offsetTo: offset, name: name, dst: at.IsDST()})
+		prevOffset = offset
+	}
+
+	return out
+}
+
+// run is a series of transitions one year apart that a single yearly
+// RRULE describes
+type run struct {
+	transitions []transition
+	ordinal     int
+	open        bool
+}
+
+// yearlyRuns groups transitions into runs. Transitions only join a run
+// when they change between the same offsets under the same name, a year
+// after the run's last one, at the same clock time on the same nth (or
+// last) weekday of the same month. Runs still going in toYear are open.
+func yearlyRuns(transitions []transition, toYear int) []run {
+	runs := []run{}
+	for _, t := range transitions {
+		joined := false
+		for i := len(runs) - 1; i >= 0 && !joined; i-- {
+			r := &runs[i]
+			last := r.transitions[len(r.transitions)-1]
+			if !sameObservance(last, t) || t.local().Year() != last.local().Year()+1 {
+				continue
+			}
+
+			ordinal := commonOrdinal(r, last, t)
+			if ordinal == 0 {
+				continue
+			}
+
+			r.transitions = append(r.transitions, t)
+			r.ordinal = ordinal
+			joined = true
+		}
+
+		if !joined {
+			n, _ := weekdayOrdinals(t.local())
+			runs = append(runs, run{transitions: []transition{t}, ordinal: n})
+		}
+	}
+
+	for i := range runs {
+		last := runs[i].transitions[len(runs[i].transitions)-1]
+		runs[i].open = last.local().Year() >= toYear
+	}
+
+	return runs
+}
+
+func sameObservance(a, b transition) bool {
+	al, bl := a.local(), b.local()
+	ah, am, as := al.Clock()
+	bh, bm, bs := bl.Clock()
+
+	return a.offsetFrom == b.offsetFrom && a.offsetTo == b.offsetTo && a.name == b.name && a.dst == b.dst &&
+		al.Month() == bl.Month() && al.Weekday() == bl.Weekday() &&
+		ah == bh && am == bm && as == bs
+}
+
+// commonOrdinal returns the BYDAY ordinal that describes both the run so
+// far and t, preferring counting from the start of the month, or 0 if
+// there is none
+func commonOrdinal(r *run, last, t transition) int {
+	n, fromEnd := weekdayOrdinals(t.local())
+
+	if len(r.transitions) == 1 {
+		ln, lFromEnd := weekdayOrdinals(last.local())
+		switch {
+		case ln == n:
+			return n
+		case lFromEnd == fromEnd:
+			return fromEnd
+		}
+		return 0
+	}
+
+	switch r.ordinal {
+	case n, fromEnd:
+		return r.ordinal
+	}
+
+	return 0
+}
+
+// weekdayOrdinals returns which occurrence of its weekday t's date is in
+// its month, counted from the start and (negative) from the end
+func weekdayOrdinals(t time.Time) (int, int) {
+	daysInMonth := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	return (t.Day()-1)/7 + 1, -((daysInMonth-t.Day())/7 + 1)
+}
+
+// weekdayCodes maps weekdays to their RRULE codes
+var weekdayCodes = map[time.Weekday]string{
+	time.Sunday:    "SU",
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+}
+
+// formatUTCOffset formats seconds east of UTC as a UTC-OFFSET, e.g. -0500
+func formatUTCOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+
+	s := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+	if seconds := offset % 60; seconds != 0 {
+		s += fmt.Sprintf("%02d", seconds)
+	}
+
+	return s
+}
@@ -0,0 +1,171 @@
+package ical
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// generatedObservance holds the properties of a STANDARD or DAYLIGHT block
+type generatedObservance struct {
+	kind       string
+	offsetFrom string
+	offsetTo   string
+	name       string
+	dtstart    string
+	rrule      string
+}
+
+func generatedObservances(t *testing.T, zone string, fromYear, toYear int) []generatedObservance {
+	t.Helper()
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewVTimezone(loc, fromYear, toYear)
+	if c.Prop("TZID").Value != zone || c.Prop("X-LIC-LOCATION").Value != zone {
+		t.Errorf("TZID = %q, want %q", c.Prop("TZID").Value, zone)
+	}
+
+	out := []generatedObservance{}
+	for _, o := range c.Components {
+		rrule := ""
+		if p := o.Prop("RRULE"); p != nil {
+			rrule = p.Value
+		}
+		out = append(out, generatedObservance{
+			kind:       o.Name,
+			offsetFrom: o.Prop("TZOFFSETFROM").Value,
+			offsetTo:   o.Prop("TZOFFSETTO").Value,
+			name:       o.Prop("TZNAME").Value,
+			dtstart:    o.Prop("DTSTART").Value,
+			rrule:      rrule,
+		})
+	}
+
+	return out
+}
+
+func TestNewVTimezone(t *testing.T) {
+	tests := []struct {
+		name     string
+		zone     string
+		fromYear int
+		toYear   int
+		want     []generatedObservance
+	}{
+		{
+			name:     "open-ended rules",
+			zone:     "America/Chicago",
+			fromYear: 2020,
+			toYear:   2030,
+			want: []generatedObservance{
+				{"DAYLIGHT", "-0600", "-0500", "CDT", "20200308T020000", "FREQ=YEARLY;BYMONTH=3;BYDAY=2SU"},
+				{"STANDARD", "-0500", "-0600", "CST", "20201101T020000", "FREQ=YEARLY;BYMONTH=11;BYDAY=1SU"},
+			},
+		},
+		{
+			name:     "fixed offset",
+			zone:     "Asia/Kolkata",
+			fromYear: 2020,
+			toYear:   2030,
+			want: []generatedObservance{
+				{"STANDARD", "+0530", "+0530", "IST", "19700101T000000", ""},
+			},
+		},
+		{
+			name:     "rules changed inside the range",
+			zone:     "America/Chicago",
+			fromYear: 2005,
+			toYear:   2010,
+			want: []generatedObservance{
+				{"DAYLIGHT", "-0600", "-0500", "CDT", "20050403T020000", "FREQ=YEARLY;BYMONTH=4;BYDAY=1SU;UNTIL=20060402T080000Z"},
+				{"STANDARD", "-0500", "-0600", "CST", "20051030T020000", "FREQ=YEARLY;BYMONTH=10;BYDAY=5SU;UNTIL=20061029T070000Z"},
+				{"DAYLIGHT", "-0600", "-0500", "CDT", "20070311T020000", "FREQ=YEARLY;BYMONTH=3;BYDAY=2SU"},
+				{"STANDARD", "-0500", "-0600", "CST", "20071104T020000", "FREQ=YEARLY;BYMONTH=11;BYDAY=1SU"},
+			},
+		},
+		{
+			name:     "offset with seconds",
+			zone:     "America/Chicago",
+			fromYear: 1883,
+			toYear:   1884,
+			want: []generatedObservance{
+				{"STANDARD", "-055036", "-0600", "CST", "18831118T120924", ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := generatedObservances(t, tt.zone, tt.fromYear, tt.toYear)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewVTimezoneMatchesTransitions(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the observances' rules reproduce every transition in the range
+	c := NewVTimezone(chicago, 2005, 2010)
+	got := map[time.Time]bool{}
+	for _, o := range c.Components {
+		offsetFrom, err := parseUTCOffset(o.Prop("TZOFFSETFROM").Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dtstart, err := time.ParseInLocation("20060102T150405", o.Prop("DTSTART").Value, time.FixedZone("", offsetFrom))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got[dtstart.UTC()] = true
+		if p := o.Prop("RRULE"); p != nil {
+			r, err := ParseRRule(p.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, start := range r.Expand(dtstart, dtstart, time.Date(2011, 1, 1, 0, 0, 0, 0, time.UTC)) {
+				got[start.UTC()] = true
+			}
+		}
+	}
+
+	want := findTransitions(chicago, 2005, 2010)
+	if len(got) != len(want) {
+		t.Errorf("rules give %d transitions, want %d", len(got), len(want))
+	}
+	for _, tr := range want {
+		if !got[tr.at] {
+			t.Errorf("no observance starts at %v", tr.at)
+		}
+	}
+}
+
+func TestFormatUTCOffset(t *testing.T) {
+	tests := []struct {
+		offset int
+		want   string
+	}{
+		{0, "+0000"},
+		{-6 * 3600, "-0600"},
+		{5*3600 + 30*60, "+0530"},
+		{5*3600 + 45*60, "+0545"},
+		{-(5*3600 + 50*60 + 36), "-055036"},
+		{20, "+000020"},
+	}
+
+	for _, tt := range tests {
+		if got := formatUTCOffset(tt.offset); got != tt.want {
+			t.Errorf("formatUTCOffset(%d) = %q, want %q", tt.offset, got, tt.want)
+		}
+	}
+}
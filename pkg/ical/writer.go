@@ -0,0 +1,160 @@
+package ical
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest a content line may be before it has to be
+// folded, excluding the CRLF (RFC 5545 3.1)
+const maxLineOctets = 75
+
+// Writer serializes content lines, escaping parameter values as needed and
+// folding every line at 75 octets. The first write error is kept and
+// returned by Err; later writes are no-ops.
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Err returns the first error encountered while writing
+func (w *Writer) Err() error {
+	return w.err
+}
+
+func (w *Writer) Begin(name string) {
+	w.line("BEGIN:" + name)
+}
+
+func (w *Writer) End(name string) {
+	w.line("END:" + name)
+}
+
+// Raw writes a property whose value is already in its iCalendar form, such
+// as a DATE-TIME, RRULE or INTEGER
+func (w *Writer) Raw(name string, value string, params ...Param) {
+	w.Property(&Property{Name: name, Params: paramMap(params), Value: value})
+}
+
+// Text writes a TEXT property, escaping the plain text value
+func (w *Writer) Text(name string, value string, params ...Param) {
+	w.Raw(name, EscapeText(value), params...)
+}
+
+// Property writes p as a single, folded content line. p.Value is written
+// as-is.
+func (w *Writer) Property(p *Property) {
+	var b strings.Builder
+	b.WriteString(p.Name)
+
+	names := make([]string, 0, len(p.Params))
+	for name := range p.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		b.WriteByte(';')
+		b.WriteString(name)
+		b.WriteByte('=')
+		for i, v := range p.Params[name] {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(quoteParam(v))
+		}
+	}
+
+	b.WriteByte(':')
+	b.WriteString(p.Value)
+
+	w.line(b.String())
+}
+
+// Component writes c and everything nested in it
+func (w *Writer) Component(c *Component) {
+	w.Begin(c.Name)
+	for _, p := range c.Properties {
+		w.Property(p)
+	}
+	for _, child := range c.Components {
+		w.Component(child)
+	}
+	w.End(c.Name)
+}
+
+// line folds and writes one content line followed by CRLF
+func (w *Writer) line(s string) {
+	if w.err != nil {
+		return
+	}
+
+	_, w.err = io.WriteString(w.w, fold(s)+"\r\n")
+}
+
+// Param is a property parameter for Raw and Text
+type Param struct {
+	Name   string
+	Values []string
+}
+
+func NewParam(name string, values ...string) Param {
+	return Param{Name: name, Values: values}
+}
+
+func paramMap(params []Param) map[string][]string {
+	m := make(map[string][]string, len(params))
+	for _, p := range params {
+		m[strings.ToUpper(p.Name)] = append(m[strings.ToUpper(p.Name)], p.Values...)
+	}
+
+	return m
+}
+
+// quoteParam quotes a parameter value when it contains characters that
+// would otherwise end it, using the RFC 6868 caret encoding for characters
+// that can't appear even inside quotes
+func quoteParam(v string) string {
+	if strings.ContainsAny(v, "^\"\n") {
+		v = strings.NewReplacer("^", "^^", "\"", "^'", "\r\n", "^n", "\n", "^n").Replace(v)
+	}
+
+	if strings.ContainsAny(v, ":;,") {
+		return "\"" + v + "\""
+	}
+
+	return v
+}
+
+// fold splits s into lines of at most 75 octets, continuing each with a
+// single space, without splitting a multi-byte UTF-8 character
+func fold(s string) string {
+	if len(s) <= maxLineOctets {
+		return s
+	}
+
+	var b strings.Builder
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+
+		// continuation lines lose one octet to the leading space
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(s)
+
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	long := strings.Repeat("Bike lanes, bus lanes; and sidewalks. ", 10)
+	multibyte := strings.Repeat("Café ☕ ", 30)
+
+	tests := []struct {
+		name   string
+		value  string
+		params []Param
+	}{
+		{name: "SUMMARY", value: "Coffee, Bikes; and Transit"},
+		{name: "DESCRIPTION", value: "First line\nSecond line with a backslash \\ and\r\nCRLF"},
+		{name: "LOCATION", value: long},
+		{name: "COMMENT", value: multibyte},
+		{name: "X-NOTE", value: "plain", params: []Param{NewParam("ALTREP", "http://example.com/venue;a=b,c")}},
+		{name: "X-CARET", value: "plain", params: []Param{NewParam("X-LABEL", "Say \"hi\"\nthere")}},
+	}
+
+	var b strings.Builder
+	w := NewWriter(&b)
+	w.Begin("VCALENDAR")
+	w.Begin("VEVENT")
+	for _, tt := range tests {
+		w.Text(tt.name, tt.value, tt.params...)
+	}
+	w.End("VEVENT")
+	w.End("VCALENDAR")
+	if err := w.Err(); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+
+	out := b.String()
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line is %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("fold split a character: %q", line)
+		}
+	}
+	if strings.Contains(strings.ReplaceAll(out, "\r\n", ""), "\n") {
+		t.Error("output contains a bare LF")
+	}
+
+	cal, warnings := parseCalendar(t, out)
+	if len(warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	vevent := cal.Children("VEVENT")[0]
+
+	for _, tt := range tests {
+		p := vevent.Prop(tt.name)
+		if p == nil {
+			t.Errorf("%s missing from output", tt.name)
+			continue
+		}
+
+		want := strings.ReplaceAll(tt.value, "\r\n", "\n")
+		if got := p.Text(); got != want {
+			t.Errorf("%s = %q, want %q", tt.name, got, want)
+		}
+		for _, param := range tt.params {
+			if got := p.Param(param.Name); got != param.Values[0] {
+				t.Errorf("%s %s = %q, want %q", tt.name, param.Name, got, param.Values[0])
+			}
+		}
+	}
+}
+
+func TestWriterComponent(t *testing.T) {
+	c := &Component{
+		Name: "VEVENT",
+		Properties: []*Property{
+			{Name: "UID", Params: map[string][]string{}, Value: "1"},
+			{Name: "DTSTART", Params: map[string][]string{"TZID": {"America/Chicago"}}, Value: "20250301T180000"},
+		},
+		Components: []*Component{
+			{Name: "VALARM", Properties: []*Property{{Name: "ACTION", Value: "DISPLAY"}}},
+		},
+	}
+
+	var b strings.Builder
+	w := NewWriter(&b)
+	w.Component(c)
+
+	want := "BEGIN:VEVENT\r\nUID:1\r\nDTSTART;TZID=America/Chicago:20250301T180000\r\nBEGIN:VALARM\r\nACTION:DISPLAY\r\nEND:VALARM\r\nEND:VEVENT\r\n"
+	if got := b.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
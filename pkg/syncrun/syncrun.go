@@ -0,0 +1,56 @@
+package syncrun
+
+import "time"
+
+// Run statuses
+const (
+	StatusRunning      = "running"
+	StatusSucceeded    = "succeeded"
+	StatusUnchanged    = "unchanged"
+	StatusPruneBlocked = "prune_blocked"
+	StatusSkipped      = "skipped"
+	StatusFailed       = "failed"
+)
+
+// SuccessStatuses are the statuses of runs that fetched their feed
+var SuccessStatuses = []string{StatusSucceeded, StatusUnchanged, StatusPruneBlocked}
+
+// Run is one organization's part of a sync. Runs started together by the
+// same events-sync invocation share a RunID.
+type Run struct {
+	ID           string     `json:"id"`
+	RunID        string     `json:"run_id"`
+	Organization string     `json:"organization"`
+	Status       string     `json:"status"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+
+	Found    int `json:"found"`
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Pruned   int `json:"pruned"`
+	Restored int `json:"restored"`
+
+	// HTTPStatus is the status of the last upstream response, if any
+	HTTPStatus *int    `json:"http_status"`
+	Error      *string `json:"error"`
+}
+
+type GetRunsInput struct {
+	Organization *string
+	Limit        int
+}
+
+type GetLatestRunsInput struct {
+	// Statuses limits which runs count; empty means any
+	Statuses []string
+}
+
+type Repository interface {
+	// SaveRun inserts the run or updates it if it was saved before
+	SaveRun(*Run) error
+	// GetRuns returns runs newest first
+	GetRuns(*GetRunsInput) ([]*Run, error)
+	// GetLatestRuns returns the newest matching run of each organization
+	GetLatestRuns(*GetLatestRunsInput) ([]*Run, error)
+}
@@ -8,6 +8,8 @@ function eventManager() {
         statusFilter: '',
         hideSingleEvents: false,
         version: '',
+        sources: [],
+        syncJobs: {},
 
         async loadEvents() {
             this.loading = true;
@@ -60,6 +62,84 @@ function eventManager() {
             }
         },
 
+        async loadSources() {
+            try {
+                const response = await fetch('/api/sync-runs?limit=1');
+                if (!response.ok) {
+                    throw new Error(`HTTP error! status: ${response.status}`);
+                }
+                const data = await response.json();
+                this.sources = data.organizations;
+            } catch (error) {
+                console.error('Error loading sync runs:', error);
+            }
+        },
+
+        async syncOrganization(name) {
+            try {
+                const response = await fetch(`/api/organizations/${encodeURIComponent(name)}/sync`, {
+                    method: 'POST'
+                });
+                if (!response.ok) {
+                    throw new Error(`HTTP error! status: ${response.status}`);
+                }
+                const job = await response.json();
+                this.syncJobs[name] = job;
+                this.pollSyncJob(name, job.id);
+            } catch (error) {
+                console.error('Error starting sync:', error);
+                this.showNotification(`Failed to start sync of ${name}. Please try again.`, 'error');
+            }
+        },
+
+        async pollSyncJob(name, id) {
+            try {
+                const response = await fetch(`/api/sync-jobs/${id}`);
+                if (!response.ok) {
+                    throw new Error(`HTTP error! status: ${response.status}`);
+                }
+                const job = await response.json();
+                this.syncJobs[name] = job;
+
+                if (job.status === 'queued' || job.status === 'running') {
+                    setTimeout(() => this.pollSyncJob(name, id), 2000);
+                    return;
+                }
+
+                if (job.status === 'failed') {
+                    this.showNotification(`Sync of ${name} failed: ${job.error}`, 'error');
+                } else if (job.status === 'skipped') {
+                    this.showNotification(`${name} is already being synced by another run`, 'info');
+                } else {
+                    this.showNotification(`Synced ${name}: ${job.run.inserted} new, ${job.run.updated} updated, ${job.run.pruned} removed`, 'success');
+                }
+
+                this.loadSources();
+                this.loadEvents();
+                this.loadStats();
+            } catch (error) {
+                console.error('Error checking sync:', error);
+                this.syncJobs[name] = null;
+            }
+        },
+
+        syncInProgress(name) {
+            const job = this.syncJobs[name];
+            return job && (job.status === 'queued' || job.status === 'running');
+        },
+
+        formatDateTime(timeString) {
+            if (!timeString) {
+                return 'Never';
+            }
+            return new Date(timeString).toLocaleString('en-US', {
+                month: 'short',
+                day: 'numeric',
+                hour: 'numeric',
+                minute: '2-digit'
+            });
+        },
+
         groupEventsByDate(events) {
             const eventsByDate = {};
 
@@ -100,10 +180,8 @@ function eventManager() {
             let filtered = this.events;
 
             // Apply status filter
-            if (this.statusFilter === 'approved') {
-                filtered = filtered.filter(event => !event.rejected);
-            } else if (this.statusFilter === 'rejected') {
-                filtered = filtered.filter(event => event.rejected);
+            if (this.statusFilter) {
+                filtered = filtered.filter(event => event.review_status === this.statusFilter);
             }
 
             // Group events by date
@@ -117,8 +195,22 @@ function eventManager() {
             this.filteredEvents = this.groupedEvents;
         },
 
+        // isOccurrence reports whether an event was expanded from its series'
+        // rules rather than stored as its own row
+        isOccurrence(uid, recurrenceID) {
+            return this.events.some(event => event.occurrence && event.uid === uid && (event.recurrence_id || '') === (recurrenceID || ''));
+        },
+
         async updateEventStatus(uid, recurrenceID, approved) {
-            const rejected = !approved;
+            const reviewStatus = approved ? 'approved' : 'rejected';
+            const body = { recurrence_id: recurrenceID || '' };
+            if (this.isOccurrence(uid, recurrenceID)) {
+                // an occurrence can only be rejected or given back its
+                // series' status; the series is reviewed as a whole
+                body.rejected = !approved;
+            } else {
+                body.review_status = reviewStatus;
+            }
 
             try {
                 const response = await fetch(`/api/events/${uid}`, {
@@ -126,25 +218,28 @@ function eventManager() {
                     headers: {
                         'Content-Type': 'application/json',
                     },
-                    body: JSON.stringify({
-                        recurrence_id: recurrenceID || '',
-                        rejected: rejected
-                    })
+                    body: JSON.stringify(body)
                 });
 
                 if (!response.ok) {
-                    throw new Error(`HTTP error! status: ${response.status}`);
+                    throw new Error(await response.text());
                 }
 
-                // Update the event in our local data
-                this.events.forEach(event => {
-                    if (event.uid === uid && (event.recurrence_id || '') === (recurrenceID || '')) {
-                        event.rejected = rejected;
-                    }
-                });
+                if (this.isOccurrence(uid, recurrenceID)) {
+                    // the change went to the series' manual exdates
+                    await this.loadEvents();
+                } else {
+                    // Update the event in our local data
+                    this.events.forEach(event => {
+                        if (event.uid === uid && (event.recurrence_id || '') === (recurrenceID || '')) {
+                            event.review_status = reviewStatus;
+                            event.rejected = reviewStatus === 'rejected';
+                        }
+                    });
 
-                // Re-filter and reload stats
-                this.filterEvents();
+                    // Re-filter
+                    this.filterEvents();
+                }
                 this.loadStats();
 
                 // Show success message
@@ -155,6 +250,43 @@ function eventManager() {
             }
         },
 
+        // reviewSeries sets the review status of a whole series, or of its
+        // occurrences from the given start on
+        async reviewSeries(uid, reviewStatus, from) {
+            const scope = from ? 'this and every later occurrence' : 'every occurrence of this series';
+            if (!confirm(`Mark ${scope} as ${this.reviewStatusLabel(reviewStatus).toLowerCase()}?`)) {
+                return;
+            }
+
+            try {
+                const response = await fetch(`/api/events/${uid}/series/review`, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json',
+                    },
+                    body: JSON.stringify({
+                        review_status: reviewStatus,
+                        from: from || ''
+                    })
+                });
+
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+
+                const result = await response.json();
+                await this.loadEvents();
+                this.loadStats();
+
+                // an open ended review only reaches a fixed horizon
+                const through = result.to ? ` through ${new Date(result.to).toLocaleDateString()}` : '';
+                this.showNotification(`Series updated${through}, ${result.changed} change(s) saved`, 'success');
+            } catch (error) {
+                console.error('Error reviewing series:', error);
+                this.showNotification('Failed to update the series. Please try again.', 'error');
+            }
+        },
+
         async updateEventOrganization(uid, recurrenceID, organization) {
             try {
                 const response = await fetch(`/api/events/${uid}`, {
@@ -172,15 +304,20 @@ function eventManager() {
                     throw new Error(`HTTP error! status: ${response.status}`);
                 }
 
-                // Update the event in our local data
-                this.events.forEach(event => {
-                    if (event.uid === uid && (event.recurrence_id || '') === (recurrenceID || '')) {
-                        event.organization = organization;
-                    }
-                });
+                if (this.isOccurrence(uid, recurrenceID)) {
+                    // the series itself was moved to the organization
+                    await this.loadEvents();
+                } else {
+                    // Update the event in our local data
+                    this.events.forEach(event => {
+                        if (event.uid === uid && (event.recurrence_id || '') === (recurrenceID || '')) {
+                            event.organization = organization;
+                        }
+                    });
 
-                // Re-filter
-                this.filterEvents();
+                    // Re-filter
+                    this.filterEvents();
+                }
 
                 // Show success message
                 this.showNotification('Event organization updated successfully!', 'success');
@@ -257,6 +394,7 @@ function eventManager() {
                         'Content-Type': 'application/json',
                     },
                     body: JSON.stringify({
+                        recurrence_id: recurrenceID || '',
                         field: 'location',
                         value: location,
                         mergeLogic: 'overwrite_empty',
@@ -319,6 +457,16 @@ function eventManager() {
             }, 3000);
         },
 
+        reviewStatusLabel(status) {
+            const labels = {
+                pending: 'Pending',
+                needs_review: 'Needs re-review',
+                approved: 'Approved',
+                rejected: 'Rejected'
+            };
+            return labels[status] || status;
+        },
+
         formatDate(dateString) {
             const parts = dateString.split("-");
             const date = new Date(parseInt(parts[0]), parseInt(parts[1]) - 1, parseInt(parts[2]));
@@ -349,9 +497,33 @@ function eventManager() {
             });
         },
 
+        formatTimeRange(event) {
+            if (!event.all_day) {
+                return `${this.formatTime(event.start_time)} - ${this.formatTime(event.end_time)}`;
+            }
+
+            // all-day events end at midnight after their last day
+            const start = new Date(event.start_time);
+            const lastDay = new Date(new Date(event.end_time).getTime() - 1);
+            if (start.toDateString() === lastDay.toDateString()) {
+                return 'All day';
+            }
+
+            return `All day, through ${lastDay.toLocaleDateString('en-US', {
+                weekday: 'long',
+                month: 'long',
+                day: 'numeric'
+            })}`;
+        },
+
         async removeLocationOverlay(uid, recurrenceID) {
+            // an occurrence's overlay may cascade from its series, which is
+            // where it has to be removed
+            const fromSeries = this.events.some(event => event.uid === uid && (event.recurrence_id || '') === (recurrenceID || '') && event.overlay?.location?.from_series);
+            const target = fromSeries ? '' : (recurrenceID || '');
+
             try {
-                const response = await fetch(`/api/events/${uid}/overlay/location`, {
+                const response = await fetch(`/api/events/${uid}/overlay/location?recurrence_id=${encodeURIComponent(target)}`, {
                     method: 'DELETE'
                 });
 
@@ -359,14 +531,19 @@ function eventManager() {
                     throw new Error(`HTTP error! status: ${response.status}`);
                 }
 
-                // Update the event in the local array
-                this.events.forEach(event => {
-                    if (event.uid === uid && (event.recurrence_id || '') === (recurrenceID || '')) {
-                        if (event.overlay && event.overlay.location) {
-                            delete event.overlay.location;
+                if (recurrenceID) {
+                    // the series' overlay may show through, or was the one removed
+                    await this.loadEvents();
+                } else {
+                    // Update the event in the local array
+                    this.events.forEach(event => {
+                        if (event.uid === uid && (event.recurrence_id || '') === (recurrenceID || '')) {
+                            if (event.overlay && event.overlay.location) {
+                                delete event.overlay.location;
+                            }
                         }
-                    }
-                });
+                    });
+                }
 
                 // Re-filter
                 this.filterEvents();